@@ -0,0 +1,354 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ArchiveFS is a read-only file system holding the contents of a zip or tar
+// archive.
+//
+// Files get read from the archive on demand. Only the index of the archive
+// stays in memory.
+//
+// Pass it as the root file system to ReadLicenseGraph etc. to analyze the
+// license metadata of a build without extracting the archive first.
+type ArchiveFS struct {
+	fs.FS
+
+	// archive is the absolute path of the archive file.
+	archive string
+
+	// prefix is the directory inside the archive holding the visible files.
+	prefix string
+
+	// closer releases the archive file when still open.
+	closer io.Closer
+}
+
+// String identifies the archive and directory inside it, e.g. for caching.
+func (afs *ArchiveFS) String() string {
+	return "archive:" + afs.archive + "!" + afs.prefix
+}
+
+// Close releases the archive. The file system cannot be read afterwards.
+func (afs *ArchiveFS) Close() error {
+	if afs.closer == nil {
+		return nil
+	}
+	err := afs.closer.Close()
+	afs.closer = nil
+	return err
+}
+
+// OpenArchiveFS opens the zip, tar or tar.gz archive at `archive` on the
+// operating system file system.
+//
+// The content decides the kind of archive regardless of the file extension.
+// A tar.gz archive gets decompressed into a temporary file that gets removed
+// when the file system gets closed, or earlier where the operating system
+// allows removing open files.
+//
+// When `prefix` is not empty, only the files below directory `prefix` inside
+// the archive are visible, and with `prefix` removed from their names. e.g.
+// with prefix "out/target/product/x" the archive file
+// "out/target/product/x/system/bin/x.meta_lic" is read as
+// "system/bin/x.meta_lic".
+func OpenArchiveFS(archive, prefix string) (*ArchiveFS, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(4)
+
+	afs := &ArchiveFS{archive: archive}
+	if abs, err := filepath.Abs(archive); err == nil {
+		afs.archive = abs
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		f.Close()
+		zr, err := zip.OpenReader(archive)
+		if err != nil {
+			return nil, fmt.Errorf("error reading zip archive %q: %w", archive, err)
+		}
+		afs.FS = zr
+		afs.closer = zr
+	case bytes.HasPrefix(magic, []byte("\x1f\x8b")):
+		defer f.Close()
+		tmp, err := decompressTar(br)
+		if err != nil {
+			return nil, fmt.Errorf("error reading gzip archive %q: %w", archive, err)
+		}
+		afs.FS, err = readTarFS(tmp.File)
+		if err != nil {
+			tmp.Close()
+			return nil, fmt.Errorf("error reading tar archive %q: %w", archive, err)
+		}
+		afs.closer = tmp
+	default:
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		afs.FS, err = readTarFS(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error reading tar archive %q: %w", archive, err)
+		}
+		afs.closer = f
+	}
+
+	prefix = strings.Trim(prefix, "/")
+	if len(prefix) > 0 {
+		prefix = path.Clean(prefix)
+		if !fs.ValidPath(prefix) {
+			afs.Close()
+			return nil, fmt.Errorf("invalid prefix %q for archive %q", prefix, archive)
+		}
+		afs.FS, err = fs.Sub(afs.FS, prefix)
+		if err != nil {
+			afs.Close()
+			return nil, err
+		}
+		afs.prefix = prefix
+	}
+	return afs, nil
+}
+
+// tempFile is a temporary file removed when closed unless already removed.
+type tempFile struct {
+	*os.File
+
+	// removed is true when the file got removed while still open.
+	removed bool
+}
+
+// Close closes and removes the file.
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if !f.removed {
+		if rerr := os.Remove(f.Name()); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// decompressTar decompresses the gzip stream `r` into a temporary file.
+func decompressTar(r io.Reader) (*tempFile, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	f, err := os.CreateTemp("", "archivefs-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	tmp := &tempFile{File: f}
+	// removing the open file keeps it readable without leaving it behind.
+	tmp.removed = os.Remove(f.Name()) == nil
+	if _, err := io.Copy(f, zr); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// archiveEntry describes a file or directory in a tarFS.
+type archiveEntry struct {
+	info fs.FileInfo
+
+	// offset is the position of the content of a regular file in the archive.
+	offset int64
+
+	// children lists the content of a directory in name order.
+	children []fs.DirEntry
+}
+
+// tarFS is an fs.FS indexing the files of a tar archive by their
+// slash-separated names and reading their content from the archive on demand.
+type tarFS struct {
+	// archive reads the tar archive.
+	archive io.ReaderAt
+
+	// entries maps the names of the files and directories to their entries.
+	entries map[string]*archiveEntry
+}
+
+// readTarFS indexes every regular file and directory in the tar archive `f`
+// into a tarFS creating any parent directories missing from the archive.
+//
+// The content of the files stays in `f`, which must remain open while the
+// tarFS gets read.
+func readTarFS(f *os.File) (*tarFS, error) {
+	tfs := &tarFS{f, make(map[string]*archiveEntry)}
+	addDir := func(name string, hdr *tar.Header) {
+		if hdr == nil {
+			hdr = &tar.Header{Name: name, Typeflag: tar.TypeDir, Mode: 0555}
+		}
+		tfs.entries[name] = &archiveEntry{info: hdr.FileInfo()}
+	}
+	addDir(".", nil)
+
+	// tr skips the content of files by seeking `f` so after each header `f`
+	// is positioned at the content of the entry.
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(strings.TrimLeft(hdr.Name, "/"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			addDir(name, hdr)
+		case tar.TypeReg:
+			if isSparse(hdr) {
+				continue
+			}
+			offset, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			tfs.entries[name] = &archiveEntry{info: hdr.FileInfo(), offset: offset}
+		default:
+			continue
+		}
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := tfs.entries[dir]; !ok {
+				addDir(dir, nil)
+			}
+		}
+	}
+
+	for name, e := range tfs.entries {
+		if name == "." {
+			continue
+		}
+		parent := tfs.entries[path.Dir(name)]
+		if parent == nil || !parent.info.IsDir() {
+			return nil, fmt.Errorf("%q is not a directory", path.Dir(name))
+		}
+		parent.children = append(parent.children, fs.FileInfoToDirEntry(e.info))
+	}
+	for _, e := range tfs.entries {
+		sort.Slice(e.children, func(i, j int) bool {
+			return e.children[i].Name() < e.children[j].Name()
+		})
+	}
+	return tfs, nil
+}
+
+// isSparse returns true when `hdr` describes a sparse file whose content in
+// the archive is not the plain file content.
+func isSparse(hdr *tar.Header) bool {
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// Open returns the file or directory `name` implementing fs.FS.
+func (tfs *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := tfs.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.info.IsDir() {
+		return &archiveDir{e, name, 0}, nil
+	}
+	return &archiveFile{e, io.NewSectionReader(tfs.archive, e.offset, e.info.Size())}, nil
+}
+
+// archiveFile is an open regular file in a tarFS.
+type archiveFile struct {
+	e *archiveEntry
+	r *io.SectionReader
+}
+
+// Stat returns the file info for the file.
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.e.info, nil }
+
+// Read reads the content of the file.
+func (f *archiveFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+
+// Close closes the file.
+func (f *archiveFile) Close() error { return nil }
+
+// archiveDir is an open directory in a tarFS.
+type archiveDir struct {
+	e    *archiveEntry
+	name string
+
+	// offset counts the children already returned by ReadDir.
+	offset int
+}
+
+// Stat returns the file info for the directory.
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.e.info, nil }
+
+// Read fails because directories have no content.
+func (d *archiveDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+// Close closes the directory.
+func (d *archiveDir) Close() error { return nil }
+
+// ReadDir returns the next `n` children of the directory implementing
+// fs.ReadDirFile.
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := len(d.e.children) - d.offset
+	if n > 0 && remaining == 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > remaining {
+		n = remaining
+	}
+	entries := d.e.children[d.offset : d.offset+n]
+	d.offset += n
+	return entries, nil
+}
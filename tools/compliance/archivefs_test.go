@@ -0,0 +1,182 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// archiveFiles lists the files written to each test archive in order.
+var archiveFiles = []struct {
+	name string
+	data string
+}{
+	{"out/product/bin/apacheBin.meta_lic", AOSP +
+		"deps: {\n  file: \"lib/gplLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n"},
+	{"out/product/lib/gplLib.meta_lic", GPL},
+	{"out/other/mitLib.meta_lic", MIT},
+	// names over 100 bytes need extra tar header blocks before the content
+	{"out/other/" + strings.Repeat("long/", 20) + "NOTICE", "notice text\n"},
+}
+
+// writeZip writes the archiveFiles to a zip archive at `archive`.
+func writeZip(t *testing.T, archive string) {
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("cannot create %q: %v", archive, err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, af := range archiveFiles {
+		w, err := zw.Create(af.name)
+		if err != nil {
+			t.Fatalf("cannot add %q to zip: %v", af.name, err)
+		}
+		io.WriteString(w, af.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("cannot write zip %q: %v", archive, err)
+	}
+}
+
+// writeTar writes the archiveFiles to a tar archive at `archive` optionally
+// compressed with gzip.
+func writeTar(t *testing.T, archive string, compress bool) {
+	buf := &bytes.Buffer{}
+	var w io.Writer = buf
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(buf)
+		w = zw
+	}
+	tw := tar.NewWriter(w)
+	// directory entries are optional in tar so only write the first
+	tw.WriteHeader(&tar.Header{Name: "./out/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: time.Unix(1600000000, 0)})
+	for _, af := range archiveFiles {
+		hdr := &tar.Header{Name: "./" + af.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(af.data)), ModTime: time.Unix(1600000000, 0)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("cannot add %q to tar: %v", af.name, err)
+		}
+		io.WriteString(tw, af.data)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("cannot write tar %q: %v", archive, err)
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			t.Fatalf("cannot compress tar %q: %v", archive, err)
+		}
+	}
+	if err := os.WriteFile(archive, buf.Bytes(), 0666); err != nil {
+		t.Fatalf("cannot write %q: %v", archive, err)
+	}
+}
+
+func TestOpenArchiveFS(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		write func(t *testing.T, archive string)
+	}{
+		{"zip", writeZip},
+		{"tar", func(t *testing.T, archive string) { writeTar(t, archive, false) }},
+		{"tar.gz", func(t *testing.T, archive string) { writeTar(t, archive, true) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the content decides the kind of archive so use no extension
+			archive := filepath.Join(dir, tt.name+".artifact")
+			tt.write(t, archive)
+
+			afs, err := OpenArchiveFS(archive, "")
+			if err != nil {
+				t.Fatalf("OpenArchiveFS: got error %v, want no error", err)
+			}
+			defer afs.Close()
+			expected := make([]string, 0, len(archiveFiles))
+			for _, af := range archiveFiles {
+				expected = append(expected, af.name)
+			}
+			if err := fstest.TestFS(afs, expected...); err != nil {
+				t.Errorf("OpenArchiveFS: %v", err)
+			}
+			for _, af := range archiveFiles {
+				data, err := fs.ReadFile(afs, af.name)
+				if err != nil {
+					t.Errorf("OpenArchiveFS: got error %v reading %q, want no error", err, af.name)
+				} else if string(data) != af.data {
+					t.Errorf("OpenArchiveFS: got %q for %q, want %q", data, af.name, af.data)
+				}
+			}
+
+			pfs, err := OpenArchiveFS(archive, "/out/product/")
+			if err != nil {
+				t.Fatalf("OpenArchiveFS with prefix: got error %v, want no error", err)
+			}
+			defer pfs.Close()
+			if err := fstest.TestFS(pfs, "bin/apacheBin.meta_lic", "lib/gplLib.meta_lic"); err != nil {
+				t.Errorf("OpenArchiveFS with prefix: %v", err)
+			}
+			if _, err := pfs.Open("out/other/mitLib.meta_lic"); err == nil {
+				t.Errorf("OpenArchiveFS with prefix: got file outside prefix, want error")
+			}
+
+			stderr := &bytes.Buffer{}
+			lg, err := ReadLicenseGraph(pfs, stderr, []string{"bin/apacheBin.meta_lic"})
+			if err != nil {
+				t.Fatalf("ReadLicenseGraph: got error %v, want no error", err)
+			}
+			actual := make([]string, 0)
+			for _, tn := range lg.Targets() {
+				actual = append(actual, tn.Name())
+			}
+			sort.Strings(actual)
+			checkSameStrings("target", actual, []string{"bin/apacheBin.meta_lic", "lib/gplLib.meta_lic"}, t)
+		})
+	}
+}
+
+func TestOpenArchiveFS_errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenArchiveFS(filepath.Join(dir, "missing.zip"), ""); err == nil {
+		t.Errorf("OpenArchiveFS: got no error for missing archive, want error")
+	}
+
+	notArchive := filepath.Join(dir, "notarchive.zip")
+	if err := os.WriteFile(notArchive, []byte("PK\x03\x04 not really a zip"), 0666); err != nil {
+		t.Fatalf("cannot write %q: %v", notArchive, err)
+	}
+	if _, err := OpenArchiveFS(notArchive, ""); err == nil {
+		t.Errorf("OpenArchiveFS: got no error for corrupt archive, want error")
+	}
+
+	archive := filepath.Join(dir, "good.zip")
+	writeZip(t, archive)
+	if _, err := OpenArchiveFS(archive, "../out"); err == nil {
+		t.Errorf("OpenArchiveFS: got no error for invalid prefix, want error")
+	}
+}
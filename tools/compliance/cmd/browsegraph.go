@@ -0,0 +1,475 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	addr         = flag.String("addr", "localhost:8080", "Local address to serve the browser on.")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failNotLocal      = fmt.Errorf("\n-addr must be a localhost or loopback address")
+)
+
+// maxResults is the most targets a search returns.
+const maxResults = 500
+
+type context struct {
+	addr         string
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Reads the license graph once, and serves a browser for it on a local
+address until interrupted.
+
+The pages let you search targets by name, and show each target with its
+license kinds and conditions, built and installed files, install map,
+dependencies, dependents, the shortest path to it from a root, and the
+resolutions that attach to it or act on it. Every target name links to
+its page to follow paths through the graph.
+
+The same information is available as JSON:
+
+  /api/targets?q=substring   lists up to %d matching target names
+  /api/target?name=target    describes the target
+
+Options:
+`, filepath.Base(os.Args[0]), maxResults)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*addr, *graphCache, *licenseKinds, *paths}
+
+	err := browseGraph(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested || err == failNotLocal {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// browseGraph implements the browsegraph utility.
+func browseGraph(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	host, _, err := net.SplitHostPort(ctx.addr)
+	if err != nil {
+		return fmt.Errorf("Invalid -addr %q: %w", ctx.addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return failNotLocal
+	}
+
+	b, err := newBrowser(ctx, stderr, files...)
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", ctx.addr)
+	if err != nil {
+		return fmt.Errorf("Unable to listen on %q: %w", ctx.addr, err)
+	}
+	fmt.Fprintf(stdout, "Serving %d targets on http://%s/\n", len(b.names), l.Addr())
+	return http.Serve(l, b)
+}
+
+// browser serves the pages and API for a license graph.
+type browser struct {
+	ctx *context
+	lg  *compliance.LicenseGraph
+	rs  *compliance.ResolutionSet
+	mux *http.ServeMux
+
+	// names lists the displayed target names in lexical order.
+	names []string
+
+	// targets maps each displayed target name to its target.
+	targets map[string]*compliance.TargetNode
+
+	// deps and dependents index the edges from and to each target name.
+	deps       map[string]compliance.TargetEdgeList
+	dependents map[string]compliance.TargetEdgeList
+
+	// pathFromRoot maps each target name to the last edge of a shortest
+	// path from a root.
+	pathFromRoot map[string]compliance.TargetEdge
+}
+
+// newBrowser reads the license graph rooted at `files` and indexes it.
+func newBrowser(ctx *context, stderr io.Writer, files ...string) (*browser, error) {
+	if len(files) < 1 {
+		return nil, failNoneRequested
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return nil, err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return nil, failNoLicenses
+	}
+
+	b := &browser{
+		ctx:          ctx,
+		lg:           licenseGraph,
+		rs:           compliance.ResolveNoticesWithPolicy(licenseGraph, kinds.Policy()),
+		mux:          http.NewServeMux(),
+		names:        make([]string, 0),
+		targets:      make(map[string]*compliance.TargetNode),
+		deps:         make(map[string]compliance.TargetEdgeList),
+		dependents:   make(map[string]compliance.TargetEdgeList),
+		pathFromRoot: make(map[string]compliance.TargetEdge),
+	}
+	for _, tn := range licenseGraph.Targets() {
+		name := ctx.paths.OutputName(tn.Name())
+		b.names = append(b.names, name)
+		b.targets[name] = tn
+	}
+	sort.Strings(b.names)
+	edges := licenseGraph.Edges()
+	sort.Sort(edges)
+	for _, e := range edges {
+		b.deps[e.Target().Name()] = append(b.deps[e.Target().Name()], e)
+		b.dependents[e.Dependency().Name()] = append(b.dependents[e.Dependency().Name()], e)
+	}
+
+	// Breadth-first from the roots finds a shortest path to each target.
+	queue := make([]string, 0, len(files))
+	visited := make(map[string]bool)
+	for _, f := range files {
+		f = compliance.MetadataFileName(f)
+		if !licenseGraph.HasTargetNode(f) {
+			return nil, fmt.Errorf("Root %q missing from license graph", ctx.paths.OutputName(f))
+		}
+		if !visited[f] {
+			visited[f] = true
+			queue = append(queue, f)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, e := range b.deps[name] {
+			dep := e.Dependency().Name()
+			if !visited[dep] {
+				visited[dep] = true
+				b.pathFromRoot[dep] = e
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	b.mux.HandleFunc("/", b.serveSearch)
+	b.mux.HandleFunc("/target", b.serveTarget)
+	b.mux.HandleFunc("/api/targets", b.serveSearch)
+	b.mux.HandleFunc("/api/target", b.serveTarget)
+	return b, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (b *browser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// searchResult describes the targets matching a search.
+type searchResult struct {
+	Query     string   `json:"query"`
+	Targets   []string `json:"targets"`
+	Truncated bool     `json:"truncated"`
+}
+
+// search returns the target names containing `q`.
+func (b *browser) search(q string) searchResult {
+	result := searchResult{q, make([]string, 0), false}
+	for _, name := range b.names {
+		if !strings.Contains(name, q) {
+			continue
+		}
+		if len(result.Targets) == maxResults {
+			result.Truncated = true
+			break
+		}
+		result.Targets = append(result.Targets, name)
+	}
+	return result
+}
+
+// serveSearch serves the search page or the /api/targets results.
+func (b *browser) serveSearch(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/api/targets" {
+		http.NotFound(w, r)
+		return
+	}
+	result := b.search(r.URL.Query().Get("q"))
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, result)
+		return
+	}
+	writeHTML(w, searchTemplate, result)
+}
+
+// edgeRecord describes an edge.
+type edgeRecord struct {
+	Target      string   `json:"target"`
+	Dependency  string   `json:"dependency"`
+	Annotations []string `json:"annotations"`
+}
+
+// installMapRecord describes an install map entry.
+type installMapRecord struct {
+	FromPath      string `json:"from_path"`
+	ContainerPath string `json:"container_path"`
+}
+
+// resolutionRecord describes a resolution of 1 condition.
+type resolutionRecord struct {
+	AttachesTo string `json:"attaches_to"`
+	ActsOn     string `json:"acts_on"`
+	Origin     string `json:"origin"`
+	Condition  string `json:"condition"`
+}
+
+// targetDetail describes a target for the target page and /api/target.
+type targetDetail struct {
+	Name                string             `json:"name"`
+	Package             string             `json:"package"`
+	Projects            []string           `json:"projects"`
+	ModuleTypes         []string           `json:"module_types"`
+	ModuleClasses       []string           `json:"module_classes"`
+	LicenseKinds        []string           `json:"license_kinds"`
+	LicenseConditions   []string           `json:"license_conditions"`
+	IsContainer         bool               `json:"is_container"`
+	Built               []string           `json:"built"`
+	Installed           []string           `json:"installed"`
+	InstallMap          []installMapRecord `json:"install_map"`
+	Dependencies        []edgeRecord       `json:"dependencies"`
+	Dependents          []edgeRecord       `json:"dependents"`
+	PathFromRoot        []edgeRecord       `json:"path_from_root"`
+	AttachedResolutions []resolutionRecord `json:"attached_resolutions"`
+	ActingResolutions   []resolutionRecord `json:"acting_resolutions"`
+}
+
+// detail describes the target `tn`.
+func (b *browser) detail(tn *compliance.TargetNode) targetDetail {
+	d := targetDetail{
+		Name:                b.ctx.paths.OutputName(tn.Name()),
+		Package:             tn.PackageName(),
+		Projects:            sorted(tn.Projects()),
+		ModuleTypes:         sorted(tn.ModuleTypes()),
+		ModuleClasses:       sorted(tn.ModuleClasses()),
+		LicenseKinds:        sorted(tn.LicenseKinds()),
+		LicenseConditions:   sorted(tn.LicenseConditions().Names()),
+		IsContainer:         tn.IsContainer(),
+		Built:               b.stripAll(tn.Built()),
+		Installed:           b.stripAll(tn.Installed()),
+		InstallMap:          make([]installMapRecord, 0),
+		Dependencies:        b.toEdgeRecords(b.deps[tn.Name()]),
+		Dependents:          b.toEdgeRecords(b.dependents[tn.Name()]),
+		AttachedResolutions: b.toResolutionRecords(b.rs.Resolutions(tn)),
+		ActingResolutions:   b.toResolutionRecords(b.rs.ResolutionsByActsOn(tn)),
+	}
+	for _, im := range tn.InstallMap() {
+		d.InstallMap = append(d.InstallMap, installMapRecord{im.FromPath, im.ContainerPath})
+	}
+	path := make(compliance.TargetEdgeList, 0)
+	for name := tn.Name(); ; {
+		e, ok := b.pathFromRoot[name]
+		if !ok {
+			break
+		}
+		path = append(compliance.TargetEdgeList{e}, path...)
+		name = e.Target().Name()
+	}
+	d.PathFromRoot = b.toEdgeRecords(path)
+	return d
+}
+
+// serveTarget serves the target page or the /api/target description.
+func (b *browser) serveTarget(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if len(name) == 0 {
+		http.Error(w, "missing name parameter", http.StatusBadRequest)
+		return
+	}
+	tn, ok := b.targets[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown target %q", name), http.StatusNotFound)
+		return
+	}
+	d := b.detail(tn)
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, d)
+		return
+	}
+	writeHTML(w, targetTemplate, d)
+}
+
+// toEdgeRecords converts `edges` into edge records in the same order.
+func (b *browser) toEdgeRecords(edges compliance.TargetEdgeList) []edgeRecord {
+	result := make([]edgeRecord, 0, len(edges))
+	for _, e := range edges {
+		result = append(result, edgeRecord{b.ctx.paths.OutputName(e.Target().Name()), b.ctx.paths.OutputName(e.Dependency().Name()), e.Annotations().AsList()})
+	}
+	return result
+}
+
+// toResolutionRecords converts `rl` into sorted resolution records with 1
+// condition each.
+func (b *browser) toResolutionRecords(rl compliance.ResolutionList) []resolutionRecord {
+	sort.Sort(rl)
+	result := make([]resolutionRecord, 0, len(rl))
+	for _, r := range rl {
+		conditions := r.Resolves().AsList()
+		sort.Sort(conditions)
+		for _, lc := range conditions {
+			result = append(result, resolutionRecord{b.ctx.paths.OutputName(r.AttachesTo().Name()), b.ctx.paths.OutputName(r.ActsOn().Name()), b.ctx.paths.OutputName(lc.Origin().Name()), lc.Name()})
+		}
+	}
+	return result
+}
+
+// sorted returns `values` in lexical order.
+func sorted(values []string) []string {
+	sort.Strings(values)
+	return values
+}
+
+// stripAll returns the `paths` as they appear in the output in lexical order.
+func (b *browser) stripAll(paths []string) []string {
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		result = append(result, b.ctx.paths.OutputName(p))
+	}
+	return sorted(result)
+}
+
+// writeJSON writes `v` to `w` as indented JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeHTML writes `data` expanded by `t` to `w`.
+func writeHTML(w http.ResponseWriter, t *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// pageHeader starts every page with a search form.
+const pageHeader = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{template "title" .}}</title>
+<style>body{font-family:sans-serif} td,th{padding:0 1em 0 0;text-align:left;vertical-align:top}</style>
+</head><body>
+<form action="/"><input name="q" size="60"> <input type="submit" value="Search"></form>
+`
+
+// targetLink renders a target name as a link to its page.
+const targetLink = `{{define "link"}}<a href="/target?name={{.}}">{{.}}</a>{{end}}`
+
+var searchTemplate = template.Must(template.New("searchPage").Parse(pageHeader + targetLink + `
+{{define "title"}}Targets{{end}}
+<h1>Targets containing &quot;{{.Query}}&quot;</h1>
+<ul>
+{{range .Targets}}<li>{{template "link" .}}</li>
+{{end}}</ul>
+{{if .Truncated}}<p>Only the first results shown. Refine the search.</p>{{end}}
+</body></html>
+`))
+
+var targetTemplate = template.Must(template.New("targetPage").Parse(pageHeader + targetLink + `
+{{define "title"}}{{.Name}}{{end}}
+{{define "edges"}}<table><tr><th>Target</th><th>Dependency</th><th>Annotations</th></tr>
+{{range .}}<tr><td>{{template "link" .Target}}</td><td>{{template "link" .Dependency}}</td><td>{{range .Annotations}}{{.}} {{end}}</td></tr>
+{{end}}</table>{{end}}
+{{define "resolutions"}}<table><tr><th>Attaches to</th><th>Acts on</th><th>Origin</th><th>Condition</th></tr>
+{{range .}}<tr><td>{{template "link" .AttachesTo}}</td><td>{{template "link" .ActsOn}}</td><td>{{template "link" .Origin}}</td><td>{{.Condition}}</td></tr>
+{{end}}</table>{{end}}
+<h1>{{.Name}}</h1>
+<table>
+<tr><th>Package</th><td>{{.Package}}</td></tr>
+<tr><th>Projects</th><td>{{range .Projects}}{{.}}<br>{{end}}</td></tr>
+<tr><th>Module types</th><td>{{range .ModuleTypes}}{{.}}<br>{{end}}</td></tr>
+<tr><th>Module classes</th><td>{{range .ModuleClasses}}{{.}}<br>{{end}}</td></tr>
+<tr><th>License kinds</th><td>{{range .LicenseKinds}}{{.}}<br>{{end}}</td></tr>
+<tr><th>License conditions</th><td>{{range .LicenseConditions}}{{.}}<br>{{end}}</td></tr>
+<tr><th>Container</th><td>{{.IsContainer}}</td></tr>
+<tr><th>Built</th><td>{{range .Built}}{{.}}<br>{{end}}</td></tr>
+<tr><th>Installed</th><td>{{range .Installed}}{{.}}<br>{{end}}</td></tr>
+</table>
+<h2>Install map</h2>
+<table><tr><th>From</th><th>To</th></tr>
+{{range .InstallMap}}<tr><td>{{.FromPath}}</td><td>{{.ContainerPath}}</td></tr>
+{{end}}</table>
+<h2>Path from root</h2>
+{{template "edges" .PathFromRoot}}
+<h2>Dependencies</h2>
+{{template "edges" .Dependencies}}
+<h2>Dependents</h2>
+{{template "edges" .Dependents}}
+<h2>Resolutions attached</h2>
+{{template "resolutions" .AttachedResolutions}}
+<h2>Resolutions acting on it</h2>
+{{template "resolutions" .ActingResolutions}}
+</body></html>
+`))
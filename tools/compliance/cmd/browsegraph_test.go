@@ -0,0 +1,258 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"android/soong/compliance/license_metadata_proto"
+)
+
+// get requests `path` from `b` and returns the response.
+func get(b *browser, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	b.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func Test(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	b, err := newBrowser(&context{}, stderr, "testdata/restricted/bin/bin1.meta_lic", "testdata/restricted/bin/bin2.meta_lic")
+	if err != nil {
+		t.Fatalf("browsegraph: error = %v, stderr = %v", err, stderr)
+	}
+	const (
+		bin1 = "testdata/restricted/bin/bin1.meta_lic"
+		liba = "testdata/restricted/lib/liba.so.meta_lic"
+		libc = "testdata/restricted/lib/libc.a.meta_lic"
+	)
+
+	t.Run("search", func(t *testing.T) {
+		w := get(b, "/api/targets?q=lib/")
+		if w.Code != http.StatusOK {
+			t.Fatalf("browsegraph: got status %d, want %d", w.Code, http.StatusOK)
+		}
+		var actual searchResult
+		if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+			t.Fatalf("browsegraph: cannot parse %q: %v", w.Body, err)
+		}
+		expected := searchResult{
+			Query: "lib/",
+			Targets: []string{
+				"testdata/restricted/lib/liba.so.meta_lic",
+				"testdata/restricted/lib/libb.so.meta_lic",
+				"testdata/restricted/lib/libc.a.meta_lic",
+				"testdata/restricted/lib/libd.so.meta_lic",
+			},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("browsegraph: got %+v, want %+v", actual, expected)
+		}
+	})
+
+	t.Run("target", func(t *testing.T) {
+		w := get(b, "/api/target?name="+url.QueryEscape(libc))
+		if w.Code != http.StatusOK {
+			t.Fatalf("browsegraph: got status %d, want %d", w.Code, http.StatusOK)
+		}
+		var actual targetDetail
+		if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+			t.Fatalf("browsegraph: cannot parse %q: %v", w.Body, err)
+		}
+		if actual.Name != libc || !reflect.DeepEqual(actual.Projects, []string{"static/library"}) {
+			t.Errorf("browsegraph: got name %q projects %v, want %q [static/library]", actual.Name, actual.Projects, libc)
+		}
+		if !reflect.DeepEqual(actual.LicenseConditions, []string{"reciprocal"}) {
+			t.Errorf("browsegraph: got conditions %v, want [reciprocal]", actual.LicenseConditions)
+		}
+		expectedDependents := []edgeRecord{{bin1, libc, []string{"static"}}}
+		if !reflect.DeepEqual(actual.Dependents, expectedDependents) {
+			t.Errorf("browsegraph: got dependents %v, want %v", actual.Dependents, expectedDependents)
+		}
+		if len(actual.Dependencies) != 0 {
+			t.Errorf("browsegraph: got dependencies %v, want none", actual.Dependencies)
+		}
+		if !reflect.DeepEqual(actual.PathFromRoot, expectedDependents) {
+			t.Errorf("browsegraph: got path %v, want %v", actual.PathFromRoot, expectedDependents)
+		}
+		expectedActing := []resolutionRecord{
+			{bin1, libc, liba, "restricted"},
+			{bin1, libc, libc, "reciprocal"},
+		}
+		if !reflect.DeepEqual(actual.ActingResolutions, expectedActing) {
+			t.Errorf("browsegraph: got acting resolutions %v, want %v", actual.ActingResolutions, expectedActing)
+		}
+		if len(actual.AttachedResolutions) != 0 {
+			t.Errorf("browsegraph: got attached resolutions %v, want none", actual.AttachedResolutions)
+		}
+	})
+
+	t.Run("root", func(t *testing.T) {
+		var actual targetDetail
+		w := get(b, "/api/target?name="+url.QueryEscape(bin1))
+		if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+			t.Fatalf("browsegraph: cannot parse %q: %v", w.Body, err)
+		}
+		if len(actual.PathFromRoot) != 0 {
+			t.Errorf("browsegraph: got path %v, want none", actual.PathFromRoot)
+		}
+		expectedDeps := []edgeRecord{
+			{bin1, liba, []string{"static"}},
+			{bin1, libc, []string{"static"}},
+		}
+		if !reflect.DeepEqual(actual.Dependencies, expectedDeps) {
+			t.Errorf("browsegraph: got dependencies %v, want %v", actual.Dependencies, expectedDeps)
+		}
+		for _, r := range actual.AttachedResolutions {
+			if r.AttachesTo != bin1 {
+				t.Errorf("browsegraph: got attached resolution %v, want attaches to %q", r, bin1)
+			}
+		}
+		if len(actual.AttachedResolutions) == 0 {
+			t.Errorf("browsegraph: got no attached resolutions, want some")
+		}
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		w := get(b, "/?q=libc")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="/target?name=testdata%2frestricted%2flib%2flibc.a.meta_lic"`) {
+			t.Errorf("browsegraph: got search page %d %q, want link to %q", w.Code, w.Body, libc)
+		}
+		w = get(b, "/target?name="+url.QueryEscape(libc))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<h1>"+libc+"</h1>") {
+			t.Errorf("browsegraph: got target page %d %q, want heading %q", w.Code, w.Body, libc)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if w := get(b, "/api/target"); w.Code != http.StatusBadRequest {
+			t.Errorf("browsegraph: got status %d for missing name, want %d", w.Code, http.StatusBadRequest)
+		}
+		if w := get(b, "/target?name=nosuch.meta_lic"); w.Code != http.StatusNotFound {
+			t.Errorf("browsegraph: got status %d for unknown target, want %d", w.Code, http.StatusNotFound)
+		}
+		if w := get(b, "/nosuch"); w.Code != http.StatusNotFound {
+			t.Errorf("browsegraph: got status %d for unknown page, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func Test_notLocal(t *testing.T) {
+	for _, addr := range []string{"0.0.0.0:8080", ":8080", "example.com:80"} {
+		err := browseGraph(&context{addr: addr}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/bin/bin1.meta_lic")
+		if err != failNotLocal {
+			t.Errorf("browsegraph: got error %v for %q, want %v", err, addr, failNotLocal)
+		}
+	}
+}
+
+func Test_rewrite(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	ctx := &context{paths: compliance.PathFlags{StripPrefix: "testdata/", Rewrites: compliance.PrefixRewrites{"restricted/": "<tree>/"}}}
+	b, err := newBrowser(ctx, stderr, "testdata/restricted/bin/bin1.meta_lic")
+	if err != nil {
+		t.Fatalf("browsegraph: error = %v, stderr = %v", err, stderr)
+	}
+
+	w := get(b, "/api/targets?q=bin/")
+	var search searchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &search); err != nil {
+		t.Fatalf("browsegraph: cannot parse %q: %v", w.Body, err)
+	}
+	if !reflect.DeepEqual(search.Targets, []string{"<tree>/bin/bin1.meta_lic"}) {
+		t.Errorf("browsegraph: got targets %v, want [<tree>/bin/bin1.meta_lic]", search.Targets)
+	}
+
+	w = get(b, "/api/target?name="+url.QueryEscape("<tree>/bin/bin1.meta_lic"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("browsegraph: got status %d for rewritten name, want %d", w.Code, http.StatusOK)
+	}
+	var actual targetDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &actual); err != nil {
+		t.Fatalf("browsegraph: cannot parse %q: %v", w.Body, err)
+	}
+	expectedDeps := []edgeRecord{
+		{"<tree>/bin/bin1.meta_lic", "<tree>/lib/liba.so.meta_lic", []string{"static"}},
+		{"<tree>/bin/bin1.meta_lic", "<tree>/lib/libc.a.meta_lic", []string{"static"}},
+	}
+	if actual.Name != "<tree>/bin/bin1.meta_lic" || !reflect.DeepEqual(actual.Dependencies, expectedDeps) {
+		t.Errorf("browsegraph: got name %q dependencies %v, want %q %v", actual.Name, actual.Dependencies, "<tree>/bin/bin1.meta_lic", expectedDeps)
+	}
+
+	if w := get(b, "/api/target?name="+url.QueryEscape("testdata/restricted/bin/bin1.meta_lic")); w.Code != http.StatusNotFound {
+		t.Errorf("browsegraph: got status %d for original name, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func Test_jsonRoot(t *testing.T) {
+	const (
+		root = "testdata/restricted/bin/bin1.json"
+		liba = "testdata/restricted/lib/liba.so.meta_lic"
+	)
+	stderr := &bytes.Buffer{}
+	b, err := newBrowser(&context{paths: compliance.PathFlags{RootFS: jsonRootFS(t, "restricted", "bin/bin1.meta_lic")}}, stderr, root)
+	if err != nil {
+		t.Fatalf("browsegraph: error = %v, stderr = %v", err, stderr)
+	}
+	e, ok := b.pathFromRoot[liba]
+	if !ok {
+		t.Fatalf("browsegraph: no path from %q to %q", root, liba)
+	}
+	if e.Target().Name() != root+".meta_lic" {
+		t.Errorf("browsegraph: got path from %q, want from %q", e.Target().Name(), root+".meta_lic")
+	}
+}
+
+// jsonRootFS returns the testdata for `condition` with `root` also converted
+// to JSON as `root` with .meta_lic replaced by .json.meta_lic.
+func jsonRootFS(t *testing.T, condition, root string) fs.FS {
+	dir := "testdata/" + condition
+	mfs := make(fstest.MapFS)
+	err := fs.WalkDir(os.DirFS("."), dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		mfs[path] = &fstest.MapFile{Data: data}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to read testdata: %v", err)
+	}
+	var pb license_metadata_proto.LicenseMetadata
+	if err := compliance.UnmarshalLicenseMetadata(mfs[dir+"/"+root].Data, &pb); err != nil {
+		t.Fatalf("unable to parse %q: %v", root, err)
+	}
+	data, err := compliance.MarshalLicenseMetadata(&pb, compliance.JSONFormat)
+	if err != nil {
+		t.Fatalf("unable to convert %q: %v", root, err)
+	}
+	mfs[dir+"/"+strings.TrimSuffix(root, ".meta_lic")+".json.meta_lic"] = &fstest.MapFile{Data: data}
+	return mfs
+}
@@ -0,0 +1,206 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	approvals    = flag.String("approvals", "", "Path to a JSON file of approved targets. (optional)")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failUnapproved    = fmt.Errorf("unapproved")
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+)
+
+type context struct {
+	approvals    string
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Outputs a space-separated Root Target Conditions tuple for each shipped
+target with a license condition requiring review and approval before
+use, i.e. by_exception_only or proprietary, where the root distributes
+the target and multiple conditions are colon-separated.
+
+Reports on stderr any of those targets missing from the approvals file.
+Approvals may name targets in full or as they appear in the output, i.e.
+after any -strip_prefix and -rewrite_prefix. The approvals file is JSON
+like:
+
+  {
+    "approvals": [
+      {
+        "target": "out/target/product/fictional/bin/bin1.meta_lic",
+        "justification": "Approved by legal in ticket 1234."
+      }
+    ]
+  }
+
+If every target requiring approval is approved, outputs "PASS" to stdout
+and exits with status 0.
+
+If any target requiring approval is not approved, outputs "FAIL" to
+stdout and exits with status 1.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*approvals, *graphCache, *licenseKinds, *paths}
+
+	err := checkException(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err != failUnapproved {
+			if err == failNoneRequested {
+				flag.Usage()
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		}
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// approval describes an approved target in the -approvals file.
+type approval struct {
+	Target        string `json:"target"`
+	Justification string `json:"justification"`
+}
+
+// checkException implements the checkexception utility.
+func checkException(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+
+	// Read the approved targets.
+	approved := make(map[string]bool)
+	if len(ctx.approvals) > 0 {
+		as, err := readApprovals(ctx.approvals)
+		if err != nil {
+			return err
+		}
+		for _, a := range as {
+			approved[a.Target] = true
+		}
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	// Sort the roots for repeatability/stability.
+	roots := make([]string, 0, len(files))
+	for _, f := range files {
+		roots = append(roots, compliance.MetadataFileName(f))
+	}
+	sort.Strings(roots)
+
+	// Apply policy to find the targets requiring approval distributed by each root.
+	rs := compliance.ResolveByExceptionOnlyWithPolicy(licenseGraph, kinds.Policy())
+	unapproved := make([]string, 0)
+	for _, root := range roots {
+		if !licenseGraph.HasTargetNode(root) {
+			return fmt.Errorf("Root %q missing from license graph", ctx.paths.OutputName(root))
+		}
+		rl := rs.Resolutions(licenseGraph.TargetNode(root))
+		sort.Sort(rl)
+		for _, r := range rl {
+			conditions := r.Resolves().Names()
+			sort.Strings(conditions)
+			target := r.ActsOn().Name()
+			fmt.Fprintf(stdout, "%s %s %s\n", ctx.paths.OutputName(root), ctx.paths.OutputName(target), strings.Join(conditions, ":"))
+			if !approved[target] && !approved[ctx.paths.OutputName(target)] {
+				unapproved = append(unapproved, fmt.Sprintf("%s %s distributed by %s not approved", ctx.paths.OutputName(target), strings.Join(conditions, ":"), ctx.paths.OutputName(root)))
+			}
+		}
+	}
+	for _, u := range unapproved {
+		fmt.Fprintln(stderr, u)
+	}
+
+	// Indicate pass or fail on stdout.
+	if len(unapproved) > 0 {
+		fmt.Fprintln(stdout, "FAIL")
+		return failUnapproved
+	}
+	fmt.Fprintln(stdout, "PASS")
+	return nil
+}
+
+// readApprovals reads and validates the approvals in `path`.
+func readApprovals(path string) ([]approval, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read approvals file %q: %w", path, err)
+	}
+	var file struct {
+		Approvals []approval `json:"approvals"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Unable to parse approvals file %q: %w", path, err)
+	}
+	for i, a := range file.Approvals {
+		if len(a.Target) == 0 {
+			return nil, fmt.Errorf("Invalid approval %d in %q: missing target", i+1, path)
+		}
+	}
+	return file.Approvals, nil
+}
@@ -0,0 +1,246 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"android/soong/compliance/license_metadata_proto"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition      string
+		name           string
+		roots          []string
+		approved       []string
+		expectedErr    error
+		expectedStdout []string
+		expectedStderr []string
+	}{
+		{
+			condition:      "firstparty",
+			name:           "apex",
+			roots:          []string{"highest.apex.meta_lic"},
+			expectedStdout: []string{"PASS"},
+		},
+		{
+			condition:      "restricted",
+			name:           "apex",
+			roots:          []string{"highest.apex.meta_lic"},
+			expectedStdout: []string{"PASS"},
+		},
+		{
+			condition:   "proprietary",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			expectedErr: failUnapproved,
+			expectedStdout: []string{
+				"highest.apex.meta_lic bin/bin2.meta_lic by_exception_only:proprietary",
+				"highest.apex.meta_lic lib/liba.so.meta_lic by_exception_only:proprietary",
+				"highest.apex.meta_lic lib/libc.a.meta_lic by_exception_only:proprietary",
+				"FAIL",
+			},
+			expectedStderr: []string{
+				"bin/bin2.meta_lic by_exception_only:proprietary distributed by highest.apex.meta_lic not approved",
+				"lib/liba.so.meta_lic by_exception_only:proprietary distributed by highest.apex.meta_lic not approved",
+				"lib/libc.a.meta_lic by_exception_only:proprietary distributed by highest.apex.meta_lic not approved",
+			},
+		},
+		{
+			condition:   "proprietary",
+			name:        "apex_partly_approved",
+			roots:       []string{"highest.apex.meta_lic"},
+			approved:    []string{"bin/bin2.meta_lic", "lib/libc.a.meta_lic"},
+			expectedErr: failUnapproved,
+			expectedStdout: []string{
+				"highest.apex.meta_lic bin/bin2.meta_lic by_exception_only:proprietary",
+				"highest.apex.meta_lic lib/liba.so.meta_lic by_exception_only:proprietary",
+				"highest.apex.meta_lic lib/libc.a.meta_lic by_exception_only:proprietary",
+				"FAIL",
+			},
+			expectedStderr: []string{
+				"lib/liba.so.meta_lic by_exception_only:proprietary distributed by highest.apex.meta_lic not approved",
+			},
+		},
+		{
+			condition: "proprietary",
+			name:      "apex_approved",
+			roots:     []string{"highest.apex.meta_lic"},
+			approved:  []string{"bin/bin2.meta_lic", "lib/liba.so.meta_lic", "lib/libc.a.meta_lic"},
+			expectedStdout: []string{
+				"highest.apex.meta_lic bin/bin2.meta_lic by_exception_only:proprietary",
+				"highest.apex.meta_lic lib/liba.so.meta_lic by_exception_only:proprietary",
+				"highest.apex.meta_lic lib/libc.a.meta_lic by_exception_only:proprietary",
+				"PASS",
+			},
+		},
+		{
+			condition:   "proprietary",
+			name:        "tworoots",
+			roots:       []string{"bin/bin2.meta_lic", "application.meta_lic"},
+			expectedErr: failUnapproved,
+			expectedStdout: []string{
+				"application.meta_lic lib/liba.so.meta_lic by_exception_only:proprietary",
+				"bin/bin2.meta_lic bin/bin2.meta_lic by_exception_only:proprietary",
+				"FAIL",
+			},
+			expectedStderr: []string{
+				"lib/liba.so.meta_lic by_exception_only:proprietary distributed by application.meta_lic not approved",
+				"bin/bin2.meta_lic by_exception_only:proprietary distributed by bin/bin2.meta_lic not approved",
+			},
+		},
+		{
+			condition:      "proprietary",
+			name:           "library",
+			roots:          []string{"lib/libd.so.meta_lic"},
+			expectedStdout: []string{"PASS"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			prefix := "testdata/" + tt.condition + "/"
+
+			ctx := &context{paths: compliance.PathFlags{StripPrefix: prefix}}
+			if len(tt.approved) > 0 {
+				data := `{"approvals": [`
+				for i, a := range tt.approved {
+					if i > 0 {
+						data += ", "
+					}
+					data += `{"target": "` + prefix + a + `", "justification": "reviewed"}`
+				}
+				data += "]}"
+				ctx.approvals = filepath.Join(t.TempDir(), "approvals.json")
+				if err := os.WriteFile(ctx.approvals, []byte(data), 0644); err != nil {
+					t.Fatalf("unable to write approvals: %v", err)
+				}
+			}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, prefix+r)
+			}
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			err := checkException(ctx, stdout, stderr, rootFiles...)
+			if err != tt.expectedErr {
+				t.Fatalf("checkexception: error = %v, want %v, stderr = %v", err, tt.expectedErr, stderr)
+			}
+			actualStdout := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if !reflect.DeepEqual(actualStdout, tt.expectedStdout) {
+				t.Errorf("checkexception: got stdout %q, want %q", actualStdout, tt.expectedStdout)
+			}
+			actualStderr := make([]string, 0)
+			for _, line := range strings.Split(stderr.String(), "\n") {
+				if len(line) > 0 {
+					actualStderr = append(actualStderr, line)
+				}
+			}
+			if len(actualStderr) > 0 || len(tt.expectedStderr) > 0 {
+				if !reflect.DeepEqual(actualStderr, tt.expectedStderr) {
+					t.Errorf("checkexception: got stderr %q, want %q", actualStderr, tt.expectedStderr)
+				}
+			}
+		})
+	}
+}
+
+func Test_strippedApprovals(t *testing.T) {
+	// approvals may use the names as output
+	approvals := filepath.Join(t.TempDir(), "approvals.json")
+	data := `{"approvals": [{"target": "bin/bin2.meta_lic", "justification": "reviewed"}]}`
+	if err := os.WriteFile(approvals, []byte(data), 0644); err != nil {
+		t.Fatalf("unable to write approvals: %v", err)
+	}
+	ctx := &context{approvals: approvals, paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if err := checkException(ctx, stdout, stderr, "testdata/proprietary/bin/bin2.meta_lic"); err != nil {
+		t.Fatalf("checkexception: error = %v, stderr = %v", err, stderr)
+	}
+	if stderr.Len() > 0 {
+		t.Errorf("checkexception: got stderr %q, want none", stderr)
+	}
+}
+
+func Test_invalidApprovals(t *testing.T) {
+	approvals := filepath.Join(t.TempDir(), "approvals.json")
+	if err := os.WriteFile(approvals, []byte(`{"approvals": [{"justification": "reviewed"}]}`), 0644); err != nil {
+		t.Fatalf("unable to write approvals: %v", err)
+	}
+	err := checkException(&context{approvals: approvals}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/proprietary/bin/bin2.meta_lic")
+	if err == nil || !strings.Contains(err.Error(), "missing target") {
+		t.Errorf("checkexception: got error %v, want missing target", err)
+	}
+}
+
+func Test_jsonRoot(t *testing.T) {
+	prefix := "testdata/proprietary/"
+	ctx := &context{paths: compliance.PathFlags{StripPrefix: prefix, RootFS: jsonRootFS(t, "proprietary", "bin/bin2.meta_lic")}}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	err := checkException(ctx, stdout, stderr, prefix+"bin/bin2.json")
+	if err != failUnapproved {
+		t.Fatalf("checkexception: error = %v, want %v, stderr = %v", err, failUnapproved, stderr)
+	}
+	expected := []string{
+		"bin/bin2.json.meta_lic bin/bin2.json.meta_lic by_exception_only:proprietary",
+		"FAIL",
+	}
+	actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("checkexception: got stdout %q, want %q", actual, expected)
+	}
+}
+
+// jsonRootFS returns the testdata for `condition` with `root` also converted
+// to JSON as `root` with .meta_lic replaced by .json.meta_lic.
+func jsonRootFS(t *testing.T, condition, root string) fs.FS {
+	dir := "testdata/" + condition
+	mfs := make(fstest.MapFS)
+	err := fs.WalkDir(os.DirFS("."), dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		mfs[path] = &fstest.MapFile{Data: data}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to read testdata: %v", err)
+	}
+	var pb license_metadata_proto.LicenseMetadata
+	if err := compliance.UnmarshalLicenseMetadata(mfs[dir+"/"+root].Data, &pb); err != nil {
+		t.Fatalf("unable to parse %q: %v", root, err)
+	}
+	data, err := compliance.MarshalLicenseMetadata(&pb, compliance.JSONFormat)
+	if err != nil {
+		t.Fatalf("unable to convert %q: %v", root, err)
+	}
+	mfs[dir+"/"+strings.TrimSuffix(root, ".meta_lic")+".json.meta_lic"] = &fstest.MapFile{Data: data}
+	return mfs
+}
@@ -16,17 +16,20 @@ package main
 
 import (
 	"compliance"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 )
 
 func init() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `Usage: %s file.meta_lic {file.meta_lic...}
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
 
 Reports on stderr any targets where policy says that the source both
 must and must not be shared. The error report indicates the target, the
@@ -41,16 +44,66 @@ outputs "PASS" to stdout and exits with status 0.
 
 If policy says any source must both be shared and not be shared,
 outputs "FAIL" to stdout and exits with status 1.
+
+When -format=json given, outputs a {"pass", "conflicts"} object to
+stdout instead, where each conflict is a {"source", "share_origin",
+"share_condition", "privacy_origin", "privacy_condition"} object. When
+-format=csv given, outputs a header row followed by one row per conflict
+with the same fields plus a "status" of conflict, expired or waived and
+the "justification" of the waiver if any. In both cases, no conflicts get
+reported on stderr and the exit status indicates pass or fail.
+
+When -waivers given, conflicts approved by an unexpired waiver do not
+cause a failure. The waivers file is JSON like:
+
+  {
+    "waivers": [
+      {
+        "target": "out/target/product/fictional/bin/bin1.meta_lic",
+        "share_origin": "out/target/product/fictional/lib/liba.so.meta_lic",
+        "privacy_origin": "out/target/product/fictional/bin/bin1.meta_lic",
+        "justification": "Approved by legal in ticket 1234.",
+        "expires": "2022-12-31"
+      }
+    ]
+  }
+
+Waivers may name targets in full or as they appear in the output, i.e.
+after any -strip_prefix and -rewrite_prefix.
+
+The "expires" date is optional, and a waiver applies through the end of
+that day. Expired waivers, and waivers matching no conflict, get reported
+on stderr or, when -format=json given, in the "expired_waivers" and
+"unmatched_waivers" lists. Conflicts suppressed by waivers appear in the
+"waived" list with the justification.
+
+Options:
 `, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
 	}
 }
 
 var (
-	failConflicts = fmt.Errorf("conflicts")
+	format       = flag.String("format", "text", "Output format: text, json or csv.")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	waivers      = flag.String("waivers", "", "Path to a JSON file of approved conflicts. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failConflicts     = fmt.Errorf("conflicts")
 	failNoneRequested = fmt.Errorf("\nNo metadata files requested")
-	failNoLicenses = fmt.Errorf("No licenses")
+	failNoLicenses    = fmt.Errorf("No licenses")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text, json or csv")
 )
 
+type context struct {
+	format       string
+	graphCache   string
+	licenseKinds string
+	waivers      string
+	now          time.Time
+	paths        compliance.PathFlags
+}
 
 // byError orders conflicts by error string
 type byError []compliance.SourceSharePrivacyConflict
@@ -68,10 +121,12 @@ func main() {
 		os.Exit(2)
 	}
 
-	err := checkShare(os.Stdout, os.Stderr, flag.Args()...)
+	ctx := &context{*format, *graphCache, *licenseKinds, *waivers, time.Now(), *paths}
+
+	err := checkShare(ctx, os.Stdout, os.Stderr, flag.Args()...)
 	if err != nil {
 		if err != failConflicts {
-			if err == failNoneRequested {
+			if err == failNoneRequested || err == failBadFormat {
 				flag.Usage()
 			}
 			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
@@ -82,14 +137,29 @@ func main() {
 }
 
 // checkShare implements the checkshare utility.
-func checkShare(stdout, stderr io.Writer, files ...string) error {
+func checkShare(ctx *context, stdout, stderr io.Writer, files ...string) error {
 
 	if len(files) < 1 {
 		return failNoneRequested
 	}
+	switch ctx.format {
+	case "", "text", "json", "csv":
+	default:
+		return failBadFormat
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
 
 	// Read the license graph from the license metadata files (*.meta_lic).
-	licenseGraph, err := compliance.ReadLicenseGraph(os.DirFS("."), stderr, files)
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
 	if err != nil {
 		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
 	}
@@ -97,11 +167,41 @@ func checkShare(stdout, stderr io.Writer, files ...string) error {
 		return failNoLicenses
 	}
 
+	// Read the approved conflicts.
+	var ws []*waiver
+	if len(ctx.waivers) > 0 {
+		ws, err = readWaivers(ctx.waivers)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Apply policy to find conflicts and report them to stderr lexicographically ordered.
-	conflicts := compliance.ConflictingSharedPrivateSource(licenseGraph)
+	conflicts := compliance.ConflictingSharedPrivateSourceWithPolicy(licenseGraph, kinds.Policy())
 	sort.Sort(byError(conflicts))
+	wr := applyWaivers(ctx, ws, conflicts)
+	conflicts = wr.conflicts
+
+	// Output structured formats on stdout, and indicate pass or fail by status only.
+	if ctx.format == "json" || ctx.format == "csv" {
+		if ctx.format == "json" {
+			err = outputJSON(ctx, stdout, wr)
+		} else {
+			outputWaiverProblems(stderr, wr)
+			err = outputCSV(ctx, stdout, wr)
+		}
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return failConflicts
+		}
+		return nil
+	}
+
+	outputWaiverProblems(stderr, wr)
 	for _, conflict := range conflicts {
-		fmt.Fprintln(stderr, conflict.Error())
+		fmt.Fprintln(stderr, newConflictRecord(ctx, conflict).String())
 	}
 
 	// Indicate pass or fail on stdout.
@@ -112,3 +212,224 @@ func checkShare(stdout, stderr io.Writer, files ...string) error {
 	fmt.Fprintln(stdout, "PASS")
 	return nil
 }
+
+// conflictRecord describes a conflict in -format=json or -format=csv output.
+type conflictRecord struct {
+	Source           string `json:"source"`
+	ShareOrigin      string `json:"share_origin"`
+	ShareCondition   string `json:"share_condition"`
+	PrivacyOrigin    string `json:"privacy_origin"`
+	PrivacyCondition string `json:"privacy_condition"`
+}
+
+// newConflictRecord returns the record describing `conflict`.
+func newConflictRecord(ctx *context, conflict compliance.SourceSharePrivacyConflict) conflictRecord {
+	return conflictRecord{
+		Source:           ctx.paths.OutputName(conflict.SourceNode.Name()),
+		ShareOrigin:      ctx.paths.OutputName(conflict.ShareCondition.Origin().Name()),
+		ShareCondition:   conflict.ShareCondition.Name(),
+		PrivacyOrigin:    ctx.paths.OutputName(conflict.PrivacyCondition.Origin().Name()),
+		PrivacyCondition: conflict.PrivacyCondition.Name(),
+	}
+}
+
+// String returns the conflict in the same form as SourceSharePrivacyConflict.Error().
+func (r conflictRecord) String() string {
+	return fmt.Sprintf("%s %s from %s and must share from %s %s\n",
+		r.Source, r.PrivacyCondition, r.PrivacyOrigin, r.ShareCondition, r.ShareOrigin)
+}
+
+// outputJSON writes the pass or fail result, the conflicts and the waivers in `wr` to `stdout` as a JSON object.
+func outputJSON(ctx *context, stdout io.Writer, wr *waiverResult) error {
+	result := struct {
+		Pass             bool             `json:"pass"`
+		Conflicts        []conflictRecord `json:"conflicts"`
+		Waived           []waivedRecord   `json:"waived"`
+		ExpiredWaivers   []*waiver        `json:"expired_waivers"`
+		UnmatchedWaivers []*waiver        `json:"unmatched_waivers"`
+	}{
+		len(wr.conflicts) == 0,
+		make([]conflictRecord, 0, len(wr.conflicts)),
+		make([]waivedRecord, 0, len(wr.waived)),
+		append([]*waiver{}, wr.expired...),
+		append([]*waiver{}, wr.unmatched...),
+	}
+	for _, conflict := range wr.conflicts {
+		result.Conflicts = append(result.Conflicts, newConflictRecord(ctx, conflict))
+	}
+	for _, wc := range wr.waived {
+		result.Waived = append(result.Waived, waivedRecord{newConflictRecord(ctx, wc.conflict), wc.waiver.Justification})
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// outputCSV writes a header row and one row per conflict in `wr`, waived or not, to `stdout`.
+func outputCSV(ctx *context, stdout io.Writer, wr *waiverResult) error {
+	w := csv.NewWriter(stdout)
+	w.Write([]string{"source", "share_origin", "share_condition", "privacy_origin", "privacy_condition", "status", "justification"})
+	write := func(conflict compliance.SourceSharePrivacyConflict, status, justification string) {
+		r := newConflictRecord(ctx, conflict)
+		w.Write([]string{r.Source, r.ShareOrigin, r.ShareCondition, r.PrivacyOrigin, r.PrivacyCondition, status, justification})
+	}
+	for _, conflict := range wr.conflicts {
+		if expired, ok := wr.lapsed[conflict]; ok {
+			write(conflict, "expired", expired.Justification)
+		} else {
+			write(conflict, "conflict", "")
+		}
+	}
+	for _, wc := range wr.waived {
+		write(wc.conflict, "waived", wc.waiver.Justification)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// waiver describes an approved conflict in the -waivers file.
+type waiver struct {
+	Target        string `json:"target"`
+	ShareOrigin   string `json:"share_origin"`
+	PrivacyOrigin string `json:"privacy_origin"`
+	Justification string `json:"justification"`
+	Expires       string `json:"expires,omitempty"`
+
+	// expires is the parsed `Expires` date or the zero time if none.
+	expires time.Time
+}
+
+// String returns a string representation of the waiver.
+func (w *waiver) String() string {
+	return fmt.Sprintf("waiver for %s sharing from %s with privacy from %s", w.Target, w.ShareOrigin, w.PrivacyOrigin)
+}
+
+// matches returns true when `w` approves `conflict`, naming each target either
+// in full or as it appears in the output.
+func (w *waiver) matches(ctx *context, conflict compliance.SourceSharePrivacyConflict) bool {
+	return ctx.sameTarget(w.Target, conflict.SourceNode.Name()) &&
+		ctx.sameTarget(w.ShareOrigin, conflict.ShareCondition.Origin().Name()) &&
+		ctx.sameTarget(w.PrivacyOrigin, conflict.PrivacyCondition.Origin().Name())
+}
+
+// isExpired returns true when `w` no longer applies at time `now`.
+func (w *waiver) isExpired(now time.Time) bool {
+	return !w.expires.IsZero() && !now.Before(w.expires.AddDate(0, 0, 1))
+}
+
+// waivedRecord describes a conflict suppressed by a waiver in -format=json output.
+type waivedRecord struct {
+	conflictRecord
+	Justification string `json:"justification"`
+}
+
+// waivedConflict pairs a conflict with the waiver approving it.
+type waivedConflict struct {
+	conflict compliance.SourceSharePrivacyConflict
+	waiver   *waiver
+}
+
+// waiverResult describes the outcome of applying the waivers to the conflicts.
+type waiverResult struct {
+	// conflicts lists the conflicts not approved by any unexpired waiver.
+	conflicts []compliance.SourceSharePrivacyConflict
+
+	// waived lists the conflicts approved by unexpired waivers.
+	waived []waivedConflict
+
+	// expired lists the waivers past their expiry date.
+	expired []*waiver
+
+	// lapsed maps the conflicts matched only by expired waivers to the first
+	// such waiver.
+	lapsed map[compliance.SourceSharePrivacyConflict]*waiver
+
+	// unmatched lists the unexpired waivers that approve no conflict.
+	unmatched []*waiver
+}
+
+// readWaivers reads and validates the waivers in `path`.
+func readWaivers(path string) ([]*waiver, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read waivers file %q: %w", path, err)
+	}
+	var file struct {
+		Waivers []*waiver `json:"waivers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Unable to parse waivers file %q: %w", path, err)
+	}
+	for i, w := range file.Waivers {
+		if len(w.Target) == 0 || len(w.ShareOrigin) == 0 || len(w.PrivacyOrigin) == 0 {
+			return nil, fmt.Errorf("Invalid waiver %d in %q: want target, share_origin and privacy_origin", i+1, path)
+		}
+		if len(w.Justification) == 0 {
+			return nil, fmt.Errorf("Invalid waiver %d in %q: missing justification", i+1, path)
+		}
+		if len(w.Expires) > 0 {
+			w.expires, err = time.Parse("2006-01-02", w.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid waiver %d in %q: expires %q not a YYYY-MM-DD date", i+1, path, w.Expires)
+			}
+		}
+	}
+	return file.Waivers, nil
+}
+
+// applyWaivers suppresses the `conflicts` approved by `waivers` unexpired at time `ctx.now`.
+func applyWaivers(ctx *context, waivers []*waiver, conflicts []compliance.SourceSharePrivacyConflict) *waiverResult {
+	wr := &waiverResult{lapsed: make(map[compliance.SourceSharePrivacyConflict]*waiver)}
+	active := make([]*waiver, 0, len(waivers))
+	for _, w := range waivers {
+		if w.isExpired(ctx.now) {
+			wr.expired = append(wr.expired, w)
+		} else {
+			active = append(active, w)
+		}
+	}
+	matched := make(map[*waiver]bool)
+	for _, conflict := range conflicts {
+		var approval *waiver
+		for _, w := range active {
+			if w.matches(ctx, conflict) {
+				approval = w
+				matched[w] = true
+				break
+			}
+		}
+		if approval == nil {
+			wr.conflicts = append(wr.conflicts, conflict)
+			for _, w := range wr.expired {
+				if w.matches(ctx, conflict) {
+					wr.lapsed[conflict] = w
+					break
+				}
+			}
+		} else {
+			wr.waived = append(wr.waived, waivedConflict{conflict, approval})
+		}
+	}
+	for _, w := range active {
+		if !matched[w] {
+			wr.unmatched = append(wr.unmatched, w)
+		}
+	}
+	return wr
+}
+
+// outputWaiverProblems reports the expired and unmatched waivers in `wr` to `stderr`.
+func outputWaiverProblems(stderr io.Writer, wr *waiverResult) {
+	for _, w := range wr.expired {
+		fmt.Fprintf(stderr, "%s expired on %s\n", w, w.Expires)
+	}
+	for _, w := range wr.unmatched {
+		fmt.Fprintf(stderr, "%s matches no conflict\n", w)
+	}
+}
+
+// sameTarget returns true when `name` names target `target` either in full or
+// as it appears in the output.
+func (ctx *context) sameTarget(name, target string) bool {
+	return name == target || name == ctx.paths.OutputName(target)
+}
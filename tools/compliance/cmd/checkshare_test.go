@@ -16,9 +16,15 @@ package main
 
 import (
 	"bytes"
+	"compliance"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 type outcome struct {
@@ -248,7 +254,7 @@ func Test(t *testing.T) {
 			for _, r := range tt.roots {
 				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
 			}
-			err := checkShare(stdout, stderr, rootFiles...)
+			err := checkShare(&context{}, stdout, stderr, rootFiles...)
 			if err != nil && err != failConflicts {
 				t.Fatalf("checkshare: error = %v, stderr = %v", err, stderr)
 				return
@@ -297,3 +303,259 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func Test_format(t *testing.T) {
+	tests := []struct {
+		name           string
+		condition      string
+		roots          []string
+		expectedPass   bool
+		expectedRecord []conflictRecord
+		expectedCSV    []string
+	}{
+		{
+			name:           "pass",
+			condition:      "restricted",
+			roots:          []string{"bin/bin2.meta_lic"},
+			expectedPass:   true,
+			expectedRecord: []conflictRecord{},
+			expectedCSV: []string{
+				"source,share_origin,share_condition,privacy_origin,privacy_condition,status,justification",
+			},
+		},
+		{
+			name:         "fail",
+			condition:    "proprietary",
+			roots:        []string{"bin/bin2.meta_lic"},
+			expectedPass: false,
+			expectedRecord: []conflictRecord{
+				{
+					Source:           "testdata/proprietary/bin/bin2.meta_lic",
+					ShareOrigin:      "testdata/proprietary/lib/libb.so.meta_lic",
+					ShareCondition:   "restricted",
+					PrivacyOrigin:    "testdata/proprietary/bin/bin2.meta_lic",
+					PrivacyCondition: "proprietary",
+				},
+			},
+			expectedCSV: []string{
+				"source,share_origin,share_condition,privacy_origin,privacy_condition,status,justification",
+				"testdata/proprietary/bin/bin2.meta_lic,testdata/proprietary/lib/libb.so.meta_lic,restricted,testdata/proprietary/bin/bin2.meta_lic,proprietary,conflict,",
+			},
+		},
+	}
+	for _, tt := range tests {
+		rootFiles := make([]string, 0, len(tt.roots))
+		for _, r := range tt.roots {
+			rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+		}
+		expectedErr := failConflicts
+		if tt.expectedPass {
+			expectedErr = nil
+		}
+		t.Run(tt.name+" json", func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			err := checkShare(&context{format: "json"}, stdout, stderr, rootFiles...)
+			if err != expectedErr {
+				t.Fatalf("checkshare: error = %v, want %v, stderr = %v", err, expectedErr, stderr)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("checkshare: gotStderr = %v, want none", stderr)
+			}
+			var actual struct {
+				Pass      bool             `json:"pass"`
+				Conflicts []conflictRecord `json:"conflicts"`
+			}
+			if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+				t.Fatalf("checkshare: cannot parse %q: %v", stdout, err)
+			}
+			if actual.Pass != tt.expectedPass {
+				t.Errorf("checkshare: got pass %v, want %v", actual.Pass, tt.expectedPass)
+			}
+			if !reflect.DeepEqual(actual.Conflicts, tt.expectedRecord) {
+				t.Errorf("checkshare: got conflicts %v, want %v", actual.Conflicts, tt.expectedRecord)
+			}
+		})
+		t.Run(tt.name+" csv", func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			err := checkShare(&context{format: "csv"}, stdout, stderr, rootFiles...)
+			if err != expectedErr {
+				t.Fatalf("checkshare: error = %v, want %v, stderr = %v", err, expectedErr, stderr)
+			}
+			actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if !reflect.DeepEqual(actual, tt.expectedCSV) {
+				t.Errorf("checkshare: got csv %q, want %q", actual, tt.expectedCSV)
+			}
+		})
+	}
+}
+
+func Test_waivers(t *testing.T) {
+	const (
+		bin2 = "testdata/proprietary/bin/bin2.meta_lic"
+		libb = "testdata/proprietary/lib/libb.so.meta_lic"
+	)
+	now := time.Date(2022, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	// waiverJSON returns a waivers file with one waiver.
+	waiverJSON := func(target, shareOrigin, privacyOrigin, expires string) string {
+		return fmt.Sprintf(`{"waivers": [{"target": %q, "share_origin": %q, "privacy_origin": %q, "justification": "approved", "expires": %q}]}`,
+			target, shareOrigin, privacyOrigin, expires)
+	}
+	tests := []struct {
+		name           string
+		waivers        string
+		expectedErr    error
+		expectedStderr []string
+	}{
+		{
+			name:    "waived",
+			waivers: waiverJSON(bin2, libb, bin2, ""),
+		},
+		{
+			name:    "unexpired",
+			waivers: waiverJSON(bin2, libb, bin2, "2022-06-30"),
+		},
+		{
+			name:        "expired",
+			waivers:     waiverJSON(bin2, libb, bin2, "2022-06-29"),
+			expectedErr: failConflicts,
+			expectedStderr: []string{
+				"waiver for " + bin2 + " sharing from " + libb + " with privacy from " + bin2 + " expired on 2022-06-29",
+				bin2 + " proprietary from " + bin2 + " and must share from restricted " + libb,
+			},
+		},
+		{
+			name:        "unmatched",
+			waivers:     waiverJSON(bin2, bin2, libb, ""),
+			expectedErr: failConflicts,
+			expectedStderr: []string{
+				"waiver for " + bin2 + " sharing from " + bin2 + " with privacy from " + libb + " matches no conflict",
+				bin2 + " proprietary from " + bin2 + " and must share from restricted " + libb,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waivers := filepath.Join(t.TempDir(), "waivers.json")
+			if err := os.WriteFile(waivers, []byte(tt.waivers), 0644); err != nil {
+				t.Fatalf("unable to write waivers: %v", err)
+			}
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			err := checkShare(&context{waivers: waivers, now: now}, stdout, stderr, bin2)
+			if err != tt.expectedErr {
+				t.Fatalf("checkshare: error = %v, want %v, stderr = %v", err, tt.expectedErr, stderr)
+			}
+			actual := make([]string, 0)
+			for _, line := range strings.Split(stderr.String(), "\n") {
+				if len(line) > 0 {
+					actual = append(actual, line)
+				}
+			}
+			if len(tt.expectedStderr) == 0 && len(actual) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(actual, tt.expectedStderr) {
+				t.Errorf("checkshare: got stderr %q, want %q", actual, tt.expectedStderr)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		waivers := filepath.Join(t.TempDir(), "waivers.json")
+		data := `{"waivers": [
+			{"target": "` + bin2 + `", "share_origin": "` + libb + `", "privacy_origin": "` + bin2 + `", "justification": "approved"},
+			{"target": "` + libb + `", "share_origin": "` + libb + `", "privacy_origin": "` + bin2 + `", "justification": "stale", "expires": "2022-01-01"}
+		]}`
+		if err := os.WriteFile(waivers, []byte(data), 0644); err != nil {
+			t.Fatalf("unable to write waivers: %v", err)
+		}
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := checkShare(&context{format: "json", waivers: waivers, now: now}, stdout, stderr, bin2)
+		if err != nil {
+			t.Fatalf("checkshare: error = %v, stderr = %v", err, stderr)
+		}
+		var actual struct {
+			Pass             bool             `json:"pass"`
+			Conflicts        []conflictRecord `json:"conflicts"`
+			Waived           []waivedRecord   `json:"waived"`
+			ExpiredWaivers   []*waiver        `json:"expired_waivers"`
+			UnmatchedWaivers []*waiver        `json:"unmatched_waivers"`
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+			t.Fatalf("checkshare: cannot parse %q: %v", stdout, err)
+		}
+		if !actual.Pass || len(actual.Conflicts) != 0 {
+			t.Errorf("checkshare: got pass %v with conflicts %v, want pass with none", actual.Pass, actual.Conflicts)
+		}
+		expectedWaived := []waivedRecord{{conflictRecord{bin2, libb, "restricted", bin2, "proprietary"}, "approved"}}
+		if !reflect.DeepEqual(actual.Waived, expectedWaived) {
+			t.Errorf("checkshare: got waived %v, want %v", actual.Waived, expectedWaived)
+		}
+		if len(actual.ExpiredWaivers) != 1 || actual.ExpiredWaivers[0].Justification != "stale" {
+			t.Errorf("checkshare: got expired waivers %v, want the stale waiver", actual.ExpiredWaivers)
+		}
+		if len(actual.UnmatchedWaivers) != 0 {
+			t.Errorf("checkshare: got unmatched waivers %v, want none", actual.UnmatchedWaivers)
+		}
+	})
+
+	t.Run("strip_prefix", func(t *testing.T) {
+		// waivers may use the names as output
+		waivers := filepath.Join(t.TempDir(), "waivers.json")
+		if err := os.WriteFile(waivers, []byte(waiverJSON("bin/bin2.meta_lic", "lib/libb.so.meta_lic", "bin/bin2.meta_lic", "")), 0644); err != nil {
+			t.Fatalf("unable to write waivers: %v", err)
+		}
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		ctx := &context{waivers: waivers, now: now, paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}}
+		if err := checkShare(ctx, stdout, stderr, bin2); err != nil {
+			t.Fatalf("checkshare: error = %v, stderr = %v", err, stderr)
+		}
+		if stderr.Len() > 0 {
+			t.Errorf("checkshare: got stderr %q, want none", stderr)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		waivers := filepath.Join(t.TempDir(), "waivers.json")
+		for _, tt := range []struct {
+			expires     string
+			expectedErr error
+			expectedRow string
+		}{
+			{"", nil, "bin/bin2.meta_lic,lib/libb.so.meta_lic,restricted,bin/bin2.meta_lic,proprietary,waived,approved"},
+			{"2022-06-29", failConflicts, "bin/bin2.meta_lic,lib/libb.so.meta_lic,restricted,bin/bin2.meta_lic,proprietary,expired,approved"},
+		} {
+			if err := os.WriteFile(waivers, []byte(waiverJSON(bin2, libb, bin2, tt.expires)), 0644); err != nil {
+				t.Fatalf("unable to write waivers: %v", err)
+			}
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			ctx := &context{format: "csv", waivers: waivers, now: now, paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}}
+			if err := checkShare(ctx, stdout, stderr, bin2); err != tt.expectedErr {
+				t.Fatalf("checkshare: error = %v, want %v, stderr = %v", err, tt.expectedErr, stderr)
+			}
+			expected := []string{"source,share_origin,share_condition,privacy_origin,privacy_condition,status,justification", tt.expectedRow}
+			actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("checkshare: got csv %q, want %q", actual, expected)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		waivers := filepath.Join(t.TempDir(), "waivers.json")
+		data := `{"waivers": [{"target": "` + bin2 + `", "share_origin": "` + libb + `", "privacy_origin": "` + bin2 + `"}]}`
+		if err := os.WriteFile(waivers, []byte(data), 0644); err != nil {
+			t.Fatalf("unable to write waivers: %v", err)
+		}
+		err := checkShare(&context{waivers: waivers, now: now}, &bytes.Buffer{}, &bytes.Buffer{}, bin2)
+		if err == nil || !strings.Contains(err.Error(), "missing justification") {
+			t.Errorf("checkshare: got error %v, want missing justification", err)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"android/soong/compliance/license_metadata_proto"
+)
+
+var (
+	from       = flag.String("from", "", "Input format: text, binary or json. (default from the content)")
+	to         = flag.String("to", "", "Output format: text, binary or json. (default from -o extension or text)")
+	outputFile = flag.String("o", "", "Where to write the converted license metadata. (default stdout)")
+	paths      = compliance.NewInputPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata file requested")
+	failTooMany       = fmt.Errorf("\nExactly one license metadata file may be converted at a time")
+)
+
+type context struct {
+	from       string
+	to         string
+	outputFile string
+	rootFS     fs.FS
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic
+
+Converts a license metadata file between the protobuf text format
+written by the build, the binary protobuf format and the protobuf JSON
+format. Every tool reading license metadata accepts all 3 formats.
+
+The input format comes from -from or else from the content: JSON starts
+with {, text contains only printable characters, and anything else is
+binary. Use -from for binary files that look like text.
+
+The output format comes from -to or else from the -o extension: *.pb or
+*.binpb are binary, *.json are JSON, and anything else is text.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify the file to convert.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rootFS, err := paths.OpenRoot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+
+	ctx := &context{*from, *to, *outputFile, rootFS}
+
+	err = convertMeta(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested || err == failTooMany {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// convertMeta implements the convertmeta utility.
+func convertMeta(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	if len(files) > 1 {
+		return failTooMany
+	}
+
+	format := outputFormat(ctx.outputFile)
+	if len(ctx.to) > 0 {
+		var err error
+		format, err = compliance.ParseMetadataFormat(ctx.to)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := fs.ReadFile(ctx.rootFS, files[0])
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file %q: %w", files[0], err)
+	}
+	inputFormat := compliance.DetectMetadataFormat(data)
+	if len(ctx.from) > 0 {
+		inputFormat, err = compliance.ParseMetadataFormat(ctx.from)
+		if err != nil {
+			return err
+		}
+	}
+	var pb license_metadata_proto.LicenseMetadata
+	err = compliance.UnmarshalLicenseMetadataAs(data, inputFormat, &pb)
+	if err != nil {
+		return fmt.Errorf("Unable to parse license metadata file %q: %w", files[0], err)
+	}
+	converted, err := compliance.MarshalLicenseMetadata(&pb, format)
+	if err != nil {
+		return fmt.Errorf("Unable to convert license metadata file %q: %w", files[0], err)
+	}
+
+	if len(ctx.outputFile) == 0 {
+		_, err = stdout.Write(converted)
+		return err
+	}
+	err = os.WriteFile(ctx.outputFile, converted, 0666)
+	if err != nil {
+		return fmt.Errorf("Unable to write %q: %w", ctx.outputFile, err)
+	}
+	return nil
+}
+
+// outputFormat returns the format implied by the extension of `file`.
+func outputFormat(file string) compliance.MetadataFormat {
+	switch {
+	case strings.HasSuffix(file, ".pb"), strings.HasSuffix(file, ".binpb"):
+		return compliance.BinaryFormat
+	case strings.HasSuffix(file, ".json"):
+		return compliance.JSONFormat
+	}
+	return compliance.TextFormat
+}
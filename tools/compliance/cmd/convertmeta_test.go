@@ -0,0 +1,121 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		name     string
+		to       string
+		output   string
+		expected compliance.MetadataFormat
+	}{
+		{name: "text", to: "text", expected: compliance.TextFormat},
+		{name: "binary", to: "binary", expected: compliance.BinaryFormat},
+		{name: "json", to: "json", expected: compliance.JSONFormat},
+		{name: "default", expected: compliance.TextFormat},
+		{name: "binary extension", output: "bin1.pb", expected: compliance.BinaryFormat},
+		{name: "json extension", output: "bin1.json", expected: compliance.JSONFormat},
+		{name: "explicit overrides extension", to: "text", output: "bin1.json", expected: compliance.TextFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			ctx := &context{to: tt.to, rootFS: os.DirFS(".")}
+			if len(tt.output) > 0 {
+				ctx.outputFile = filepath.Join(t.TempDir(), tt.output)
+			}
+			err := convertMeta(ctx, stdout, stderr, "testdata/restricted/bin/bin1.meta_lic")
+			if err != nil {
+				t.Fatalf("convertmeta: error = %v, stderr = %v", err, stderr)
+			}
+			converted := stdout.Bytes()
+			if len(tt.output) > 0 {
+				if stdout.Len() > 0 {
+					t.Errorf("convertmeta: got stdout %q, want none with -o", stdout)
+				}
+				converted, err = os.ReadFile(ctx.outputFile)
+				if err != nil {
+					t.Fatalf("convertmeta: cannot read output: %v", err)
+				}
+			}
+			if actual := compliance.DetectMetadataFormat(converted); actual != tt.expected {
+				t.Errorf("convertmeta: got %s output, want %s", actual, tt.expected)
+			}
+
+			// converting back to text must reproduce the text conversion
+			roundTrip := &bytes.Buffer{}
+			err = convertMeta(&context{to: "text", rootFS: fstest.MapFS{"bin1.meta_lic": {Data: converted}}}, roundTrip, stderr, "bin1.meta_lic")
+			if err != nil {
+				t.Fatalf("convertmeta: round trip error = %v, stderr = %v", err, stderr)
+			}
+			text := &bytes.Buffer{}
+			err = convertMeta(&context{to: "text", rootFS: os.DirFS(".")}, text, stderr, "testdata/restricted/bin/bin1.meta_lic")
+			if err != nil {
+				t.Fatalf("convertmeta: error = %v, stderr = %v", err, stderr)
+			}
+			if roundTrip.String() != text.String() {
+				t.Errorf("convertmeta: got round trip %q, want %q", roundTrip, text)
+			}
+			forced := &bytes.Buffer{}
+			err = convertMeta(&context{from: tt.expected.String(), to: "text", rootFS: fstest.MapFS{"bin1.meta_lic": {Data: converted}}}, forced, stderr, "bin1.meta_lic")
+			if err != nil {
+				t.Fatalf("convertmeta: forced round trip error = %v, stderr = %v", err, stderr)
+			}
+			if forced.String() != text.String() {
+				t.Errorf("convertmeta: got forced round trip %q, want %q", forced, text)
+			}
+			if !strings.Contains(text.String(), "testdata/restricted/lib/liba.so.meta_lic") {
+				t.Errorf("convertmeta: got %q, want dependencies", text)
+			}
+		})
+	}
+}
+
+func Test_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      *context
+		files    []string
+		expected string
+	}{
+		{"none", &context{rootFS: os.DirFS(".")}, []string{}, failNoneRequested.Error()},
+		{"too many", &context{rootFS: os.DirFS(".")}, []string{"testdata/restricted/bin/bin1.meta_lic", "testdata/restricted/bin/bin2.meta_lic"}, failTooMany.Error()},
+		{"bad format", &context{to: "yaml", rootFS: os.DirFS(".")}, []string{"testdata/restricted/bin/bin1.meta_lic"}, "unknown license metadata format"},
+		{"bad input format", &context{from: "yaml", rootFS: os.DirFS(".")}, []string{"testdata/restricted/bin/bin1.meta_lic"}, "unknown license metadata format"},
+		{"wrong input format", &context{from: "json", rootFS: os.DirFS(".")}, []string{"testdata/restricted/bin/bin1.meta_lic"}, "Unable to parse"},
+		{"missing", &context{rootFS: os.DirFS(".")}, []string{"testdata/restricted/bin/missing.meta_lic"}, "Unable to read"},
+		{"garbled", &context{rootFS: fstest.MapFS{"bad.meta_lic": {Data: []byte("package_name: {\n")}}}, []string{"bad.meta_lic"}, "Unable to parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := convertMeta(tt.ctx, &bytes.Buffer{}, &bytes.Buffer{}, tt.files...)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("convertmeta: got error %v, want %q", err, tt.expected)
+			}
+		})
+	}
+}
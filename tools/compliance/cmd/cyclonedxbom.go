@@ -0,0 +1,139 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	format       = flag.String("format", "json", "Output format: json or xml.")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+)
+
+type context struct {
+	format       string
+	created      time.Time
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Outputs a CycloneDX 1.5 bill of materials describing the license graph
+rooted at the given license metadata files.
+
+Each target becomes a component with its license kinds nested inside
+the container that bundles it. Each target lists the targets it depends
+on in the dependency graph.
+
+Targets distributed either directly or as derivative works have scope
+"required". Other targets, e.g. build tools, have scope "excluded".
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*format, time.Now(), *graphCache, *licenseKinds, *paths}
+
+	err := cycloneDXBOM(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// cycloneDXBOM implements the cyclonedxbom utility.
+func cycloneDXBOM(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	if ctx.format != "json" && ctx.format != "xml" {
+		return fmt.Errorf("Unknown output format %q: want json or xml", ctx.format)
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	bom := compliance.NewCycloneDXBOM(licenseGraph, kinds.Policy(), "cyclonedxbom", ctx.created)
+	stripComponents(ctx, bom.Components)
+	for i := range bom.Dependencies {
+		d := &bom.Dependencies[i]
+		d.Ref = ctx.paths.OutputName(d.Ref)
+		for j := range d.DependsOn {
+			d.DependsOn[j] = ctx.paths.OutputName(d.DependsOn[j])
+		}
+	}
+	if ctx.format == "xml" {
+		return bom.WriteXML(stdout)
+	}
+	return bom.WriteJSON(stdout)
+}
+
+// stripComponents rewrites the target names in `components` and in their
+// nested components as they appear in the output.
+func stripComponents(ctx *context, components []compliance.CycloneDXComponent) {
+	for i := range components {
+		c := &components[i]
+		c.BOMRef = ctx.paths.OutputName(c.BOMRef)
+		c.Name = ctx.paths.OutputName(c.Name)
+		stripComponents(ctx, c.Components)
+	}
+}
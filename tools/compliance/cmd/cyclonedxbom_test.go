@@ -0,0 +1,132 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"sort"
+	"testing"
+	"time"
+)
+
+type component struct {
+	BOMRef     string      `json:"bom-ref" xml:"bom-ref,attr"`
+	Scope      string      `json:"scope" xml:"scope"`
+	Components []component `json:"components" xml:"components>component"`
+}
+
+type bom struct {
+	Components   []component `json:"components" xml:"components>component"`
+	Dependencies []struct {
+		Ref string `json:"ref" xml:"ref,attr"`
+	} `json:"dependencies" xml:"dependencies>dependency"`
+}
+
+// flatten returns `ref scope` strings for every component at every level of nesting.
+func (b *bom) flatten() []string {
+	result := make([]string, 0)
+	var walk func(parent string, components []component)
+	walk = func(parent string, components []component) {
+		for _, c := range components {
+			result = append(result, parent+c.BOMRef+" "+c.Scope)
+			walk(c.BOMRef+" > ", c.Components)
+		}
+	}
+	walk("", b.Components)
+	sort.Strings(result)
+	return result
+}
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition          string
+		name               string
+		roots              []string
+		expectedComponents []string
+	}{
+		{
+			condition: "firstparty",
+			name:      "apex",
+			roots:     []string{"highest.apex.meta_lic"},
+			expectedComponents: []string{
+				"testdata/firstparty/highest.apex.meta_lic > testdata/firstparty/bin/bin1.meta_lic required",
+				"testdata/firstparty/highest.apex.meta_lic > testdata/firstparty/bin/bin2.meta_lic required",
+				"testdata/firstparty/highest.apex.meta_lic > testdata/firstparty/lib/liba.so.meta_lic required",
+				"testdata/firstparty/highest.apex.meta_lic > testdata/firstparty/lib/libb.so.meta_lic required",
+				"testdata/firstparty/highest.apex.meta_lic required",
+				"testdata/firstparty/lib/libc.a.meta_lic required",
+				"testdata/firstparty/lib/libd.so.meta_lic excluded",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "application",
+			roots:     []string{"application.meta_lic"},
+			expectedComponents: []string{
+				"testdata/restricted/application.meta_lic required",
+				"testdata/restricted/bin/bin3.meta_lic excluded",
+				"testdata/restricted/lib/liba.so.meta_lic required",
+				"testdata/restricted/lib/libb.so.meta_lic excluded",
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"json", "xml"} {
+			t.Run(tt.condition+" "+tt.name+" "+format, func(t *testing.T) {
+				stdout := &bytes.Buffer{}
+				stderr := &bytes.Buffer{}
+
+				rootFiles := make([]string, 0, len(tt.roots))
+				for _, r := range tt.roots {
+					rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+				}
+				ctx := &context{format: format, created: time.Unix(0, 0)}
+				err := cycloneDXBOM(ctx, stdout, stderr, rootFiles...)
+				if err != nil {
+					t.Fatalf("cyclonedxbom: error = %v, stderr = %v", err, stderr)
+					return
+				}
+				if stderr.Len() > 0 {
+					t.Errorf("cyclonedxbom: gotStderr = %v, want none", stderr)
+				}
+				var actual bom
+				if format == "json" {
+					err = json.Unmarshal(stdout.Bytes(), &actual)
+				} else {
+					err = xml.Unmarshal(stdout.Bytes(), &actual)
+				}
+				if err != nil {
+					t.Fatalf("cyclonedxbom: unable to decode %s output: %v\n%s", format, err, stdout.String())
+				}
+				actualComponents := actual.flatten()
+				if len(actualComponents) != len(tt.expectedComponents) {
+					t.Errorf("cyclonedxbom: got %d components %v, want %d components %v",
+						len(actualComponents), actualComponents, len(tt.expectedComponents), tt.expectedComponents)
+					return
+				}
+				for i := range actualComponents {
+					if actualComponents[i] != tt.expectedComponents[i] {
+						t.Errorf("cyclonedxbom: unexpected component #%d: got %q, want %q", i+1, actualComponents[i], tt.expectedComponents[i])
+					}
+				}
+				if len(actual.Dependencies) != len(tt.expectedComponents) {
+					t.Errorf("cyclonedxbom: got %d dependencies, want %d", len(actual.Dependencies), len(tt.expectedComponents))
+				}
+			})
+		}
+	}
+}
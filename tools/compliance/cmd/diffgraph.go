@@ -0,0 +1,484 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	oldRoots           = newMultiString("old", "License metadata file of an old root. (may be given multiple times)")
+	newRoots           = newMultiString("new", "License metadata file of a new root. (may be given multiple times)")
+	oldTree            = flag.String("old_tree", ".", "Directory to read the old license metadata from.")
+	newTree            = flag.String("new_tree", ".", "Directory to read the new license metadata from.")
+	oldArchive         = flag.String("old_archive", "", "Zip, tar or tar.gz archive to read the old license metadata from instead of -old_tree. (optional)")
+	newArchive         = flag.String("new_archive", "", "Zip, tar or tar.gz archive to read the new license metadata from instead of -new_tree. (optional)")
+	archiveStripPrefix = flag.String("archive_strip_prefix", "", "Directory prefix to remove from the paths inside -old_archive and -new_archive. (optional)")
+	format             = flag.String("format", "text", "Output format: text or json.")
+	graphCache         = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds       = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths              = compliance.NewOutputPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text or json")
+)
+
+type context struct {
+	oldRoots     []string
+	newRoots     []string
+	oldFS        fs.FS
+	newFS        fs.FS
+	format       string
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} {file.meta_lic...}
+
+Compares the license graph of the old roots read from the old tree with
+the license graph of the new roots read from the new tree, and outputs
+the differences.
+
+Any file.meta_lic arguments become roots of both graphs. Use -old and
+-new to give the roots of each graph separately, and -old_tree and
+-new_tree to compare the same roots in two different build trees. Use
+-old_archive and -new_archive to read either tree from a zip, tar or
+tar.gz archive instead.
+
+Targets and edges match by name, which is the path of the license
+metadata file relative to its tree after any -strip_prefix and
+-rewrite_prefix. e.g. -rewrite_prefix out/target/product/x/=<product>/
+and -rewrite_prefix out/target/product/y/=<product>/ compare products x
+and y.
+
+The output reports added and removed targets and edges, edges with
+changed annotations, targets with changed license kinds or conditions,
+and the added and removed resolutions for source sharing, source
+privacy and notices.
+
+In plain text mode, each line starts with + for added, - for removed or
+~ for changed followed by the kind of difference. When -format=json
+given, outputs a single object with one list per kind of difference.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+// newMultiString creates a flag that allows multiple values in an array.
+func newMultiString(name, usage string) *multiString {
+	var f multiString
+	flag.Var(&f, name, usage)
+	return &f
+}
+
+// multiString implements the flag `Value` interface for multiple strings.
+type multiString []string
+
+func (ms *multiString) String() string     { return strings.Join(*ms, ", ") }
+func (ms *multiString) Set(s string) error { *ms = append(*ms, s); return nil }
+
+func main() {
+	flag.Parse()
+
+	ctx := &context{
+		oldRoots:     append(append([]string{}, *oldRoots...), flag.Args()...),
+		newRoots:     append(append([]string{}, *newRoots...), flag.Args()...),
+		oldFS:        os.DirFS(*oldTree),
+		newFS:        os.DirFS(*newTree),
+		format:       *format,
+		graphCache:   *graphCache,
+		licenseKinds: *licenseKinds,
+		paths:        *paths,
+	}
+	for _, a := range []struct {
+		archive string
+		rootFS  *fs.FS
+	}{{*oldArchive, &ctx.oldFS}, {*newArchive, &ctx.newFS}} {
+		if len(a.archive) == 0 {
+			continue
+		}
+		afs, err := compliance.OpenArchiveFS(a.archive, *archiveStripPrefix)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			os.Exit(1)
+		}
+		*a.rootFS = afs
+	}
+
+	err := diffGraph(ctx, os.Stdout, os.Stderr)
+	if err != nil {
+		if err == failNoneRequested || err == failBadFormat {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// graphDiff describes the differences between 2 license graphs.
+type graphDiff struct {
+	AddedTargets       []string            `json:"added_targets"`
+	RemovedTargets     []string            `json:"removed_targets"`
+	AddedEdges         []edgeRecord        `json:"added_edges"`
+	RemovedEdges       []edgeRecord        `json:"removed_edges"`
+	ChangedAnnotations []annotationsChange `json:"changed_annotations"`
+	ChangedTargets     []targetChange      `json:"changed_targets"`
+	SourceSharing      resolutionsDiff     `json:"source_sharing"`
+	SourcePrivacy      resolutionsDiff     `json:"source_privacy"`
+	Notices            resolutionsDiff     `json:"notices"`
+}
+
+// edgeRecord describes an edge.
+type edgeRecord struct {
+	Target      string   `json:"target"`
+	Dependency  string   `json:"dependency"`
+	Annotations []string `json:"annotations"`
+}
+
+// annotationsChange describes an edge whose annotations changed.
+type annotationsChange struct {
+	Target         string   `json:"target"`
+	Dependency     string   `json:"dependency"`
+	OldAnnotations []string `json:"old_annotations"`
+	NewAnnotations []string `json:"new_annotations"`
+}
+
+// targetChange describes a target whose license kinds or conditions changed.
+type targetChange struct {
+	Target        string   `json:"target"`
+	OldKinds      []string `json:"old_kinds"`
+	NewKinds      []string `json:"new_kinds"`
+	OldConditions []string `json:"old_conditions"`
+	NewConditions []string `json:"new_conditions"`
+}
+
+// resolutionsDiff describes the resolutions added to and removed from a resolution set.
+type resolutionsDiff struct {
+	Added   []resolutionRecord `json:"added"`
+	Removed []resolutionRecord `json:"removed"`
+}
+
+// resolutionRecord describes a single resolved license condition.
+type resolutionRecord struct {
+	AttachesTo string `json:"attaches_to"`
+	ActsOn     string `json:"acts_on"`
+	Origin     string `json:"origin"`
+	Condition  string `json:"condition"`
+}
+
+// String returns a string representation of the resolution.
+func (r resolutionRecord) String() string {
+	return fmt.Sprintf("%s %s %s:%s", r.AttachesTo, r.ActsOn, r.Origin, r.Condition)
+}
+
+// isEmpty returns true when there are no differences.
+func (d *graphDiff) isEmpty() bool {
+	return len(d.AddedTargets) == 0 && len(d.RemovedTargets) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 &&
+		len(d.ChangedAnnotations) == 0 && len(d.ChangedTargets) == 0 &&
+		d.SourceSharing.isEmpty() && d.SourcePrivacy.isEmpty() && d.Notices.isEmpty()
+}
+
+// isEmpty returns true when no resolutions were added or removed.
+func (d resolutionsDiff) isEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// diffGraph implements the diffgraph utility.
+func diffGraph(ctx *context, stdout, stderr io.Writer) error {
+	if len(ctx.oldRoots) < 1 || len(ctx.newRoots) < 1 {
+		return failNoneRequested
+	}
+	switch ctx.format {
+	case "", "text", "json":
+	default:
+		return failBadFormat
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	// Read the license graphs from the license metadata files (*.meta_lic).
+	oldGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(ctx.oldFS, stderr, ctx.oldRoots, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read old license metadata file(s) %q: %w\n", ctx.oldRoots, err)
+	}
+	newGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(ctx.newFS, stderr, ctx.newRoots, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read new license metadata file(s) %q: %w\n", ctx.newRoots, err)
+	}
+	if oldGraph == nil || newGraph == nil {
+		return failNoLicenses
+	}
+
+	d, err := diffGraphs(ctx, kinds.Policy(), oldGraph, newGraph)
+	if err != nil {
+		return err
+	}
+
+	if ctx.format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	outputText(stdout, d)
+	return nil
+}
+
+// diffGraphs returns the differences from `oldGraph` to `newGraph` with every list sorted.
+func diffGraphs(ctx *context, policy compliance.Policy, oldGraph, newGraph *compliance.LicenseGraph) (*graphDiff, error) {
+	d := &graphDiff{
+		AddedTargets:       []string{},
+		RemovedTargets:     []string{},
+		AddedEdges:         []edgeRecord{},
+		RemovedEdges:       []edgeRecord{},
+		ChangedAnnotations: []annotationsChange{},
+		ChangedTargets:     []targetChange{},
+	}
+
+	// Compare the targets.
+	oldTargets, oldNames, err := targetsByName(ctx, oldGraph)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to compare old license graph: %w", err)
+	}
+	newTargets, newNames, err := targetsByName(ctx, newGraph)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to compare new license graph: %w", err)
+	}
+	for _, name := range oldNames {
+		if _, ok := newTargets[name]; !ok {
+			d.RemovedTargets = append(d.RemovedTargets, name)
+		}
+	}
+	for _, name := range newNames {
+		newTarget := newTargets[name]
+		oldTarget, ok := oldTargets[name]
+		if !ok {
+			d.AddedTargets = append(d.AddedTargets, name)
+			continue
+		}
+		oldKinds, newKinds := sortedStrings(oldTarget.LicenseKinds()), sortedStrings(newTarget.LicenseKinds())
+		oldConditions, newConditions := sortedStrings(oldTarget.LicenseConditions().Names()), sortedStrings(newTarget.LicenseConditions().Names())
+		if !sameStrings(oldKinds, newKinds) || !sameStrings(oldConditions, newConditions) {
+			d.ChangedTargets = append(d.ChangedTargets, targetChange{name, oldKinds, newKinds, oldConditions, newConditions})
+		}
+	}
+
+	// Compare the edges.
+	oldEdges, oldKeys := edgesByName(ctx, oldGraph)
+	newEdges, newKeys := edgesByName(ctx, newGraph)
+	for _, key := range oldKeys {
+		if _, ok := newEdges[key]; !ok {
+			d.RemovedEdges = append(d.RemovedEdges, oldEdges[key])
+		}
+	}
+	for _, key := range newKeys {
+		newEdge := newEdges[key]
+		oldEdge, ok := oldEdges[key]
+		if !ok {
+			d.AddedEdges = append(d.AddedEdges, newEdge)
+			continue
+		}
+		if !sameStrings(oldEdge.Annotations, newEdge.Annotations) {
+			d.ChangedAnnotations = append(d.ChangedAnnotations, annotationsChange{newEdge.Target, newEdge.Dependency, oldEdge.Annotations, newEdge.Annotations})
+		}
+	}
+
+	// Compare the resolutions.
+	d.SourceSharing = diffResolutions(ctx, compliance.ResolveSourceSharingWithPolicy(oldGraph, policy), compliance.ResolveSourceSharingWithPolicy(newGraph, policy))
+	d.SourcePrivacy = diffResolutions(ctx, compliance.ResolveSourcePrivacyWithPolicy(oldGraph, policy), compliance.ResolveSourcePrivacyWithPolicy(newGraph, policy))
+	d.Notices = diffResolutions(ctx, compliance.ResolveNoticesWithPolicy(oldGraph, policy), compliance.ResolveNoticesWithPolicy(newGraph, policy))
+
+	return d, nil
+}
+
+// targetsByName maps the names of the targets in `lg` to the targets, and
+// returns the sorted names.
+//
+// Returns an error when -strip_prefix and -rewrite_prefix give 2 targets the
+// same name.
+func targetsByName(ctx *context, lg *compliance.LicenseGraph) (map[string]*compliance.TargetNode, []string, error) {
+	result := make(map[string]*compliance.TargetNode)
+	names := make([]string, 0)
+	for _, tn := range lg.Targets() {
+		name := ctx.paths.OutputName(tn.Name())
+		if other, ok := result[name]; ok {
+			return nil, nil, fmt.Errorf("targets %q and %q both appear as %q", other.Name(), tn.Name(), name)
+		}
+		result[name] = tn
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return result, names, nil
+}
+
+// edgesByName maps "target dependency" name pairs to the edges in `lg`, and
+// returns the sorted pairs.
+//
+// Multiple edges between the same pair merge into 1 record with the union of
+// their annotations.
+func edgesByName(ctx *context, lg *compliance.LicenseGraph) (map[string]edgeRecord, []string) {
+	result := make(map[string]edgeRecord)
+	keys := make([]string, 0)
+	for _, e := range lg.Edges() {
+		key := ctx.paths.OutputName(e.Target().Name()) + " " + ctx.paths.OutputName(e.Dependency().Name())
+		annotations := e.Annotations().AsList()
+		if record, ok := result[key]; ok {
+			annotations = append(annotations, record.Annotations...)
+		} else {
+			keys = append(keys, key)
+		}
+		result[key] = edgeRecord{
+			ctx.paths.OutputName(e.Target().Name()),
+			ctx.paths.OutputName(e.Dependency().Name()),
+			sortedStrings(uniqueStrings(annotations)),
+		}
+	}
+	sort.Strings(keys)
+	return result, keys
+}
+
+// uniqueStrings returns `s` without duplicates.
+func uniqueStrings(s []string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(s))
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// diffResolutions returns the resolutions in `newRs` but not `oldRs` as added and vice versa as removed.
+func diffResolutions(ctx *context, oldRs, newRs *compliance.ResolutionSet) resolutionsDiff {
+	oldRecords, oldKeys := resolutionRecords(ctx, oldRs)
+	newRecords, newKeys := resolutionRecords(ctx, newRs)
+	d := resolutionsDiff{[]resolutionRecord{}, []resolutionRecord{}}
+	for _, key := range newKeys {
+		if _, ok := oldRecords[key]; !ok {
+			d.Added = append(d.Added, newRecords[key])
+		}
+	}
+	for _, key := range oldKeys {
+		if _, ok := newRecords[key]; !ok {
+			d.Removed = append(d.Removed, oldRecords[key])
+		}
+	}
+	return d
+}
+
+// resolutionRecords maps the string representation of each resolved condition
+// in `rs` to its record, and returns the sorted string representations.
+func resolutionRecords(ctx *context, rs *compliance.ResolutionSet) (map[string]resolutionRecord, []string) {
+	result := make(map[string]resolutionRecord)
+	keys := make([]string, 0)
+	for _, target := range rs.AttachesTo() {
+		for _, r := range rs.Resolutions(target) {
+			for _, lc := range r.Resolves().AsList() {
+				record := resolutionRecord{ctx.paths.OutputName(target.Name()), ctx.paths.OutputName(r.ActsOn().Name()), ctx.paths.OutputName(lc.Origin().Name()), lc.Name()}
+				if _, ok := result[record.String()]; !ok {
+					keys = append(keys, record.String())
+				}
+				result[record.String()] = record
+			}
+		}
+	}
+	sort.Strings(keys)
+	return result, keys
+}
+
+// outputText writes the human-readable differences in `d` to `stdout`.
+func outputText(stdout io.Writer, d *graphDiff) {
+	if d.isEmpty() {
+		fmt.Fprintln(stdout, "no differences")
+		return
+	}
+	for _, name := range d.AddedTargets {
+		fmt.Fprintf(stdout, "+ target %s\n", name)
+	}
+	for _, name := range d.RemovedTargets {
+		fmt.Fprintf(stdout, "- target %s\n", name)
+	}
+	for _, c := range d.ChangedTargets {
+		if !sameStrings(c.OldKinds, c.NewKinds) {
+			fmt.Fprintf(stdout, "~ kinds %s [%s] => [%s]\n", c.Target, strings.Join(c.OldKinds, ":"), strings.Join(c.NewKinds, ":"))
+		}
+		if !sameStrings(c.OldConditions, c.NewConditions) {
+			fmt.Fprintf(stdout, "~ conditions %s [%s] => [%s]\n", c.Target, strings.Join(c.OldConditions, ":"), strings.Join(c.NewConditions, ":"))
+		}
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(stdout, "+ edge %s -> %s [%s]\n", e.Target, e.Dependency, strings.Join(e.Annotations, ":"))
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(stdout, "- edge %s -> %s [%s]\n", e.Target, e.Dependency, strings.Join(e.Annotations, ":"))
+	}
+	for _, c := range d.ChangedAnnotations {
+		fmt.Fprintf(stdout, "~ edge %s -> %s [%s] => [%s]\n", c.Target, c.Dependency, strings.Join(c.OldAnnotations, ":"), strings.Join(c.NewAnnotations, ":"))
+	}
+	outputResolutions(stdout, "share", d.SourceSharing)
+	outputResolutions(stdout, "privacy", d.SourcePrivacy)
+	outputResolutions(stdout, "notice", d.Notices)
+}
+
+// outputResolutions writes the added and removed resolutions in `d` labelled `label` to `stdout`.
+func outputResolutions(stdout io.Writer, label string, d resolutionsDiff) {
+	for _, r := range d.Added {
+		fmt.Fprintf(stdout, "+ %s %s\n", label, r)
+	}
+	for _, r := range d.Removed {
+		fmt.Fprintf(stdout, "- %s %s\n", label, r)
+	}
+}
+
+// sortedStrings returns a sorted copy of `s`.
+func sortedStrings(s []string) []string {
+	result := append([]string{}, s...)
+	sort.Strings(result)
+	return result
+}
+
+// sameStrings returns true when `s1` and `s2` have the same elements in the same order.
+func sameStrings(s1, s2 []string) bool {
+	if len(s1) != len(s2) {
+		return false
+	}
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,306 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const (
+	// apache is the license metadata for an Apache-licensed target.
+	apache = "license_kinds: \"SPDX-license-identifier-Apache-2.0\"\nlicense_conditions: \"notice\"\n"
+
+	// gpl is the license metadata for a GPL-licensed target.
+	gpl = "license_kinds: \"SPDX-license-identifier-GPL-2.0\"\nlicense_conditions: \"restricted\"\n"
+
+	// proprietary is the license metadata for a proprietary target.
+	proprietary = "license_kinds: \"legacy_proprietary\"\nlicense_conditions: \"proprietary\"\n"
+)
+
+// dep returns the license metadata for a dependency on `file` with `annotations`.
+func dep(file string, annotations ...string) string {
+	s := "deps: {\n  file: \"" + file + "\"\n"
+	for _, a := range annotations {
+		s += "  annotations: \"" + a + "\"\n"
+	}
+	return s + "}\n"
+}
+
+// tree returns a test file system with license metadata `files`.
+func tree(files map[string]string) fstest.MapFS {
+	result := make(fstest.MapFS)
+	for name, data := range files {
+		result[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+	return result
+}
+
+func Test(t *testing.T) {
+	oldTree := tree(map[string]string{
+		"bin.meta_lic":  apache + dep("liba.meta_lic", "static") + dep("libb.meta_lic", "dynamic"),
+		"liba.meta_lic": apache,
+		"libb.meta_lic": apache,
+	})
+	newTree := tree(map[string]string{
+		"bin.meta_lic":  proprietary + dep("liba.meta_lic", "static") + dep("libc.meta_lic", "static"),
+		"liba.meta_lic": gpl,
+		"libc.meta_lic": apache,
+	})
+	tests := []struct {
+		name        string
+		oldTree     fstest.MapFS
+		newTree     fstest.MapFS
+		expectedOut []string
+	}{
+		{
+			name:        "same",
+			oldTree:     oldTree,
+			newTree:     oldTree,
+			expectedOut: []string{"no differences"},
+		},
+		{
+			name:    "changed",
+			oldTree: oldTree,
+			newTree: newTree,
+			expectedOut: []string{
+				"+ target libc.meta_lic",
+				"- target libb.meta_lic",
+				"~ kinds bin.meta_lic [SPDX-license-identifier-Apache-2.0] => [legacy_proprietary]",
+				"~ conditions bin.meta_lic [notice] => [proprietary]",
+				"~ kinds liba.meta_lic [SPDX-license-identifier-Apache-2.0] => [SPDX-license-identifier-GPL-2.0]",
+				"~ conditions liba.meta_lic [notice] => [restricted]",
+				"+ edge bin.meta_lic -> libc.meta_lic [static]",
+				"- edge bin.meta_lic -> libb.meta_lic [dynamic]",
+				"+ share bin.meta_lic bin.meta_lic liba.meta_lic:restricted",
+				"+ share bin.meta_lic liba.meta_lic liba.meta_lic:restricted",
+				"+ share bin.meta_lic libc.meta_lic liba.meta_lic:restricted",
+				"+ privacy bin.meta_lic bin.meta_lic bin.meta_lic:proprietary",
+				"+ notice bin.meta_lic bin.meta_lic bin.meta_lic:proprietary",
+				"+ notice bin.meta_lic bin.meta_lic liba.meta_lic:restricted",
+				"+ notice bin.meta_lic liba.meta_lic liba.meta_lic:restricted",
+				"+ notice bin.meta_lic libc.meta_lic liba.meta_lic:restricted",
+				"+ notice bin.meta_lic libc.meta_lic libc.meta_lic:notice",
+				"- notice bin.meta_lic bin.meta_lic bin.meta_lic:notice",
+				"- notice bin.meta_lic liba.meta_lic liba.meta_lic:notice",
+			},
+		},
+		{
+			name:    "annotations",
+			oldTree: oldTree,
+			newTree: tree(map[string]string{
+				"bin.meta_lic":  apache + dep("liba.meta_lic", "dynamic") + dep("libb.meta_lic", "dynamic"),
+				"liba.meta_lic": apache,
+				"libb.meta_lic": apache,
+			}),
+			expectedOut: []string{
+				"~ edge bin.meta_lic -> liba.meta_lic [static] => [dynamic]",
+				"- notice bin.meta_lic liba.meta_lic liba.meta_lic:notice",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			ctx := &context{[]string{"bin.meta_lic"}, []string{"bin.meta_lic"}, tt.oldTree, tt.newTree, "text", "", "", compliance.PathFlags{}}
+			err := diffGraph(ctx, stdout, stderr)
+			if err != nil {
+				t.Fatalf("diffgraph: error = %v, stderr = %v", err, stderr)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("diffgraph: gotStderr = %v, want none", stderr)
+			}
+			actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("diffgraph: got stdout %v, want %v", strings.Join(actual, "\n"), strings.Join(tt.expectedOut, "\n"))
+			}
+		})
+	}
+}
+
+func Test_json(t *testing.T) {
+	oldTree := tree(map[string]string{
+		"bin.meta_lic": apache + dep("lib.meta_lic", "static"),
+		"lib.meta_lic": apache,
+	})
+	newTree := tree(map[string]string{
+		"bin.meta_lic": apache + dep("lib.meta_lic", "static"),
+		"lib.meta_lic": gpl,
+	})
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{[]string{"bin.meta_lic"}, []string{"bin.meta_lic"}, oldTree, newTree, "json", "", "", compliance.PathFlags{}}
+	err := diffGraph(ctx, stdout, stderr)
+	if err != nil {
+		t.Fatalf("diffgraph: error = %v, stderr = %v", err, stderr)
+	}
+	var actual graphDiff
+	if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+		t.Fatalf("diffgraph: cannot parse %q: %v", stdout, err)
+	}
+	expected := graphDiff{
+		AddedTargets:       []string{},
+		RemovedTargets:     []string{},
+		AddedEdges:         []edgeRecord{},
+		RemovedEdges:       []edgeRecord{},
+		ChangedAnnotations: []annotationsChange{},
+		ChangedTargets: []targetChange{
+			{
+				Target:        "lib.meta_lic",
+				OldKinds:      []string{"SPDX-license-identifier-Apache-2.0"},
+				NewKinds:      []string{"SPDX-license-identifier-GPL-2.0"},
+				OldConditions: []string{"notice"},
+				NewConditions: []string{"restricted"},
+			},
+		},
+		SourceSharing: resolutionsDiff{
+			Added: []resolutionRecord{
+				{"bin.meta_lic", "bin.meta_lic", "lib.meta_lic", "restricted"},
+				{"bin.meta_lic", "lib.meta_lic", "lib.meta_lic", "restricted"},
+			},
+			Removed: []resolutionRecord{},
+		},
+		SourcePrivacy: resolutionsDiff{[]resolutionRecord{}, []resolutionRecord{}},
+		Notices: resolutionsDiff{
+			Added: []resolutionRecord{
+				{"bin.meta_lic", "bin.meta_lic", "lib.meta_lic", "restricted"},
+				{"bin.meta_lic", "lib.meta_lic", "lib.meta_lic", "restricted"},
+			},
+			Removed: []resolutionRecord{
+				{"bin.meta_lic", "lib.meta_lic", "lib.meta_lic", "notice"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("diffgraph: got %+v, want %+v", actual, expected)
+	}
+}
+
+func Test_roots(t *testing.T) {
+	// the same tree with different roots
+	rootFS := os.DirFS(".")
+	ctx := &context{
+		[]string{"testdata/notice/bin/bin1.meta_lic"},
+		[]string{"testdata/notice/bin/bin1.meta_lic", "testdata/notice/bin/bin2.meta_lic"},
+		rootFS, rootFS, "text", "", "", compliance.PathFlags{},
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	err := diffGraph(ctx, stdout, stderr)
+	if err != nil {
+		t.Fatalf("diffgraph: error = %v, stderr = %v", err, stderr)
+	}
+	expected := []string{
+		"+ target testdata/notice/bin/bin2.meta_lic",
+		"+ target testdata/notice/lib/libb.so.meta_lic",
+		"+ target testdata/notice/lib/libd.so.meta_lic",
+		"+ edge testdata/notice/bin/bin2.meta_lic -> testdata/notice/lib/libb.so.meta_lic [dynamic]",
+		"+ edge testdata/notice/bin/bin2.meta_lic -> testdata/notice/lib/libd.so.meta_lic [dynamic]",
+	}
+	actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	for i := 0; i < len(expected); i++ {
+		if i >= len(actual) || actual[i] != expected[i] {
+			t.Fatalf("diffgraph: got stdout %v, want starting %v", strings.Join(actual, "\n"), strings.Join(expected, "\n"))
+		}
+	}
+	for _, line := range actual[len(expected):] {
+		if !strings.HasPrefix(line, "+ notice testdata/notice/bin/bin2.meta_lic ") &&
+			!strings.HasPrefix(line, "+ notice testdata/notice/lib/libb.so.meta_lic ") &&
+			!strings.HasPrefix(line, "+ notice testdata/notice/lib/libd.so.meta_lic ") {
+			t.Errorf("diffgraph: unexpected line %q, want only added notices for bin2", line)
+		}
+	}
+}
+
+func Test_rewrite(t *testing.T) {
+	// the same roots in 2 trees compare equal after rewriting the tree prefixes
+	rootFS := os.DirFS(".")
+	ctx := &context{
+		[]string{"testdata/notice/bin/bin1.meta_lic"},
+		[]string{"testdata/reciprocal/bin/bin1.meta_lic"},
+		rootFS, rootFS, "text", "", "",
+		compliance.PathFlags{StripPrefix: "testdata/", Rewrites: compliance.PrefixRewrites{"notice/": "<tree>/", "reciprocal/": "<tree>/"}},
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	err := diffGraph(ctx, stdout, stderr)
+	if err != nil {
+		t.Fatalf("diffgraph: error = %v, stderr = %v", err, stderr)
+	}
+	changed := false
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		if strings.Contains(line, " target ") || strings.Contains(line, " edge ") {
+			t.Errorf("diffgraph: got %q, want no added or removed targets or edges", line)
+		}
+		if strings.Contains(line, "testdata/") {
+			t.Errorf("diffgraph: got %q, want names rewritten", line)
+		}
+		if strings.HasPrefix(line, "~ conditions <tree>/") {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Errorf("diffgraph: got %q, want changed conditions for <tree>/ targets", stdout.String())
+	}
+}
+
+func Test_duplicateEdges(t *testing.T) {
+	// 2 edges between the same targets appear once with all the annotations
+	oldTree := tree(map[string]string{
+		"bin.meta_lic": apache,
+	})
+	newTree := tree(map[string]string{
+		"bin.meta_lic":  apache + dep("liba.meta_lic", "static") + dep("liba.meta_lic", "dynamic"),
+		"liba.meta_lic": apache,
+	})
+	ctx := &context{[]string{"bin.meta_lic"}, []string{"bin.meta_lic"}, oldTree, newTree, "text", "", "", compliance.PathFlags{}}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if err := diffGraph(ctx, stdout, stderr); err != nil {
+		t.Fatalf("diffgraph: error = %v, stderr = %v", err, stderr)
+	}
+	edges := make([]string, 0)
+	for _, line := range strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n") {
+		if strings.Contains(line, " edge ") {
+			edges = append(edges, line)
+		}
+	}
+	expected := []string{"+ edge bin.meta_lic -> liba.meta_lic [dynamic:static]"}
+	if !reflect.DeepEqual(edges, expected) {
+		t.Errorf("diffgraph: got edges %q, want %q", edges, expected)
+	}
+}
+
+func Test_rewriteCollision(t *testing.T) {
+	// rewriting 2 targets in the same graph to 1 name is an error
+	rootFS := os.DirFS(".")
+	ctx := &context{
+		[]string{"testdata/notice/bin/bin1.meta_lic", "testdata/reciprocal/bin/bin1.meta_lic"},
+		[]string{"testdata/notice/bin/bin1.meta_lic"},
+		rootFS, rootFS, "text", "", "",
+		compliance.PathFlags{StripPrefix: "testdata/", Rewrites: compliance.PrefixRewrites{"notice/": "<tree>/", "reciprocal/": "<tree>/"}},
+	}
+	err := diffGraph(ctx, &bytes.Buffer{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "both appear as") {
+		t.Errorf("diffgraph: got error %v, want both appear as", err)
+	}
+}
@@ -16,6 +16,9 @@ package main
 
 import (
 	"compliance"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -26,18 +29,27 @@ import (
 )
 
 var (
+	format          = flag.String("format", "text", "Output format: text, json or csv.")
 	graphViz        = flag.Bool("dot", false, "Whether to output graphviz (i.e. dot) format.")
 	labelConditions = flag.Bool("label_conditions", false, "Whether to label target nodes with conditions.")
-	stripPrefix     = flag.String("strip_prefix", "", "Prefix to remove from paths. i.e. path to root")
+	graphCache      = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds    = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	tolerate        = flag.Bool("tolerate_read_errors", false, "Whether to dump the rest of the graph when license metadata files fail to read.")
+	paths           = compliance.NewPathFlags(flag.CommandLine)
 
 	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
 	failNoLicenses = fmt.Errorf("No licenses found")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text, json or csv, and -dot requires text")
 )
 
 type context struct {
+	format          string
 	graphViz        bool
 	labelConditions bool
-	stripPrefix     string
+	graphCache      string
+	licenseKinds    string
+	tolerate        bool
+	paths           compliance.PathFlags
 }
 
 func init() {
@@ -53,6 +65,17 @@ e.g. multiple annotations appear as annotation1:annotation2:annotation3
 or when -label_conditions is requested, Target and Dependency become
 target:condition1:condition2 etc.
 
+When -format=json given, outputs an object with a "targets" list of
+{"name", "conditions"} objects and an "edges" list of {"target",
+"dependency", "annotations"} objects. When -format=csv given, outputs a
+header row followed by one target,dependency,annotations,
+target_conditions,dependency_conditions row per edge with multiple
+values colon-separated. Both always include the conditions.
+
+When -tolerate_read_errors given, license metadata files that fail to
+read appear as targets without conditions, and the failures get reported
+after the rest of the graph.
+
 Options:
 `, filepath.Base(os.Args[0]))
 		flag.PrintDefaults()
@@ -68,11 +91,11 @@ func main() {
 		os.Exit(2)
 	}
 
-	ctx := &context{*graphViz, *labelConditions, *stripPrefix}
+	ctx := &context{*format, *graphViz, *labelConditions, *graphCache, *licenseKinds, *tolerate, *paths}
 
 	err := dumpGraph(ctx, os.Stdout, os.Stderr, flag.Args()...)
 	if err != nil {
-		if err == failNoneRequested {
+		if err == failNoneRequested || err == failBadFormat {
 			flag.Usage()
 		}
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
@@ -82,31 +105,67 @@ func main() {
 }
 
 // dumpGraph implements the dumpgraph utility.
-func dumpGraph(ctx *context, stdout, stderr io.Writer, files ...string) error {
+func dumpGraph(ctx *context, stdout, stderr io.Writer, files ...string) (err error) {
 	if len(files) < 1 {
 		return failNoneRequested
 	}
+	switch ctx.format {
+	case "", "text":
+	case "json", "csv":
+		if ctx.graphViz {
+			return failBadFormat
+		}
+	default:
+		return failBadFormat
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
 
 	// Read the license graph from the license metadata files (*.meta_lic).
-	licenseGraph, err := compliance.ReadLicenseGraph(os.DirFS("."), stderr, files)
+	opts := compliance.ReadOptions{Kinds: kinds, CacheFile: ctx.graphCache, TolerateReadErrors: ctx.tolerate}
+	rootFS, err := ctx.paths.OpenRoot()
 	if err != nil {
+		return err
+	}
+
+	licenseGraph, err := compliance.ReadLicenseGraphWithOptions(rootFS, stderr, files, opts)
+	var readErrors compliance.ReadErrors
+	if err != nil && (!ctx.tolerate || !errors.As(err, &readErrors)) {
 		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
 	}
 	if licenseGraph == nil {
 		return failNoLicenses
 	}
+	if len(readErrors) > 0 {
+		// report the failures after dumping the rest of the graph
+		defer func() {
+			if err == nil {
+				err = fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, readErrors)
+			}
+		}()
+	}
 
 	// Sort the edges of the graph.
 	edges := licenseGraph.Edges()
 	sort.Sort(edges)
 
+	switch ctx.format {
+	case "json":
+		return outputJSON(ctx, stdout, licenseGraph.Targets(), edges)
+	case "csv":
+		return outputCSV(ctx, stdout, edges)
+	}
+
 	// nodes maps license metadata file names to graphViz node names when ctx.graphViz is true.
 	var nodes map[string]string
 	n := 0
 
 	// targetOut calculates the string to output for `target` separating conditions as needed using `sep`.
 	targetOut := func(target *compliance.TargetNode, sep string) string {
-		tOut := strings.TrimPrefix(target.Name(), ctx.stripPrefix)
+		tOut := ctx.paths.OutputName(target.Name())
 		if ctx.labelConditions {
 			conditions := target.LicenseConditions().Names()
 			sort.Strings(conditions)
@@ -164,10 +223,7 @@ func dumpGraph(ctx *context, stdout, stderr io.Writer, files ...string) error {
 	if ctx.graphViz {
 		fmt.Fprintf(stdout, "\t{rank=same;")
 		for _, f := range files {
-			fName := f
-			if !strings.HasSuffix(fName, ".meta_lic") {
-				fName += ".meta_lic"
-			}
+			fName := compliance.MetadataFileName(f)
 			if fNode, ok := nodes[fName]; ok {
 				fmt.Fprintf(stdout, " %s", fNode)
 			}
@@ -176,3 +232,62 @@ func dumpGraph(ctx *context, stdout, stderr io.Writer, files ...string) error {
 	}
 	return nil
 }
+
+// jsonTarget describes a target node in -format=json output.
+type jsonTarget struct {
+	Name       string   `json:"name"`
+	Conditions []string `json:"conditions"`
+}
+
+// jsonEdge describes an edge in -format=json output.
+type jsonEdge struct {
+	Target      string   `json:"target"`
+	Dependency  string   `json:"dependency"`
+	Annotations []string `json:"annotations"`
+}
+
+// outputJSON writes the sorted `targets` and `edges` to `stdout` as a JSON object.
+func outputJSON(ctx *context, stdout io.Writer, targets compliance.TargetNodeList, edges compliance.TargetEdgeList) error {
+	sort.Sort(targets)
+	graph := struct {
+		Targets []jsonTarget `json:"targets"`
+		Edges   []jsonEdge   `json:"edges"`
+	}{make([]jsonTarget, 0, len(targets)), make([]jsonEdge, 0, len(edges))}
+	for _, target := range targets {
+		graph.Targets = append(graph.Targets, jsonTarget{ctx.paths.OutputName(target.Name()), targetConditions(target)})
+	}
+	for _, e := range edges {
+		annotations := e.Annotations().AsList()
+		sort.Strings(annotations)
+		graph.Edges = append(graph.Edges, jsonEdge{ctx.paths.OutputName(e.Target().Name()), ctx.paths.OutputName(e.Dependency().Name()), annotations})
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(graph)
+}
+
+// outputCSV writes a header row and one row per edge in `edges` to `stdout`.
+func outputCSV(ctx *context, stdout io.Writer, edges compliance.TargetEdgeList) error {
+	w := csv.NewWriter(stdout)
+	w.Write([]string{"target", "dependency", "annotations", "target_conditions", "dependency_conditions"})
+	for _, e := range edges {
+		annotations := e.Annotations().AsList()
+		sort.Strings(annotations)
+		w.Write([]string{
+			ctx.paths.OutputName(e.Target().Name()),
+			ctx.paths.OutputName(e.Dependency().Name()),
+			strings.Join(annotations, ":"),
+			strings.Join(targetConditions(e.Target()), ":"),
+			strings.Join(targetConditions(e.Dependency()), ":"),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// targetConditions returns the sorted condition names of `target`.
+func targetConditions(target *compliance.TargetNode) []string {
+	conditions := target.LicenseConditions().Names()
+	sort.Strings(conditions)
+	return conditions
+}
@@ -15,10 +15,19 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"compliance"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func Test_plaintext(t *testing.T) {
@@ -48,7 +57,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic static",
 				"bin/bin1.meta_lic lib/libc.a.meta_lic static",
@@ -64,7 +73,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:notice static",
 				"bin/bin1.meta_lic:notice lib/libc.a.meta_lic:notice static",
@@ -135,7 +144,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic static",
 				"bin/bin1.meta_lic lib/libc.a.meta_lic static",
@@ -151,7 +160,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:notice static",
 				"bin/bin1.meta_lic:notice lib/libc.a.meta_lic:notice static",
@@ -222,7 +231,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic static",
 				"bin/bin1.meta_lic lib/libc.a.meta_lic static",
@@ -238,7 +247,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:reciprocal static",
 				"bin/bin1.meta_lic:notice lib/libc.a.meta_lic:reciprocal static",
@@ -309,7 +318,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic static",
 				"bin/bin1.meta_lic lib/libc.a.meta_lic static",
@@ -325,7 +334,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:restricted static",
 				"bin/bin1.meta_lic:notice lib/libc.a.meta_lic:reciprocal static",
@@ -396,7 +405,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic static",
 				"bin/bin1.meta_lic lib/libc.a.meta_lic static",
@@ -412,7 +421,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:by_exception_only:proprietary static",
 				"bin/bin1.meta_lic:notice lib/libc.a.meta_lic:by_exception_only:proprietary static",
@@ -602,7 +611,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("bin/bin2.meta_lic"),
@@ -625,7 +634,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("bin/bin2.meta_lic", "notice"),
@@ -724,7 +733,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("bin/bin2.meta_lic"),
@@ -747,7 +756,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("bin/bin2.meta_lic", "notice"),
@@ -846,7 +855,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("bin/bin2.meta_lic"),
@@ -869,7 +878,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("bin/bin2.meta_lic", "notice"),
@@ -968,7 +977,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("bin/bin2.meta_lic"),
@@ -991,7 +1000,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("bin/bin2.meta_lic", "notice"),
@@ -1090,7 +1099,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("bin/bin2.meta_lic"),
@@ -1113,7 +1122,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("bin/bin2.meta_lic", "by_exception_only", "proprietary"),
@@ -1256,3 +1265,152 @@ func Test_graphviz(t *testing.T) {
 		})
 	}
 }
+
+func Test_format(t *testing.T) {
+	rootFiles := []string{"testdata/restricted/bin/bin1.meta_lic"}
+
+	t.Run("json", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := dumpGraph(&context{format: "json", paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}, stdout, stderr, rootFiles...)
+		if err != nil {
+			t.Fatalf("dumpgraph: error = %v, stderr = %v", err, stderr)
+		}
+		var actual struct {
+			Targets []jsonTarget `json:"targets"`
+			Edges   []jsonEdge   `json:"edges"`
+		}
+		if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+			t.Fatalf("dumpgraph: cannot parse %q: %v", stdout, err)
+		}
+		expectedTargets := []jsonTarget{
+			{"bin/bin1.meta_lic", []string{"notice"}},
+			{"lib/liba.so.meta_lic", []string{"restricted"}},
+			{"lib/libc.a.meta_lic", []string{"reciprocal"}},
+		}
+		expectedEdges := []jsonEdge{
+			{"bin/bin1.meta_lic", "lib/liba.so.meta_lic", []string{"static"}},
+			{"bin/bin1.meta_lic", "lib/libc.a.meta_lic", []string{"static"}},
+		}
+		if !reflect.DeepEqual(actual.Targets, expectedTargets) {
+			t.Errorf("dumpgraph: got targets %v, want %v", actual.Targets, expectedTargets)
+		}
+		if !reflect.DeepEqual(actual.Edges, expectedEdges) {
+			t.Errorf("dumpgraph: got edges %v, want %v", actual.Edges, expectedEdges)
+		}
+	})
+	t.Run("csv", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := dumpGraph(&context{format: "csv", paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}, stdout, stderr, rootFiles...)
+		if err != nil {
+			t.Fatalf("dumpgraph: error = %v, stderr = %v", err, stderr)
+		}
+		actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		expected := []string{
+			"target,dependency,annotations,target_conditions,dependency_conditions",
+			"bin/bin1.meta_lic,lib/liba.so.meta_lic,static,notice,restricted",
+			"bin/bin1.meta_lic,lib/libc.a.meta_lic,static,notice,reciprocal",
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("dumpgraph: got %q, want %q", actual, expected)
+		}
+	})
+	t.Run("dot", func(t *testing.T) {
+		err := dumpGraph(&context{format: "json", graphViz: true}, &bytes.Buffer{}, &bytes.Buffer{}, rootFiles...)
+		if err != failBadFormat {
+			t.Errorf("dumpgraph: got error %v, want %v", err, failBadFormat)
+		}
+	})
+}
+
+func Test_archive(t *testing.T) {
+	// zip up the restricted testdata below a build directory
+	archive := filepath.Join(t.TempDir(), "meta_lic.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("cannot create %q: %v", archive, err)
+	}
+	zw := zip.NewWriter(f)
+	err = fs.WalkDir(os.DirFS("."), "testdata/restricted", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create("build/" + path)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err == nil {
+		err = zw.Close()
+	}
+	f.Close()
+	if err != nil {
+		t.Fatalf("cannot write %q: %v", archive, err)
+	}
+
+	afs, err := compliance.OpenArchiveFS(archive, "build")
+	if err != nil {
+		t.Fatalf("OpenArchiveFS: got error %v, want no error", err)
+	}
+	defer afs.Close()
+
+	rootFiles := []string{"testdata/restricted/bin/bin1.meta_lic", "testdata/restricted/container.zip.meta_lic"}
+	expected := &bytes.Buffer{}
+	err = dumpGraph(&context{paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}, expected, &bytes.Buffer{}, rootFiles...)
+	if err != nil {
+		t.Fatalf("dumpgraph: got error %v reading directory, want no error", err)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	err = dumpGraph(&context{paths: compliance.PathFlags{StripPrefix: "testdata/restricted/", RootFS: afs}}, stdout, stderr, rootFiles...)
+	if err != nil {
+		t.Fatalf("dumpgraph: error = %v, stderr = %v", err, stderr)
+	}
+	if stdout.String() != expected.String() {
+		t.Errorf("dumpgraph: got %q reading archive, want %q", stdout.String(), expected.String())
+	}
+	if len(stdout.String()) == 0 {
+		t.Errorf("dumpgraph: got no output reading archive, want edges")
+	}
+}
+
+func Test_tolerateReadErrors(t *testing.T) {
+	rootFS := fstest.MapFS{
+		"bin.meta_lic": {Data: []byte(`package_name: "Android"
+license_conditions: "notice"
+deps: {
+  file: "missing.meta_lic"
+  annotations: "static"
+}
+`)},
+	}
+	for _, tolerate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("tolerate=%t", tolerate), func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+			ctx := &context{tolerate: tolerate, paths: compliance.PathFlags{RootFS: rootFS}}
+			err := dumpGraph(ctx, stdout, stderr, "bin.meta_lic")
+			if err == nil {
+				t.Fatalf("dumpgraph: got no error, want missing.meta_lic error")
+			}
+			var readErrors compliance.ReadErrors
+			if tolerate && !errors.As(err, &readErrors) {
+				t.Fatalf("dumpgraph: got error %v, want compliance.ReadErrors", err)
+			}
+			expected := ""
+			if tolerate {
+				expected = "bin.meta_lic missing.meta_lic static\n"
+			}
+			if stdout.String() != expected {
+				t.Errorf("dumpgraph: got stdout %q, want %q", stdout.String(), expected)
+			}
+		})
+	}
+}
@@ -16,6 +16,8 @@ package main
 
 import (
 	"compliance"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -27,19 +29,28 @@ import (
 
 var (
 	conditions      = newMultiString("c", "License condition to resolve. (may be given multiple times)")
+	format          = flag.String("format", "text", "Output format: text, json or csv.")
 	graphViz        = flag.Bool("dot", false, "Whether to output graphviz (i.e. dot) format.")
+	installed       = flag.Bool("installed", false, "Whether to key the resolutions by the installed paths of the targets.")
 	labelConditions = flag.Bool("label_conditions", false, "Whether to label target nodes with conditions.")
-	stripPrefix     = flag.String("strip_prefix", "", "Prefix to remove from paths. i.e. path to root")
+	graphCache      = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds    = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths           = compliance.NewPathFlags(flag.CommandLine)
 
 	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
-	failNoLicenses = fmt.Errorf("No licenses found")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text, json or csv, and -dot requires text without -installed")
 )
 
 type context struct {
 	conditions      []string
+	format          string
 	graphViz        bool
+	installed       bool
 	labelConditions bool
-	stripPrefix     string
+	graphCache      string
+	licenseKinds    string
+	paths           compliance.PathFlags
 }
 
 func init() {
@@ -58,6 +69,21 @@ In plain text mode, when '-label_conditions' is requested, the Target
 and Origin have colon-separated license conditions appended:
 i.e. target:condition1:condition2 etc.
 
+When -format=json given, outputs a list of {"attaches_to", "acts_on",
+"origin", "conditions"} objects. When -format=csv given, outputs a
+header row followed by one attaches_to,acts_on,origin,conditions row
+per resolution with the conditions colon-separated. Neither labels
+targets with conditions.
+
+When -installed given, replaces the Target with the full path of each
+file the target installs, composing the install maps of any containers
+holding the target, e.g. a library inside an APEX inside a partition
+image, and outputs the resolutions in installed path order. Outputs
+nothing for targets that install no files, e.g. static libraries, whose
+resolutions also attach to the targets that use them. Does not label
+targets with conditions. The json and csv formats name the first field
+"installed" instead of "attaches_to".
+
 Options:
 `, filepath.Base(os.Args[0]))
 		flag.PrintDefaults()
@@ -88,13 +114,18 @@ func main() {
 
 	ctx := &context{
 		conditions:      append([]string{}, *conditions...),
+		format:          *format,
 		graphViz:        *graphViz,
+		installed:       *installed,
 		labelConditions: *labelConditions,
-		stripPrefix:     *stripPrefix,
+		graphCache:      *graphCache,
+		licenseKinds:    *licenseKinds,
+		paths:           *paths,
 	}
+
 	err := dumpResolutions(ctx, os.Stdout, os.Stderr, flag.Args()...)
 	if err != nil {
-		if err == failNoneRequested {
+		if err == failNoneRequested || err == failBadFormat {
 			flag.Usage()
 		}
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
@@ -108,9 +139,31 @@ func dumpResolutions(ctx *context, stdout, stderr io.Writer, files ...string) er
 	if len(files) < 1 {
 		return failNoneRequested
 	}
+	switch ctx.format {
+	case "", "text":
+	case "json", "csv":
+		if ctx.graphViz {
+			return failBadFormat
+		}
+	default:
+		return failBadFormat
+	}
+	if ctx.graphViz && ctx.installed {
+		return failBadFormat
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
 
 	// Read the license graph from the license metadata files (*.meta_lic).
-	licenseGraph, err := compliance.ReadLicenseGraph(os.DirFS("."), stderr, files)
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
 	if err != nil {
 		return fmt.Errorf("Unable to read license metadata file(s) %q: %v\n", files, err)
 	}
@@ -121,11 +174,11 @@ func dumpResolutions(ctx *context, stdout, stderr io.Writer, files ...string) er
 	// resolutions will contain the requested set of resolutions.
 	var resolutions *compliance.ResolutionSet
 
-	resolutions = compliance.ResolveTopDownConditions(licenseGraph)
+	resolutions = compliance.ResolveTopDownConditions(licenseGraph, kinds.Policy())
 	if len(ctx.conditions) > 0 {
 		rlist := make([]*compliance.ResolutionSet, 0, len(ctx.conditions))
 		for _, c := range ctx.conditions {
-			rlist = append(rlist, compliance.WalkResolutionsForCondition(licenseGraph, resolutions, compliance.ConditionNames{c}))
+			rlist = append(rlist, compliance.WalkResolutionsForCondition(licenseGraph, kinds.Policy(), resolutions, compliance.ConditionNames{c}))
 		}
 		if len(rlist) == 1 {
 			resolutions = rlist[0]
@@ -134,13 +187,32 @@ func dumpResolutions(ctx *context, stdout, stderr io.Writer, files ...string) er
 		}
 	}
 
+	if ctx.installed {
+		return outputInstalled(ctx, stdout, installedRecords(ctx, licenseGraph, resolutions))
+	}
+
+	switch ctx.format {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resolutionRecords(ctx, resolutions))
+	case "csv":
+		w := csv.NewWriter(stdout)
+		w.Write([]string{"attaches_to", "acts_on", "origin", "conditions"})
+		for _, r := range resolutionRecords(ctx, resolutions) {
+			w.Write([]string{r.AttachesTo, r.ActsOn, r.Origin, strings.Join(r.Conditions, ":")})
+		}
+		w.Flush()
+		return w.Error()
+	}
+
 	// nodes maps license metadata file names to graphViz node names when graphViz requested.
 	nodes := make(map[string]string)
 	n := 0
 
 	// targetOut calculates the string to output for `target` adding `sep`-separated conditions as needed.
 	targetOut := func(target *compliance.TargetNode, sep string) string {
-		tOut := strings.TrimPrefix(target.Name(), ctx.stripPrefix)
+		tOut := ctx.paths.OutputName(target.Name())
 		if ctx.labelConditions {
 			conditions := make([]string, 0, target.LicenseConditions().Count())
 			for _, lc := range target.LicenseConditions().AsList() {
@@ -270,10 +342,7 @@ func dumpResolutions(ctx *context, stdout, stderr io.Writer, files ...string) er
 	if ctx.graphViz {
 		fmt.Fprintf(stdout, "\t{rank=same;")
 		for _, f := range files {
-			fName := f
-			if !strings.HasSuffix(fName, ".meta_lic") {
-				fName += ".meta_lic"
-			}
+			fName := compliance.MetadataFileName(f)
 			if fNode, ok := nodes[fName]; ok {
 				fmt.Fprintf(stdout, " %s", fNode)
 			}
@@ -282,3 +351,112 @@ func dumpResolutions(ctx *context, stdout, stderr io.Writer, files ...string) er
 	}
 	return nil
 }
+
+// resolutionRecord describes the conditions originating at a single origin
+// that a resolution resolves in -format=json or -format=csv output.
+type resolutionRecord struct {
+	AttachesTo string   `json:"attaches_to"`
+	ActsOn     string   `json:"acts_on"`
+	Origin     string   `json:"origin"`
+	Conditions []string `json:"conditions"`
+}
+
+// resolutionRecords returns 1 record for each attachesTo+actsOn+origin
+// combination in `resolutions` in the same order as the plain text output.
+func resolutionRecords(ctx *context, resolutions *compliance.ResolutionSet) []resolutionRecord {
+	result := make([]resolutionRecord, 0)
+
+	targets := resolutions.AttachesTo()
+	sort.Sort(targets)
+	for _, target := range targets {
+		result = append(result, targetResolutionRecords(ctx, resolutions, target)...)
+	}
+	return result
+}
+
+// targetResolutionRecords returns 1 record for each actsOn+origin combination
+// in the resolutions of `resolutions` attached to `target`.
+func targetResolutionRecords(ctx *context, resolutions *compliance.ResolutionSet, target *compliance.TargetNode) []resolutionRecord {
+	result := make([]resolutionRecord, 0)
+
+	tname := ctx.paths.OutputName(target.Name())
+	rl := compliance.ResolutionList(resolutions.Resolutions(target))
+	sort.Sort(rl)
+	for _, r := range rl {
+		aname := ctx.paths.OutputName(r.ActsOn().Name())
+		conditions := r.Resolves().AsList()
+		sort.Sort(conditions)
+
+		// record is the record for the previous origin or nil if no previous
+		var record *resolutionRecord
+		for _, condition := range conditions {
+			oname := ctx.paths.OutputName(condition.Origin().Name())
+			if record == nil || record.Origin != oname {
+				result = append(result, resolutionRecord{tname, aname, oname, []string{}})
+				record = &result[len(result)-1]
+			}
+			record.Conditions = append(record.Conditions, condition.Name())
+		}
+	}
+	return result
+}
+
+// installedRecord describes the conditions originating at a single origin
+// that a resolution resolves for a file installed by the target the
+// resolution attaches to in -installed output.
+type installedRecord struct {
+	Installed  string   `json:"installed"`
+	ActsOn     string   `json:"acts_on"`
+	Origin     string   `json:"origin"`
+	Conditions []string `json:"conditions"`
+}
+
+// installedRecords returns 1 record for each installed path of each
+// attachesTo+actsOn+origin combination in `resolutions` in installed path
+// order.
+func installedRecords(ctx *context, licenseGraph *compliance.LicenseGraph, resolutions *compliance.ResolutionSet) []installedRecord {
+	installPaths := compliance.InstallPaths(licenseGraph)
+
+	result := make([]installedRecord, 0)
+	targets := resolutions.AttachesTo()
+	sort.Sort(targets)
+	for _, target := range targets {
+		paths := installPaths[target]
+		if len(paths) == 0 {
+			continue
+		}
+		records := targetResolutionRecords(ctx, resolutions, target)
+		for _, path := range paths {
+			path = ctx.paths.OutputName(path)
+			for _, r := range records {
+				result = append(result, installedRecord{path, r.ActsOn, r.Origin, r.Conditions})
+			}
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Installed < result[j].Installed
+	})
+	return result
+}
+
+// outputInstalled writes `records` to `stdout` in the requested format.
+func outputInstalled(ctx *context, stdout io.Writer, records []installedRecord) error {
+	switch ctx.format {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case "csv":
+		w := csv.NewWriter(stdout)
+		w.Write([]string{"installed", "acts_on", "origin", "conditions"})
+		for _, r := range records {
+			w.Write([]string{r.Installed, r.ActsOn, r.Origin, strings.Join(r.Conditions, ":")})
+		}
+		w.Flush()
+		return w.Error()
+	}
+	for _, r := range records {
+		fmt.Fprintf(stdout, "%s %s %s %s\n", r.Installed, r.ActsOn, r.Origin, strings.Join(r.Conditions, ":"))
+	}
+	return nil
+}
@@ -16,7 +16,10 @@ package main
 
 import (
 	"bytes"
+	"compliance"
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -54,7 +57,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic notice",
@@ -77,8 +80,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
@@ -100,8 +103,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []string{},
 		},
@@ -110,8 +113,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []string{},
 		},
@@ -120,8 +123,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []string{},
 		},
@@ -129,7 +132,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice notice",
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:notice lib/liba.so.meta_lic:notice notice",
@@ -225,7 +228,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic notice",
@@ -248,8 +251,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
@@ -271,8 +274,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []string{},
 		},
@@ -281,8 +284,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []string{},
 		},
@@ -291,8 +294,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []string{},
 		},
@@ -300,7 +303,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice notice",
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:notice lib/liba.so.meta_lic:notice notice",
@@ -396,7 +399,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic reciprocal",
@@ -419,8 +422,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
@@ -437,8 +440,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic reciprocal",
@@ -453,8 +456,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []string{},
 		},
@@ -463,8 +466,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic reciprocal",
@@ -478,7 +481,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice notice",
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:reciprocal lib/liba.so.meta_lic:reciprocal reciprocal",
@@ -587,7 +590,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
 				"bin/bin1.meta_lic bin/bin1.meta_lic lib/liba.so.meta_lic restricted",
@@ -623,8 +626,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
@@ -639,8 +642,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic lib/liba.so.meta_lic restricted",
@@ -666,8 +669,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []string{},
 		},
@@ -676,8 +679,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic lib/liba.so.meta_lic restricted",
@@ -702,7 +705,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice notice",
 				"bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice lib/liba.so.meta_lic:restricted restricted",
@@ -839,7 +842,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic by_exception_only:proprietary",
@@ -869,8 +872,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic bin/bin1.meta_lic bin/bin1.meta_lic notice",
@@ -883,8 +886,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []string{
 				"bin/bin2.meta_lic bin/bin2.meta_lic lib/libb.so.meta_lic restricted",
@@ -900,8 +903,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic proprietary",
@@ -918,8 +921,8 @@ func Test_plaintext(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []string{
 				"bin/bin1.meta_lic lib/liba.so.meta_lic lib/liba.so.meta_lic proprietary",
@@ -941,7 +944,7 @@ func Test_plaintext(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []string{
 				"bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice bin/bin1.meta_lic:notice notice",
 				"bin/bin1.meta_lic:notice lib/liba.so.meta_lic:by_exception_only:proprietary lib/liba.so.meta_lic:by_exception_only:proprietary by_exception_only:proprietary",
@@ -1239,7 +1242,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("lib/liba.so.meta_lic"),
@@ -1325,8 +1328,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -1402,8 +1405,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -1412,8 +1415,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -1422,8 +1425,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/firstparty/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/firstparty/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -1431,7 +1434,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "firstparty",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/firstparty/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/firstparty/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("lib/liba.so.meta_lic", "notice"),
@@ -1768,7 +1771,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("lib/liba.so.meta_lic"),
@@ -1854,8 +1857,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -1931,8 +1934,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -1941,8 +1944,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -1951,8 +1954,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/notice/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/notice/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -1960,7 +1963,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "notice",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/notice/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("lib/liba.so.meta_lic", "notice"),
@@ -2297,7 +2300,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("lib/liba.so.meta_lic"),
@@ -2383,8 +2386,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -2433,8 +2436,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -2473,8 +2476,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -2483,8 +2486,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/reciprocal/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/reciprocal/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -2522,7 +2525,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "reciprocal",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/reciprocal/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("lib/liba.so.meta_lic", "reciprocal"),
@@ -2924,7 +2927,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("lib/liba.so.meta_lic"),
@@ -3075,8 +3078,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -3114,8 +3117,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -3211,8 +3214,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []getMatcher{},
 		},
@@ -3221,8 +3224,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/restricted/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/restricted/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -3317,7 +3320,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "restricted",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/restricted/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("lib/liba.so.meta_lic", "restricted"),
@@ -3867,7 +3870,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/"},
+			ctx:       context{paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
 				matchTarget("lib/liba.so.meta_lic"),
@@ -3996,8 +3999,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_notice",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"notice"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"notice"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -4024,8 +4027,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"reciprocal", "restricted"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin2.meta_lic"),
@@ -4068,8 +4071,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"proprietary"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -4119,8 +4122,8 @@ func Test_graphviz(t *testing.T) {
 			name:      "apex_trimmed_share_private",
 			roots:     []string{"highest.apex.meta_lic"},
 			ctx: context{
-				conditions:  []string{"reciprocal", "restricted", "proprietary"},
-				stripPrefix: "testdata/proprietary/",
+				conditions: []string{"reciprocal", "restricted", "proprietary"},
+				paths:      compliance.PathFlags{StripPrefix: "testdata/proprietary/"},
 			},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic"),
@@ -4200,7 +4203,7 @@ func Test_graphviz(t *testing.T) {
 			condition: "proprietary",
 			name:      "apex_trimmed_labelled",
 			roots:     []string{"highest.apex.meta_lic"},
-			ctx:       context{stripPrefix: "testdata/proprietary/", labelConditions: true},
+			ctx:       context{labelConditions: true, paths: compliance.PathFlags{StripPrefix: "testdata/proprietary/"}},
 			expectedOut: []getMatcher{
 				matchTarget("bin/bin1.meta_lic", "notice"),
 				matchTarget("lib/liba.so.meta_lic", "by_exception_only", "proprietary"),
@@ -4632,3 +4635,149 @@ func Test_graphviz(t *testing.T) {
 		})
 	}
 }
+
+func Test_format(t *testing.T) {
+	rootFiles := []string{"testdata/restricted/bin/bin1.meta_lic"}
+	expected := []resolutionRecord{
+		{"bin/bin1.meta_lic", "bin/bin1.meta_lic", "bin/bin1.meta_lic", []string{"notice"}},
+		{"bin/bin1.meta_lic", "bin/bin1.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+		{"bin/bin1.meta_lic", "lib/liba.so.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+		{"bin/bin1.meta_lic", "lib/libc.a.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+		{"bin/bin1.meta_lic", "lib/libc.a.meta_lic", "lib/libc.a.meta_lic", []string{"reciprocal"}},
+		{"lib/liba.so.meta_lic", "lib/liba.so.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+		{"lib/libc.a.meta_lic", "lib/libc.a.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+		{"lib/libc.a.meta_lic", "lib/libc.a.meta_lic", "lib/libc.a.meta_lic", []string{"reciprocal"}},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := dumpResolutions(&context{format: "json", paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}, stdout, stderr, rootFiles...)
+		if err != nil {
+			t.Fatalf("dumpresolutions: error = %v, stderr = %v", err, stderr)
+		}
+		var actual []resolutionRecord
+		if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+			t.Fatalf("dumpresolutions: cannot parse %q: %v", stdout, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("dumpresolutions: got %v, want %v", actual, expected)
+		}
+	})
+	t.Run("csv", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := dumpResolutions(&context{format: "csv", paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}, stdout, stderr, rootFiles...)
+		if err != nil {
+			t.Fatalf("dumpresolutions: error = %v, stderr = %v", err, stderr)
+		}
+		actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		expectedCSV := []string{"attaches_to,acts_on,origin,conditions"}
+		for _, r := range expected {
+			expectedCSV = append(expectedCSV, strings.Join([]string{r.AttachesTo, r.ActsOn, r.Origin, strings.Join(r.Conditions, ":")}, ","))
+		}
+		if !reflect.DeepEqual(actual, expectedCSV) {
+			t.Errorf("dumpresolutions: got %q, want %q", actual, expectedCSV)
+		}
+	})
+}
+
+func Test_installed(t *testing.T) {
+	tests := []struct {
+		condition   string
+		name        string
+		roots       []string
+		ctx         context
+		expectedOut []string
+	}{
+		{
+			condition: "restricted",
+			name:      "apex",
+			roots:     []string{"highest.apex.meta_lic"},
+			ctx:       context{conditions: []string{"restricted"}, paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}},
+			expectedOut: []string{
+				"out/target/product/fictional/system/apex/highest.apex bin/bin1.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex bin/bin2.meta_lic lib/libb.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex highest.apex.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex highest.apex.meta_lic lib/libb.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex lib/liba.so.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex lib/libb.so.meta_lic lib/libb.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex lib/libc.a.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex/bin/bin1 bin/bin1.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex/bin/bin1 lib/liba.so.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex/bin/bin1 lib/libc.a.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex/bin/bin1 bin/bin2.meta_lic lib/libb.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex/bin/bin1 lib/libb.so.meta_lic lib/libb.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex/lib/liba.so lib/liba.so.meta_lic lib/liba.so.meta_lic restricted",
+				"out/target/product/fictional/system/apex/highest.apex/lib/libb.so lib/libb.so.meta_lic lib/libb.so.meta_lic restricted",
+			},
+		},
+		{
+			condition: "reciprocal",
+			name:      "container",
+			roots:     []string{"container.zip.meta_lic"},
+			ctx:       context{conditions: []string{"reciprocal"}, format: "csv", paths: compliance.PathFlags{StripPrefix: "testdata/reciprocal/"}},
+			expectedOut: []string{
+				"installed,acts_on,origin,conditions",
+				"out/target/product/fictional/data/container.zip,lib/liba.so.meta_lic,lib/liba.so.meta_lic,reciprocal",
+				"out/target/product/fictional/data/container.zip,lib/libc.a.meta_lic,lib/libc.a.meta_lic,reciprocal",
+				"out/target/product/fictional/data/container.zip/bin1,lib/liba.so.meta_lic,lib/liba.so.meta_lic,reciprocal",
+				"out/target/product/fictional/data/container.zip/bin1,lib/libc.a.meta_lic,lib/libc.a.meta_lic,reciprocal",
+				"out/target/product/fictional/data/container.zip/liba.so,lib/liba.so.meta_lic,lib/liba.so.meta_lic,reciprocal",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			tt.ctx.installed = true
+			err := dumpResolutions(&tt.ctx, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("dumpresolutions: error = %v, stderr = %v", err, stderr)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("dumpresolutions: gotStderr = %v, want none", stderr)
+			}
+			actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("dumpresolutions: got %q, want %q", actual, tt.expectedOut)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		ctx := &context{installed: true, format: "json", conditions: []string{"restricted"}, paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}
+		err := dumpResolutions(ctx, stdout, stderr, "testdata/restricted/bin/bin1.meta_lic")
+		if err != nil {
+			t.Fatalf("dumpresolutions: error = %v, stderr = %v", err, stderr)
+		}
+		var actual []installedRecord
+		if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+			t.Fatalf("dumpresolutions: cannot parse %q: %v", stdout, err)
+		}
+		bin1 := "out/target/product/fictional/system/bin/bin1"
+		expected := []installedRecord{
+			{bin1, "bin/bin1.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+			{bin1, "lib/liba.so.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+			{bin1, "lib/libc.a.meta_lic", "lib/liba.so.meta_lic", []string{"restricted"}},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("dumpresolutions: got %+v, want %+v", actual, expected)
+		}
+	})
+	t.Run("dot", func(t *testing.T) {
+		ctx := &context{installed: true, graphViz: true}
+		err := dumpResolutions(ctx, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/bin/bin1.meta_lic")
+		if err != failBadFormat {
+			t.Errorf("dumpresolutions: got error %v, want %v", err, failBadFormat)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition     string
+		name          string
+		roots         []string
+		stripPrefix   string
+		expectedLines []string
+	}{
+		{
+			condition:   "notices",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			stripPrefix: "out/target/product/fictional",
+			expectedLines: []string{
+				"  <ul class=\"toc\">",
+				"    <li>/system/apex/highest.apex",
+				"        <li><a href=\"#id1\">notice 1</a></li>",
+				"        <li><a href=\"#id2\">notice 2</a></li>",
+				"        <li><a href=\"#id3\">notice 3</a></li>",
+				"    <li>/system/lib/libb.so",
+				"        <li><a href=\"#id2\">notice 2</a></li>",
+				"  <strong id=\"id1\">Notice 1 for file(s):</strong>",
+				"  <pre class=\"license-text\">$$$Reciprocal License$$$",
+				"  <strong id=\"id2\">Notice 2 for file(s):</strong>",
+				"    /system/lib/liba.so <br>",
+				"    /system/lib/libb.so <br>",
+				"  <pre class=\"license-text\">%%%Notice License%%%",
+				"  <strong id=\"id3\">Notice 3 for file(s):</strong>",
+				"  <pre class=\"license-text\">&amp;&amp;&amp;First Party License&amp;&amp;&amp;",
+				"</pre><!-- license-text -->",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			ctx := &context{paths: compliance.PathFlags{StripPrefix: tt.stripPrefix}}
+			err := htmlNotice(ctx, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("htmlnotice: error = %v, stderr = %v", err, stderr)
+				return
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("htmlnotice: gotStderr = %v, want none", stderr)
+			}
+			checkLines(t, stdout.String(), tt.expectedLines)
+		})
+	}
+}
+
+func Test_gzip(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{title: "Notices & Licenses", gzip: true}
+	err := htmlNotice(ctx, stdout, stderr, "testdata/notices/highest.apex.meta_lic")
+	if err != nil {
+		t.Fatalf("htmlnotice: error = %v, stderr = %v", err, stderr)
+	}
+	zr, err := gzip.NewReader(stdout)
+	if err != nil {
+		t.Fatalf("htmlnotice: unable to read gzip output: %v", err)
+	}
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("htmlnotice: unable to decompress output: %v", err)
+	}
+	checkLines(t, string(text), []string{
+		"<!DOCTYPE html>",
+		"<title>Notices &amp; Licenses</title>",
+		"  <h1>Notices &amp; Licenses</h1>",
+		"</body></html>",
+	})
+}
+
+func Test_closeOutput(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "NOTICE")
+
+	failed, err := os.CreateTemp(dir, "NOTICE.*")
+	if err != nil {
+		t.Fatalf("htmlnotice: unable to create temporary file: %v", err)
+	}
+	failed.WriteString("partial")
+	if err := closeOutput(failed, outputFile, failNoLicenses); err != failNoLicenses {
+		t.Errorf("htmlnotice: got error %v, want %v", err, failNoLicenses)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("htmlnotice: got %v after failure, want no files", entries)
+	}
+
+	done, err := os.CreateTemp(dir, "NOTICE.*")
+	if err != nil {
+		t.Fatalf("htmlnotice: unable to create temporary file: %v", err)
+	}
+	done.WriteString("complete")
+	if err := closeOutput(done, outputFile, nil); err != nil {
+		t.Fatalf("htmlnotice: got error %v, want none", err)
+	}
+	fi, err := os.Stat(outputFile)
+	if err != nil {
+		t.Fatalf("htmlnotice: unable to stat %q: %v", outputFile, err)
+	}
+	if fi.Mode().Perm() != 0644 {
+		t.Errorf("htmlnotice: got mode %v, want %v", fi.Mode().Perm(), os.FileMode(0644))
+	}
+}
+
+// checkLines verifies `expectedLines` appear in `output` in order.
+func checkLines(t *testing.T, output string, expectedLines []string) {
+	lines := strings.Split(output, "\n")
+	next := 0
+	for _, expected := range expectedLines {
+		for next < len(lines) && lines[next] != expected {
+			next++
+		}
+		if next >= len(lines) {
+			t.Errorf("htmlnotice: missing line %q in order in output:\n%s", expected, output)
+			return
+		}
+		next++
+	}
+}
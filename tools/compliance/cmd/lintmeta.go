@@ -0,0 +1,143 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var (
+	format       = flag.String("format", "text", "Output format: text or json.")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failProblems      = fmt.Errorf("problems")
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text or json")
+)
+
+type context struct {
+	format       string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Reads the license metadata files and every file they depend on, and
+reports all of the problems found instead of stopping at the first.
+
+Outputs one "file: problem" line per problem in file order, and exits
+with status 1 if there are any problems or with status 0 if none.
+
+Problems include unreadable or unparseable files, dependencies on
+missing files, dependencies with no file name, duplicate dependencies
+with conflicting annotations, unrecognized annotations, unrecognized
+license condition names, license kinds without license conditions,
+missing license text files, empty package names, and containers without
+dependencies.
+
+When -format=json given, outputs a list of {"file", "problem"} objects.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*format, *licenseKinds, *paths}
+
+	err := lintMeta(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err != failProblems {
+			if err == failNoneRequested || err == failBadFormat {
+				flag.Usage()
+			}
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		}
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// problemRecord describes a problem in -format=json output.
+type problemRecord struct {
+	File    string `json:"file"`
+	Problem string `json:"problem"`
+}
+
+// lintMeta implements the lintmeta utility.
+func lintMeta(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	switch ctx.format {
+	case "", "text", "json":
+	default:
+		return failBadFormat
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	problems, err := compliance.LintLicenseMetadataWithKindMap(rootFS, files, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to lint license metadata file(s) %q: %w\n", files, err)
+	}
+
+	if ctx.format == "json" {
+		result := make([]problemRecord, 0, len(problems))
+		for _, p := range problems {
+			result = append(result, problemRecord{ctx.paths.OutputName(p.File), p.Problem})
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(result); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "%s: %s\n", ctx.paths.OutputName(p.File), p.Problem)
+		}
+	}
+	if len(problems) > 0 {
+		return failProblems
+	}
+	return nil
+}
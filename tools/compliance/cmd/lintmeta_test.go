@@ -0,0 +1,162 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition   string
+		name        string
+		roots       []string
+		expectedErr error
+		expectedOut []string
+	}{
+		{
+			condition:   "restricted",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			expectedOut: []string{},
+		},
+		{
+			condition:   "reciprocal",
+			name:        "application",
+			roots:       []string{"application.meta_lic", "bin/bin3.meta_lic"},
+			expectedOut: []string{},
+		},
+		{
+			condition:   "lint",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			expectedErr: failProblems,
+			expectedOut: []string{
+				"bin/bin1.meta_lic: dependency \"testdata/lint/lib/libc.a.meta_lic\" missing",
+				"bin/bin1.meta_lic: empty package name",
+				"bin/bin1.meta_lic: missing license text file \"testdata/lint/MISSING_LICENSE\"",
+				"bin/bin1.meta_lic: unrecognized annotation \"linked\" on dependency \"testdata/lint/lib/liba.so.meta_lic\"",
+				"container.zip.meta_lic: container without dependencies",
+				"highest.apex.meta_lic: duplicate dependency \"testdata/lint/bin/bin1.meta_lic\" with conflicting annotations [static] and [dynamic]",
+				"lib/liba.so.meta_lic: license kinds SPDX-license-identifier-GPL-2.0 without license conditions",
+				"lib/libb.so.meta_lic: unrecognized license condition \"noticeable\"",
+			},
+		},
+		{
+			condition:   "lint",
+			name:        "missing",
+			roots:       []string{"highest.apex.meta_lic", "bin/bin2.meta_lic"},
+			expectedErr: failProblems,
+			expectedOut: []string{
+				"bin/bin1.meta_lic: dependency \"testdata/lint/lib/libc.a.meta_lic\" missing",
+				"bin/bin1.meta_lic: empty package name",
+				"bin/bin1.meta_lic: missing license text file \"testdata/lint/MISSING_LICENSE\"",
+				"bin/bin1.meta_lic: unrecognized annotation \"linked\" on dependency \"testdata/lint/lib/liba.so.meta_lic\"",
+				"bin/bin2.meta_lic: cannot read license metadata",
+				"container.zip.meta_lic: container without dependencies",
+				"highest.apex.meta_lic: duplicate dependency \"testdata/lint/bin/bin1.meta_lic\" with conflicting annotations [static] and [dynamic]",
+				"lib/liba.so.meta_lic: license kinds SPDX-license-identifier-GPL-2.0 without license conditions",
+				"lib/libb.so.meta_lic: unrecognized license condition \"noticeable\"",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			ctx := &context{paths: compliance.PathFlags{StripPrefix: "testdata/" + tt.condition + "/", RootFS: testdataFS(t, tt.condition)}}
+			err := lintMeta(ctx, stdout, stderr, rootFiles...)
+			if err != tt.expectedErr {
+				t.Fatalf("lintmeta: got error %v, want %v, stderr = %v", err, tt.expectedErr, stderr)
+			}
+			actual := make([]string, 0)
+			for _, line := range strings.Split(stdout.String(), "\n") {
+				if len(line) > 0 {
+					actual = append(actual, line)
+				}
+			}
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("lintmeta: got %q, want %q", actual, tt.expectedOut)
+			}
+		})
+	}
+}
+
+// testdataFS returns the testdata for `condition` along with the license text
+// the shared testdata refer to in the source tree.
+func testdataFS(t *testing.T, condition string) fs.FS {
+	mfs := fstest.MapFS{
+		"build/soong/licenses/LICENSE": {Data: []byte("&&&Build License&&&\n")},
+	}
+	err := fs.WalkDir(os.DirFS("."), "testdata/"+condition, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		mfs[path] = &fstest.MapFile{Data: data}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to read testdata: %v", err)
+	}
+	return mfs
+}
+
+func Test_json(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{format: "json", paths: compliance.PathFlags{StripPrefix: "testdata/lint/"}}
+	err := lintMeta(ctx, stdout, stderr, "testdata/lint/lib/libb.so.meta_lic")
+	if err != failProblems {
+		t.Fatalf("lintmeta: got error %v, want %v, stderr = %v", err, failProblems, stderr)
+	}
+	var actual []problemRecord
+	if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+		t.Fatalf("lintmeta: cannot parse %q: %v", stdout, err)
+	}
+	expected := []problemRecord{
+		{"lib/libb.so.meta_lic", "unrecognized license condition \"noticeable\""},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("lintmeta: got %+v, want %+v", actual, expected)
+	}
+}
+
+func Test_errors(t *testing.T) {
+	err := lintMeta(&context{}, &bytes.Buffer{}, &bytes.Buffer{})
+	if err != failNoneRequested {
+		t.Errorf("lintmeta: got error %v, want %v", err, failNoneRequested)
+	}
+	err = lintMeta(&context{format: "csv"}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/lint/highest.apex.meta_lic")
+	if err != failBadFormat {
+		t.Errorf("lintmeta: got error %v, want %v", err, failBadFormat)
+	}
+}
@@ -16,6 +16,8 @@ package main
 
 import (
 	"compliance"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -34,15 +36,54 @@ by target:condition pairs describing why the project must be shared.
 Each target is the path to a generated license metadata file for a
 Soong module or Make target, and the license condition is either
 restricted (e.g. GPL) or reciprocal (e.g. MPL).
+
+When -format=json given, outputs a list of {"project", "conditions"}
+objects where each condition is an {"origin", "condition"} object. When
+-format=csv given, outputs a header row followed by one project,origin,
+condition row per pair.
+
+When -per_file given, lists the individual source files to share for
+reciprocal (e.g. MPL) conditions instead of whole projects, using the
+sources recorded in the license metadata. Restricted conditions, and
+reciprocal conditions for targets without recorded sources, still list
+the whole project with a trailing "/" to distinguish it from a file. The
+json and csv formats name the first field "path" instead of "project".
+
+When -installed given, lists the full path of each file installed by a
+target whose source must be shared instead of projects, composing
+the install maps of any containers holding the target, e.g. a library
+inside an APEX inside a partition image. The json and csv formats name
+the first field "path" instead of "project".
+
+Options:
 `, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
 	}
 }
 
 var (
+	format       = flag.String("format", "text", "Output format: text, json or csv.")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	perFile      = flag.Bool("per_file", false, "List individual source files for reciprocal conditions instead of whole projects.")
+	installed    = flag.Bool("installed", false, "List the installed paths of the targets with source-sharing conditions instead of projects.")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
 	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
-	failNoLicenses = fmt.Errorf("No licenses found")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text, json or csv")
+	failBadMode       = fmt.Errorf("\n-per_file and -installed are mutually exclusive")
 )
 
+type context struct {
+	format       string
+	graphCache   string
+	licenseKinds string
+	perFile      bool
+	installed    bool
+	paths        compliance.PathFlags
+}
+
 func main() {
 	flag.Parse()
 
@@ -52,9 +93,11 @@ func main() {
 		os.Exit(2)
 	}
 
-	err := listShare(os.Stdout, os.Stderr, flag.Args()...)
+	ctx := &context{*format, *graphCache, *licenseKinds, *perFile, *installed, *paths}
+
+	err := listShare(ctx, os.Stdout, os.Stderr, flag.Args()...)
 	if err != nil {
-		if err == failNoneRequested {
+		if err == failNoneRequested || err == failBadFormat || err == failBadMode {
 			flag.Usage()
 		}
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
@@ -64,14 +107,32 @@ func main() {
 }
 
 // listShare implements the listshare utility.
-func listShare(stdout, stderr io.Writer, files ...string) error {
+func listShare(ctx *context, stdout, stderr io.Writer, files ...string) error {
 	// Must be at least one root file.
 	if len(files) < 1 {
 		return failNoneRequested
 	}
+	switch ctx.format {
+	case "", "text", "json", "csv":
+	default:
+		return failBadFormat
+	}
+	if ctx.perFile && ctx.installed {
+		return failBadMode
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
 
 	// Read the license graph from the license metadata files (*.meta_lic).
-	licenseGraph, err := compliance.ReadLicenseGraph(os.DirFS("."), stderr, files)
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
 	if err != nil {
 		return fmt.Errorf("Unable to read license metadata file(s) %q: %v\n", files, err)
 	}
@@ -79,10 +140,51 @@ func listShare(stdout, stderr io.Writer, files ...string) error {
 		return failNoLicenses
 	}
 
-	// shareSource contains all source-sharing resolutions.
-	shareSource := compliance.ResolveSourceSharing(licenseGraph)
+	// Group the source-sharing resolutions by project, by file for -per_file, or by
+	// installed path for -installed.
+	var presolution map[string]*compliance.LicenseConditionSet
+	if ctx.perFile {
+		presolution = shareFiles(licenseGraph, kinds.Policy())
+	} else if ctx.installed {
+		presolution = shareInstalled(ctx, licenseGraph, compliance.ResolveSourceSharingWithPolicy(licenseGraph, kinds.Policy()))
+	} else {
+		presolution = shareProjects(compliance.ResolveSourceSharingWithPolicy(licenseGraph, kinds.Policy()))
+	}
 
-	// Group the resolutions by project.
+	// Sort the projects for repeatability/stability.
+	projects := make([]string, 0, len(presolution))
+	for p := range presolution {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+
+	switch ctx.format {
+	case "json":
+		return outputJSON(ctx, stdout, projects, presolution)
+	case "csv":
+		return outputCSV(ctx, stdout, projects, presolution)
+	}
+
+	// Output the sorted projects and the source-sharing license conditions that each project resolves.
+	for _, p := range projects {
+		fmt.Fprintf(stdout, "%s", p)
+
+		// Sort the conditions for repeatability/stability.
+		conditions := presolution[p].AsList()
+		sort.Sort(conditions)
+
+		// Output the sorted origin:condition pairs.
+		for _, lc := range conditions {
+			fmt.Fprintf(stdout, ",%s:%s", ctx.paths.OutputName(lc.Origin().Name()), lc.Name())
+		}
+		fmt.Fprintf(stdout, "\n")
+	}
+
+	return nil
+}
+
+// shareProjects groups the resolutions in `shareSource` by the projects they act on.
+func shareProjects(shareSource *compliance.ResolutionSet) map[string]*compliance.LicenseConditionSet {
 	presolution := make(map[string]*compliance.LicenseConditionSet)
 	for _, target := range shareSource.AttachesTo() {
 		rl := shareSource.Resolutions(target)
@@ -97,28 +199,122 @@ func listShare(stdout, stderr io.Writer, files ...string) error {
 			}
 		}
 	}
+	return presolution
+}
 
-	// Sort the projects for repeatability/stability.
-	projects := make([]string, 0, len(presolution))
-	for p := range presolution {
-		projects = append(projects, p)
+// shareFiles groups the source-sharing resolutions in `licenseGraph` under
+// `policy` by the whole projects, marked with a trailing "/", or by the
+// individual source files they require sharing.
+func shareFiles(licenseGraph *compliance.LicenseGraph, policy compliance.Policy) map[string]*compliance.LicenseConditionSet {
+	presolution := make(map[string]*compliance.LicenseConditionSet)
+	add := func(path string, conditions *compliance.LicenseConditionSet) {
+		if _, ok := presolution[path]; !ok {
+			presolution[path] = conditions.Copy()
+			return
+		}
+		presolution[path].AddSet(conditions)
 	}
-	sort.Strings(projects)
 
-	// Output the sorted projects and the source-sharing license conditions that each project resolves.
-	for _, p := range projects {
-		fmt.Fprintf(stdout, "%s", p)
+	// Restricted conditions require sharing whole projects.
+	for p, conditions := range shareProjects(compliance.ResolveRestrictedSourceSharingWithPolicy(licenseGraph, policy)) {
+		add(p+"/", conditions)
+	}
 
-		// Sort the conditions for repeatability/stability.
+	// Reciprocal conditions require sharing only the files used by the target.
+	reciprocal := compliance.ResolveReciprocalSourceSharingWithPolicy(licenseGraph, policy)
+	files, unlisted := compliance.SharedSourceFiles(reciprocal)
+	for f, conditions := range files {
+		add(f, conditions)
+	}
+	for _, target := range unlisted {
+		conditions := reciprocal.ResolutionsByActsOn(target).AllConditions()
+		for _, p := range target.Projects() {
+			add(p+"/", conditions)
+		}
+	}
+	return presolution
+}
+
+// shareInstalled groups the resolutions in `shareSource` by the full installed
+// paths, as they appear in the output, of the targets they act on.
+func shareInstalled(ctx *context, licenseGraph *compliance.LicenseGraph, shareSource *compliance.ResolutionSet) map[string]*compliance.LicenseConditionSet {
+	installPaths := compliance.InstallPaths(licenseGraph)
+	presolution := make(map[string]*compliance.LicenseConditionSet)
+	for _, target := range shareSource.AttachesTo() {
+		for _, r := range shareSource.Resolutions(target) {
+			for _, path := range installPaths[r.ActsOn()] {
+				path = ctx.paths.OutputName(path)
+				if _, ok := presolution[path]; !ok {
+					presolution[path] = r.Resolves().Copy()
+					continue
+				}
+				presolution[path].AddSet(r.Resolves())
+			}
+		}
+	}
+	return presolution
+}
+
+// projectRecord describes why a project must be shared in -format=json output.
+type projectRecord struct {
+	Project    string            `json:"project"`
+	Conditions []conditionRecord `json:"conditions"`
+}
+
+// conditionRecord describes a source-sharing license condition in -format=json output.
+type conditionRecord struct {
+	Origin    string `json:"origin"`
+	Condition string `json:"condition"`
+}
+
+// pathRecord describes why a project, file or installed path must be shared in -per_file or
+// -installed -format=json output.
+type pathRecord struct {
+	Path       string            `json:"path"`
+	Conditions []conditionRecord `json:"conditions"`
+}
+
+// outputJSON writes the sorted `projects` and their conditions from `presolution` to `stdout` as a JSON list.
+func outputJSON(ctx *context, stdout io.Writer, projects []string, presolution map[string]*compliance.LicenseConditionSet) error {
+	projectResult := make([]projectRecord, 0, len(projects))
+	pathResult := make([]pathRecord, 0, len(projects))
+	for _, p := range projects {
 		conditions := presolution[p].AsList()
 		sort.Sort(conditions)
 
-		// Output the sorted origin:condition pairs.
+		records := make([]conditionRecord, 0, len(conditions))
 		for _, lc := range conditions {
-			fmt.Fprintf(stdout, ",%s:%s", lc.Origin().Name(), lc.Name())
+			records = append(records, conditionRecord{ctx.paths.OutputName(lc.Origin().Name()), lc.Name()})
 		}
-		fmt.Fprintf(stdout, "\n")
+		if ctx.perFile || ctx.installed {
+			pathResult = append(pathResult, pathRecord{p, records})
+		} else {
+			projectResult = append(projectResult, projectRecord{p, records})
+		}
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if ctx.perFile || ctx.installed {
+		return enc.Encode(pathResult)
 	}
+	return enc.Encode(projectResult)
+}
 
-	return nil
+// outputCSV writes a header row and one row per project and condition to `stdout`.
+func outputCSV(ctx *context, stdout io.Writer, projects []string, presolution map[string]*compliance.LicenseConditionSet) error {
+	w := csv.NewWriter(stdout)
+	if ctx.perFile || ctx.installed {
+		w.Write([]string{"path", "origin", "condition"})
+	} else {
+		w.Write([]string{"project", "origin", "condition"})
+	}
+	for _, p := range projects {
+		conditions := presolution[p].AsList()
+		sort.Sort(conditions)
+		for _, lc := range conditions {
+			w.Write([]string{p, ctx.paths.OutputName(lc.Origin().Name()), lc.Name()})
+		}
+	}
+	w.Flush()
+	return w.Error()
 }
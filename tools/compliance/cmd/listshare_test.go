@@ -16,6 +16,11 @@ package main
 
 import (
 	"bytes"
+	"compliance"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -380,7 +385,7 @@ func Test(t *testing.T) {
 			for _, r := range tt.roots {
 				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
 			}
-			err := listShare(stdout, stderr, rootFiles...)
+			err := listShare(&context{}, stdout, stderr, rootFiles...)
 			if err != nil {
 				t.Fatalf("listshare: error = %v, stderr = %v", err, stderr)
 				return
@@ -403,3 +408,255 @@ func Test(t *testing.T) {
 		})
 	}
 }
+
+func Test_format(t *testing.T) {
+	rootFiles := []string{"testdata/restricted/bin/bin1.meta_lic"}
+	liba := conditionRecord{"testdata/restricted/lib/liba.so.meta_lic", "restricted"}
+	libc := conditionRecord{"testdata/restricted/lib/libc.a.meta_lic", "reciprocal"}
+
+	t.Run("json", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := listShare(&context{format: "json"}, stdout, stderr, rootFiles...)
+		if err != nil {
+			t.Fatalf("listshare: error = %v, stderr = %v", err, stderr)
+		}
+		var actual []projectRecord
+		if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+			t.Fatalf("listshare: cannot parse %q: %v", stdout, err)
+		}
+		expected := []projectRecord{
+			{"device/library", []conditionRecord{liba}},
+			{"static/binary", []conditionRecord{liba}},
+			{"static/library", []conditionRecord{liba, libc}},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("listshare: got %v, want %v", actual, expected)
+		}
+	})
+	t.Run("csv", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := listShare(&context{format: "csv"}, stdout, stderr, rootFiles...)
+		if err != nil {
+			t.Fatalf("listshare: error = %v, stderr = %v", err, stderr)
+		}
+		actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		expected := []string{
+			"project,origin,condition",
+			"device/library,testdata/restricted/lib/liba.so.meta_lic,restricted",
+			"static/binary,testdata/restricted/lib/liba.so.meta_lic,restricted",
+			"static/library,testdata/restricted/lib/liba.so.meta_lic,restricted",
+			"static/library,testdata/restricted/lib/libc.a.meta_lic,reciprocal",
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("listshare: got %q, want %q", actual, expected)
+		}
+	})
+	t.Run("bad", func(t *testing.T) {
+		err := listShare(&context{format: "xml"}, &bytes.Buffer{}, &bytes.Buffer{}, rootFiles...)
+		if err != failBadFormat {
+			t.Errorf("listshare: got error %v, want %v", err, failBadFormat)
+		}
+	})
+}
+
+func Test_perFile(t *testing.T) {
+	tests := []struct {
+		condition   string
+		name        string
+		roots       []string
+		format      string
+		expectedOut []string
+	}{
+		{
+			condition: "sources",
+			name:      "binary",
+			roots:     []string{"bin/bin1.meta_lic"},
+			expectedOut: []string{
+				"device/library/liba.c,testdata/sources/lib/liba.so.meta_lic:reciprocal",
+				"device/library/liba.h,testdata/sources/lib/liba.so.meta_lic:reciprocal",
+				"static/library/,testdata/sources/lib/libc.a.meta_lic:reciprocal",
+			},
+		},
+		{
+			condition: "sources",
+			name:      "csv",
+			roots:     []string{"bin/bin1.meta_lic"},
+			format:    "csv",
+			expectedOut: []string{
+				"path,origin,condition",
+				"device/library/liba.c,testdata/sources/lib/liba.so.meta_lic,reciprocal",
+				"device/library/liba.h,testdata/sources/lib/liba.so.meta_lic,reciprocal",
+				"static/library/,testdata/sources/lib/libc.a.meta_lic,reciprocal",
+			},
+		},
+		{
+			condition: "reciprocal",
+			name:      "apex",
+			roots:     []string{"highest.apex.meta_lic"},
+			expectedOut: []string{
+				"device/library/,testdata/reciprocal/lib/liba.so.meta_lic:reciprocal",
+				"static/library/,testdata/reciprocal/lib/libc.a.meta_lic:reciprocal",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "binary",
+			roots:     []string{"bin/bin1.meta_lic"},
+			expectedOut: []string{
+				"device/library/,testdata/restricted/lib/liba.so.meta_lic:restricted",
+				"static/binary/,testdata/restricted/lib/liba.so.meta_lic:restricted",
+				"static/library/,testdata/restricted/lib/liba.so.meta_lic:restricted,testdata/restricted/lib/libc.a.meta_lic:reciprocal",
+			},
+		},
+		{
+			condition:   "notice",
+			name:        "binary",
+			roots:       []string{"bin/bin1.meta_lic"},
+			expectedOut: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			err := listShare(&context{format: tt.format, perFile: true}, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("listshare: error = %v, stderr = %v", err, stderr)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("listshare: gotStderr = %v, want none", stderr)
+			}
+			actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("listshare: got %q, want %q", actual, tt.expectedOut)
+			}
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		err := listShare(&context{format: "json", perFile: true}, stdout, stderr, "testdata/sources/bin/bin1.meta_lic")
+		if err != nil {
+			t.Fatalf("listshare: error = %v, stderr = %v", err, stderr)
+		}
+		var actual []pathRecord
+		if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+			t.Fatalf("listshare: cannot parse %q: %v", stdout, err)
+		}
+		liba := conditionRecord{"testdata/sources/lib/liba.so.meta_lic", "reciprocal"}
+		libc := conditionRecord{"testdata/sources/lib/libc.a.meta_lic", "reciprocal"}
+		expected := []pathRecord{
+			{"device/library/liba.c", []conditionRecord{liba}},
+			{"device/library/liba.h", []conditionRecord{liba}},
+			{"static/library/", []conditionRecord{libc}},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("listshare: got %v, want %v", actual, expected)
+		}
+	})
+}
+
+func Test_installed(t *testing.T) {
+	tests := []struct {
+		condition   string
+		name        string
+		roots       []string
+		format      string
+		expectedOut []string
+	}{
+		{
+			condition: "restricted",
+			name:      "apex",
+			roots:     []string{"highest.apex.meta_lic"},
+			expectedOut: []string{
+				"out/target/product/fictional/system/apex/highest.apex,testdata/restricted/lib/liba.so.meta_lic:restricted,testdata/restricted/lib/libb.so.meta_lic:restricted",
+				"out/target/product/fictional/system/apex/highest.apex/bin/bin1,testdata/restricted/lib/liba.so.meta_lic:restricted,testdata/restricted/lib/libb.so.meta_lic:restricted",
+				"out/target/product/fictional/system/apex/highest.apex/lib/liba.so,testdata/restricted/lib/liba.so.meta_lic:restricted",
+				"out/target/product/fictional/system/apex/highest.apex/lib/libb.so,testdata/restricted/lib/libb.so.meta_lic:restricted",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "container",
+			roots:     []string{"container.zip.meta_lic"},
+			format:    "csv",
+			expectedOut: []string{
+				"path,origin,condition",
+				"out/target/product/fictional/data/container.zip,testdata/restricted/lib/liba.so.meta_lic,restricted",
+				"out/target/product/fictional/data/container.zip,testdata/restricted/lib/libb.so.meta_lic,restricted",
+				"out/target/product/fictional/data/container.zip/bin1,testdata/restricted/lib/liba.so.meta_lic,restricted",
+				"out/target/product/fictional/data/container.zip/bin1,testdata/restricted/lib/libb.so.meta_lic,restricted",
+				"out/target/product/fictional/data/container.zip/liba.so,testdata/restricted/lib/liba.so.meta_lic,restricted",
+				"out/target/product/fictional/data/container.zip/libb.so,testdata/restricted/lib/libb.so.meta_lic,restricted",
+			},
+		},
+		{
+			condition:   "notice",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			expectedOut: []string{""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			err := listShare(&context{format: tt.format, installed: true}, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("listshare: error = %v, stderr = %v", err, stderr)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("listshare: gotStderr = %v, want none", stderr)
+			}
+			actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("listshare: got %q, want %q", actual, tt.expectedOut)
+			}
+		})
+	}
+
+	t.Run("per_file", func(t *testing.T) {
+		err := listShare(&context{perFile: true, installed: true}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/highest.apex.meta_lic")
+		if err != failBadMode {
+			t.Errorf("listshare: got error %v, want %v", err, failBadMode)
+		}
+	})
+}
+
+func Test_licenseKinds(t *testing.T) {
+	licenseKinds := filepath.Join(t.TempDir(), "kinds.json")
+	data := `{"license_kinds": [{"kind": "SPDX-license-identifier-MIT", "conditions": ["reciprocal"]}]}`
+	if err := os.WriteFile(licenseKinds, []byte(data), 0644); err != nil {
+		t.Fatalf("unable to write license kinds: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	err := listShare(&context{licenseKinds: licenseKinds, paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}}, stdout, stderr, "testdata/notice/highest.apex.meta_lic")
+	if err != nil {
+		t.Fatalf("listshare: error = %v, stderr = %v", err, stderr)
+	}
+	expected := []string{"static/library,lib/libc.a.meta_lic:reciprocal"}
+	actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("listshare: got %q, want %q", actual, expected)
+	}
+
+	err = listShare(&context{licenseKinds: filepath.Join(t.TempDir(), "missing.json")}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/notice/highest.apex.meta_lic")
+	if err == nil {
+		t.Errorf("listshare: got no error for missing license kinds, want error")
+	}
+}
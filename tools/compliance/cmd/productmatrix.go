@@ -0,0 +1,375 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} -product name=file.meta_lic {-product name=file.meta_lic...}
+
+Reads the license metadata for several products into separate license
+graphs and outputs a matrix comparing their obligations: the projects
+that must be shared, the license texts needed in notices, and the
+conflicts between source-sharing and source privacy conditions.
+
+Each -product names a product and one of its root targets. Repeat the
+flag with the same name to give a product several roots, e.g. the
+partition images it ships. Products appear in the columns in the order
+first given.
+
+The text output has one row per obligation with its kind (share, notice
+or conflict), the project, license text or conflict, and an "X" in the
+column of each product where it applies. Rows that apply to only some
+of the products are product-specific and marked with a leading "*".
+
+When -format=json given, outputs an object with the list of "products"
+and a list of "rows", each a {"kind", "item", "products",
+"product_specific"} object. When -format=csv given, outputs a header row
+followed by one kind,item,product_specific row per obligation with an
+"X" column per product.
+
+Use -strip_prefix and -rewrite_prefix to map the output directories of
+the products onto common names so that the same obligation in different
+products appears on the same row.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+var (
+	products        = newProductRoots("product", "A product and one of its root targets as name=file.meta_lic. (may be repeated)")
+	format          = flag.String("format", "text", "Output format: text, json or csv.")
+	graphCache      = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds    = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	productSpecific = flag.Bool("product_specific", false, "Output only the product-specific rows.")
+	paths           = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo products requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text, json or csv")
+	failExtraArgs     = fmt.Errorf("\nUnexpected arguments: use -product to give the root targets")
+)
+
+// newProductRoots defines a repeatable name=file.meta_lic flag.
+func newProductRoots(name, usage string) *productRoots {
+	var f productRoots
+	flag.Var(&f, name, usage)
+	return &f
+}
+
+// productRoots implements the flag `Value` interface for the root targets of
+// several products.
+type productRoots struct {
+	// names lists the products in the order first given.
+	names []string
+	// roots maps each product to its root targets.
+	roots map[string][]string
+}
+
+// String returns the products as "name=file.meta_lic" pairs.
+func (pr *productRoots) String() string {
+	var pairs []string
+	for _, name := range pr.names {
+		for _, root := range pr.roots[name] {
+			pairs = append(pairs, name+"="+root)
+		}
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Set adds a root target given as "name=file.meta_lic".
+func (pr *productRoots) Set(s string) error {
+	fields := strings.SplitN(s, "=", 2)
+	if len(fields) != 2 || len(fields[0]) == 0 || len(fields[1]) == 0 {
+		return fmt.Errorf("invalid product %q: want name=file.meta_lic", s)
+	}
+	pr.add(fields[0], fields[1])
+	return nil
+}
+
+// add adds `root` to the root targets of product `name`.
+func (pr *productRoots) add(name, root string) {
+	if pr.roots == nil {
+		pr.roots = make(map[string][]string)
+	}
+	if _, ok := pr.roots[name]; !ok {
+		pr.names = append(pr.names, name)
+	}
+	pr.roots[name] = append(pr.roots[name], root)
+}
+
+type context struct {
+	products        *productRoots
+	format          string
+	graphCache      string
+	licenseKinds    string
+	productSpecific bool
+	paths           compliance.PathFlags
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one product.
+	if len(products.names) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{products, *format, *graphCache, *licenseKinds, *productSpecific, *paths}
+
+	err := productMatrix(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested || err == failBadFormat || err == failExtraArgs {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// kinds lists the kinds of obligation in the order they appear in the output.
+var kinds = []string{"share", "notice", "conflict"}
+
+// matrixRow describes an obligation and the products where it applies.
+type matrixRow struct {
+	kind     string
+	item     string
+	products map[string]bool
+}
+
+// isProductSpecific returns true when the row applies to only some of `products`.
+func (row *matrixRow) isProductSpecific(products []string) bool {
+	return len(row.products) < len(products)
+}
+
+// productMatrix implements the productmatrix utility.
+func productMatrix(ctx *context, stdout, stderr io.Writer, args ...string) error {
+	if len(args) > 0 {
+		return failExtraArgs
+	}
+	if ctx.products == nil || len(ctx.products.names) == 0 {
+		return failNoneRequested
+	}
+	switch ctx.format {
+	case "", "text", "json", "csv":
+	default:
+		return failBadFormat
+	}
+
+	kindMap, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+	policy := kindMap.Policy()
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read a separate license graph for each product and collect its obligations.
+	rows := make(map[string]map[string]*matrixRow)
+	for _, kind := range kinds {
+		rows[kind] = make(map[string]*matrixRow)
+	}
+	for _, product := range ctx.products.names {
+		files := ctx.products.roots[product]
+		licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kindMap)
+		if err != nil {
+			return fmt.Errorf("Unable to read license metadata file(s) %q for product %q: %v\n", files, product, err)
+		}
+		if licenseGraph == nil {
+			return failNoLicenses
+		}
+
+		obligations := map[string][]string{
+			"share":    shareItems(licenseGraph, policy),
+			"notice":   noticeItems(ctx, licenseGraph, policy),
+			"conflict": conflictItems(ctx, licenseGraph, policy),
+		}
+		for kind, items := range obligations {
+			for _, item := range items {
+				row, ok := rows[kind][item]
+				if !ok {
+					row = &matrixRow{kind, item, make(map[string]bool)}
+					rows[kind][item] = row
+				}
+				row.products[product] = true
+			}
+		}
+	}
+
+	// Sort the rows by kind and then by item for repeatability/stability.
+	matrix := make([]*matrixRow, 0)
+	for _, kind := range kinds {
+		items := make([]string, 0, len(rows[kind]))
+		for item := range rows[kind] {
+			items = append(items, item)
+		}
+		sort.Strings(items)
+		for _, item := range items {
+			row := rows[kind][item]
+			if ctx.productSpecific && !row.isProductSpecific(ctx.products.names) {
+				continue
+			}
+			matrix = append(matrix, row)
+		}
+	}
+
+	switch ctx.format {
+	case "json":
+		return outputJSON(ctx, stdout, matrix)
+	case "csv":
+		return outputCSV(ctx, stdout, matrix)
+	}
+
+	// Output the matrix with aligned columns.
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, " \tkind\titem\t%s\n", strings.Join(ctx.products.names, "\t"))
+	for _, row := range matrix {
+		marker := " "
+		if row.isProductSpecific(ctx.products.names) {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s", marker, row.kind, row.item)
+		for _, product := range ctx.products.names {
+			if row.products[product] {
+				fmt.Fprintf(w, "\tX")
+			} else {
+				fmt.Fprintf(w, "\t-")
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	return w.Flush()
+}
+
+// shareItems returns the projects that must be shared in `licenseGraph`.
+func shareItems(licenseGraph *compliance.LicenseGraph, policy compliance.Policy) []string {
+	shareSource := compliance.ResolveSourceSharingWithPolicy(licenseGraph, policy)
+	projects := make(map[string]bool)
+	for _, target := range shareSource.AttachesTo() {
+		for _, r := range shareSource.Resolutions(target) {
+			for _, p := range r.ActsOn().Projects() {
+				projects[p] = true
+			}
+		}
+	}
+	return keys(projects)
+}
+
+// noticeItems returns the license texts, as they appear in the output, needed in
+// the notices for `licenseGraph`.
+func noticeItems(ctx *context, licenseGraph *compliance.LicenseGraph, policy compliance.Policy) []string {
+	notices := compliance.ResolveNoticesWithPolicy(licenseGraph, policy)
+	texts := make(map[string]bool)
+	for _, target := range notices.AttachesTo() {
+		for _, r := range notices.Resolutions(target) {
+			for _, text := range r.ActsOn().LicenseTexts() {
+				texts[ctx.paths.OutputName(text)] = true
+			}
+		}
+	}
+	return keys(texts)
+}
+
+// conflictItems returns the conflicts, as they appear in the output, between
+// source-sharing and source privacy conditions in `licenseGraph`.
+func conflictItems(ctx *context, licenseGraph *compliance.LicenseGraph, policy compliance.Policy) []string {
+	conflicts := make(map[string]bool)
+	for _, conflict := range compliance.ConflictingSharedPrivateSourceWithPolicy(licenseGraph, policy) {
+		conflicts[fmt.Sprintf("%s %s from %s and must share from %s %s",
+			ctx.paths.OutputName(conflict.SourceNode.Name()),
+			conflict.PrivacyCondition.Name(), ctx.paths.OutputName(conflict.PrivacyCondition.Origin().Name()),
+			conflict.ShareCondition.Name(), ctx.paths.OutputName(conflict.ShareCondition.Origin().Name()))] = true
+	}
+	return keys(conflicts)
+}
+
+// keys returns the keys of `set`.
+func keys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for k := range set {
+		result = append(result, k)
+	}
+	return result
+}
+
+// rowRecord describes an obligation in -format=json output.
+type rowRecord struct {
+	Kind            string   `json:"kind"`
+	Item            string   `json:"item"`
+	Products        []string `json:"products"`
+	ProductSpecific bool     `json:"product_specific"`
+}
+
+// matrixRecord describes the whole matrix in -format=json output.
+type matrixRecord struct {
+	Products []string    `json:"products"`
+	Rows     []rowRecord `json:"rows"`
+}
+
+// outputJSON writes the `matrix` to `stdout` as a JSON object.
+func outputJSON(ctx *context, stdout io.Writer, matrix []*matrixRow) error {
+	result := matrixRecord{ctx.products.names, make([]rowRecord, 0, len(matrix))}
+	for _, row := range matrix {
+		products := make([]string, 0, len(row.products))
+		for _, product := range ctx.products.names {
+			if row.products[product] {
+				products = append(products, product)
+			}
+		}
+		result.Rows = append(result.Rows, rowRecord{row.kind, row.item, products, row.isProductSpecific(ctx.products.names)})
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// outputCSV writes a header row and one row per obligation to `stdout`.
+func outputCSV(ctx *context, stdout io.Writer, matrix []*matrixRow) error {
+	w := csv.NewWriter(stdout)
+	w.Write(append([]string{"kind", "item", "product_specific"}, ctx.products.names...))
+	for _, row := range matrix {
+		record := []string{row.kind, row.item, fmt.Sprintf("%t", row.isProductSpecific(ctx.products.names))}
+		for _, product := range ctx.products.names {
+			if row.products[product] {
+				record = append(record, "X")
+			} else {
+				record = append(record, "")
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,212 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestContext returns a context comparing the testdata trees in `conditions` as products
+// rooted at `root` with the tree names rewritten away so common obligations share rows.
+func newTestContext(format string, productSpecific bool, root string, conditions ...string) *context {
+	pr := &productRoots{}
+	rewrites := make(compliance.PrefixRewrites)
+	for _, condition := range conditions {
+		pr.add(condition, "testdata/"+condition+"/"+root)
+		rewrites[condition+"/"] = ""
+	}
+	return &context{pr, format, "", "", productSpecific, compliance.PathFlags{StripPrefix: "testdata/", Rewrites: rewrites}}
+}
+
+func Test(t *testing.T) {
+	tests := []struct {
+		name            string
+		root            string
+		conditions      []string
+		productSpecific bool
+		expectedOut     []string
+	}{
+		{
+			name:       "single",
+			root:       "bin/bin1.meta_lic",
+			conditions: []string{"notice"},
+			expectedOut: []string{
+				"kind item notice",
+				"notice build/soong/licenses/LICENSE X",
+			},
+		},
+		{
+			name:       "binary",
+			root:       "bin/bin1.meta_lic",
+			conditions: []string{"reciprocal", "restricted"},
+			expectedOut: []string{
+				"kind item reciprocal restricted",
+				"share device/library X X",
+				"* share static/binary - X",
+				"share static/library X X",
+				"notice build/soong/licenses/LICENSE X X",
+			},
+		},
+		{
+			name:       "apex",
+			root:       "highest.apex.meta_lic",
+			conditions: []string{"notice", "reciprocal", "restricted", "proprietary"},
+			expectedOut: []string{
+				"kind item notice reciprocal restricted proprietary",
+				"* share base/library - - X X",
+				"* share device/library - X X -",
+				"* share dynamic/binary - - X X",
+				"* share highest/apex - - X X",
+				"* share static/binary - - X -",
+				"* share static/library - X X -",
+				"notice build/soong/licenses/LICENSE X X X X",
+				"* conflict bin/bin2.meta_lic proprietary from bin/bin2.meta_lic and must share from restricted lib/libb.so.meta_lic - - - X",
+			},
+		},
+		{
+			name:            "productspecific",
+			root:            "bin/bin1.meta_lic",
+			conditions:      []string{"reciprocal", "restricted"},
+			productSpecific: true,
+			expectedOut: []string{
+				"kind item reciprocal restricted",
+				"* share static/binary - X",
+			},
+		},
+		{
+			name:       "notices",
+			root:       "highest.apex.meta_lic",
+			conditions: []string{"notice", "notices"},
+			expectedOut: []string{
+				"kind item notice notices",
+				"* share static/library - X",
+				"* notice COPY_OF_NOTICE_LICENSE - X",
+				"* notice FIRST_PARTY_LICENSE - X",
+				"* notice NOTICE_LICENSE - X",
+				"* notice RECIPROCAL_LICENSE - X",
+				"* notice build/soong/licenses/LICENSE X -",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			ctx := newTestContext("text", tt.productSpecific, tt.root, tt.conditions...)
+			err := productMatrix(ctx, stdout, stderr)
+			if err != nil {
+				t.Fatalf("productmatrix: error = %v, stderr = %v", err, stderr)
+				return
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("productmatrix: gotStderr = %v, want none", stderr)
+			}
+
+			// Compare the fields of each line to ignore the column alignment.
+			out := strings.TrimRight(stdout.String(), "\n")
+			lines := strings.Split(out, "\n")
+			actual := make([]string, 0, len(lines))
+			for _, line := range lines {
+				actual = append(actual, strings.Join(strings.Fields(line), " "))
+			}
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("productmatrix: got:\n%s\nwant:\n%s", strings.Join(actual, "\n"), strings.Join(tt.expectedOut, "\n"))
+			}
+		})
+	}
+}
+
+func Test_formats(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		ctx := newTestContext("json", false, "bin/bin1.meta_lic", "reciprocal", "restricted")
+		if err := productMatrix(ctx, stdout, stderr); err != nil {
+			t.Fatalf("productmatrix: error = %v, stderr = %v", err, stderr)
+		}
+		var actual matrixRecord
+		if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+			t.Fatalf("productmatrix: invalid json %q: %v", stdout.String(), err)
+		}
+		both := []string{"reciprocal", "restricted"}
+		restricted := []string{"restricted"}
+		expected := matrixRecord{
+			Products: both,
+			Rows: []rowRecord{
+				{"share", "device/library", both, false},
+				{"share", "static/binary", restricted, true},
+				{"share", "static/library", both, false},
+				{"notice", "build/soong/licenses/LICENSE", both, false},
+			},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("productmatrix: got %#v, want %#v", actual, expected)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+
+		ctx := newTestContext("csv", true, "bin/bin1.meta_lic", "reciprocal", "restricted")
+		if err := productMatrix(ctx, stdout, stderr); err != nil {
+			t.Fatalf("productmatrix: error = %v, stderr = %v", err, stderr)
+		}
+		actual, err := csv.NewReader(stdout).ReadAll()
+		if err != nil {
+			t.Fatalf("productmatrix: invalid csv %q: %v", stdout.String(), err)
+		}
+		expected := [][]string{
+			{"kind", "item", "product_specific", "reciprocal", "restricted"},
+			{"share", "static/binary", "true", "", "X"},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("productmatrix: got %q, want %q", actual, expected)
+		}
+	})
+}
+
+func Test_errors(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	if err := productMatrix(&context{products: &productRoots{}}, stdout, stderr); err != failNoneRequested {
+		t.Errorf("productmatrix: no products: got %v, want %v", err, failNoneRequested)
+	}
+
+	ctx := newTestContext("xml", false, "bin/bin1.meta_lic", "notice")
+	if err := productMatrix(ctx, stdout, stderr); err != failBadFormat {
+		t.Errorf("productmatrix: bad format: got %v, want %v", err, failBadFormat)
+	}
+
+	ctx = newTestContext("text", false, "bin/bin1.meta_lic", "notice")
+	if err := productMatrix(ctx, stdout, stderr, "testdata/notice/bin/bin1.meta_lic"); err != failExtraArgs {
+		t.Errorf("productmatrix: extra args: got %v, want %v", err, failExtraArgs)
+	}
+
+	for _, s := range []string{"bin1.meta_lic", "=bin1.meta_lic", "notice="} {
+		if err := (&productRoots{}).Set(s); err == nil {
+			t.Errorf("productmatrix: Set(%q): got no error, want error", s)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	query        = flag.String("query", "", "Query selecting the targets to output. (required)")
+	format       = flag.String("format", "text", "Output format: text or json.")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failNoQuery       = fmt.Errorf("\n-query is required")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text or json")
+)
+
+type context struct {
+	query        string
+	format       string
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} -query query file.meta_lic {file.meta_lic...}
+
+Outputs the name of each target in the license graph matching the query,
+one per line in name order.
+
+A query combines terms with and, or, not and parentheses. Adjacent terms
+are and-ed together. Terms are field:pattern where * in the pattern
+matches anything and ? matches any one character, or the bare keywords
+shipped, root and container. The fields are name, package, project,
+module_type, module_class, kind (license kind), condition (originating
+at the target), resolved (acted on by the target), annotation (of edges
+depending on the target), reaches (target names the target depends on)
+and from (target names depending on the target).
+
+e.g. all shipped targets under vendor/ with restricted conditions that
+are dynamically linked:
+
+  -query 'shipped project:vendor/* condition:restricted annotation:dynamic'
+
+When -format=json given, outputs a list of {"name", "package",
+"projects", "module_types", "module_classes", "license_kinds",
+"license_conditions"} objects.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*query, *format, *graphCache, *licenseKinds, *paths}
+
+	err := queryGraph(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested || err == failNoQuery || err == failBadFormat {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// targetRecord describes a matching target in -format=json output.
+type targetRecord struct {
+	Name              string   `json:"name"`
+	Package           string   `json:"package"`
+	Projects          []string `json:"projects"`
+	ModuleTypes       []string `json:"module_types"`
+	ModuleClasses     []string `json:"module_classes"`
+	LicenseKinds      []string `json:"license_kinds"`
+	LicenseConditions []string `json:"license_conditions"`
+}
+
+// queryGraph implements the querygraph utility.
+func queryGraph(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	if len(strings.TrimSpace(ctx.query)) == 0 {
+		return failNoQuery
+	}
+	switch ctx.format {
+	case "", "text", "json":
+	default:
+		return failBadFormat
+	}
+
+	q, err := compliance.ParseQuery(ctx.query)
+	if err != nil {
+		return err
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	targets := q.Select(licenseGraph, kinds.Policy())
+
+	if ctx.format == "json" {
+		result := make([]targetRecord, 0, len(targets))
+		for _, tn := range targets {
+			result = append(result, targetRecord{
+				Name:              ctx.paths.OutputName(tn.Name()),
+				Package:           tn.PackageName(),
+				Projects:          sorted(tn.Projects()),
+				ModuleTypes:       sorted(tn.ModuleTypes()),
+				ModuleClasses:     sorted(tn.ModuleClasses()),
+				LicenseKinds:      sorted(tn.LicenseKinds()),
+				LicenseConditions: sorted(tn.LicenseConditions().Names()),
+			})
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	for _, tn := range targets {
+		fmt.Fprintln(stdout, ctx.paths.OutputName(tn.Name()))
+	}
+	return nil
+}
+
+// sorted returns `values` in lexical order.
+func sorted(values []string) []string {
+	sort.Strings(values)
+	return values
+}
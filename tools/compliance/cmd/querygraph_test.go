@@ -0,0 +1,189 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition   string
+		name        string
+		roots       []string
+		query       string
+		expectedOut []string
+	}{
+		{
+			condition:   "restricted",
+			name:        "application",
+			roots:       []string{"application.meta_lic"},
+			query:       "condition:restricted",
+			expectedOut: []string{"bin/bin3.meta_lic", "lib/liba.so.meta_lic", "lib/libb.so.meta_lic"},
+		},
+		{
+			condition:   "restricted",
+			name:        "application",
+			roots:       []string{"application.meta_lic"},
+			query:       "shipped condition:restricted",
+			expectedOut: []string{"lib/liba.so.meta_lic"},
+		},
+		{
+			condition:   "restricted",
+			name:        "application",
+			roots:       []string{"application.meta_lic"},
+			query:       "condition:restricted and annotation:dynamic",
+			expectedOut: []string{"lib/libb.so.meta_lic"},
+		},
+		{
+			condition:   "restricted",
+			name:        "application",
+			roots:       []string{"application.meta_lic"},
+			query:       "module_class:EXECUTABLES not root",
+			expectedOut: []string{"bin/bin3.meta_lic"},
+		},
+		{
+			condition:   "restricted",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			query:       "project:*/library",
+			expectedOut: []string{"lib/liba.so.meta_lic", "lib/libb.so.meta_lic", "lib/libc.a.meta_lic", "lib/libd.so.meta_lic"},
+		},
+		{
+			condition:   "restricted",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			query:       "from:*/bin2.meta_lic or container",
+			expectedOut: []string{"highest.apex.meta_lic", "lib/libb.so.meta_lic", "lib/libd.so.meta_lic"},
+		},
+		{
+			condition:   "notice",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			query:       "condition:restricted",
+			expectedOut: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name+" "+tt.query, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			ctx := &context{query: tt.query, paths: compliance.PathFlags{StripPrefix: "testdata/" + tt.condition + "/"}}
+			err := queryGraph(ctx, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("querygraph: error = %v, stderr = %v", err, stderr)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("querygraph: gotStderr = %v, want none", stderr)
+			}
+			actual := make([]string, 0)
+			for _, line := range strings.Split(stdout.String(), "\n") {
+				if len(line) > 0 {
+					actual = append(actual, line)
+				}
+			}
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("querygraph: got %q, want %q", actual, tt.expectedOut)
+			}
+		})
+	}
+}
+
+func Test_json(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{query: "root", format: "json", paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}
+	err := queryGraph(ctx, stdout, stderr, "testdata/restricted/application.meta_lic")
+	if err != nil {
+		t.Fatalf("querygraph: error = %v, stderr = %v", err, stderr)
+	}
+	var actual []targetRecord
+	if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+		t.Fatalf("querygraph: cannot parse %q: %v", stdout, err)
+	}
+	expected := []targetRecord{
+		{
+			Name:              "application.meta_lic",
+			Package:           "Android",
+			Projects:          []string{"distributable/application"},
+			ModuleTypes:       []string{},
+			ModuleClasses:     []string{"EXECUTABLES"},
+			LicenseKinds:      []string{"SPDX-license-identifier-Apache-2.0"},
+			LicenseConditions: []string{"notice"},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("querygraph: got %+v, want %+v", actual, expected)
+	}
+}
+
+func Test_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      *context
+		expected string
+	}{
+		{"no query", &context{query: " "}, failNoQuery.Error()},
+		{"bad format", &context{query: "root", format: "csv"}, failBadFormat.Error()},
+		{"bad query", &context{query: "root and"}, "unexpected end of query"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queryGraph(tt.ctx, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/application.meta_lic")
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("querygraph: got error %v, want %q", err, tt.expected)
+			}
+		})
+	}
+}
+
+func Test_licenseKinds(t *testing.T) {
+	licenseKinds := filepath.Join(t.TempDir(), "kinds.json")
+	data := `{"license_kinds": [{"kind": "SPDX-license-identifier-MIT", "conditions": ["reciprocal"]}]}`
+	if err := os.WriteFile(licenseKinds, []byte(data), 0644); err != nil {
+		t.Fatalf("unable to write license kinds: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{query: "condition:reciprocal", licenseKinds: licenseKinds, paths: compliance.PathFlags{StripPrefix: "testdata/notice/"}}
+	err := queryGraph(ctx, stdout, stderr, "testdata/notice/highest.apex.meta_lic")
+	if err != nil {
+		t.Fatalf("querygraph: error = %v, stderr = %v", err, stderr)
+	}
+	expected := []string{"lib/libc.a.meta_lic", "lib/libd.so.meta_lic"}
+	actual := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("querygraph: got %q, want %q", actual, expected)
+	}
+
+	ctx = &context{query: "root", licenseKinds: filepath.Join(t.TempDir(), "missing.json")}
+	err = queryGraph(ctx, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/notice/highest.apex.meta_lic")
+	if err == nil {
+		t.Errorf("querygraph: got no error for missing license kinds, want error")
+	}
+}
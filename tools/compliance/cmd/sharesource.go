@@ -0,0 +1,331 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compliance"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	outputFile   = flag.String("o", "", "Path to the .tar or .zip archive to write. (required)")
+	sourceTree   = flag.String("source_tree", ".", "Directory to read the project files from.")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failBadOutput     = fmt.Errorf("\n-o must name a file ending in .tar or .zip")
+)
+
+// manifestName is the name of the manifest file at the top of the archive.
+const manifestName = "manifest.json"
+
+// modTime is the fixed modification time of every file in the archive so
+// that archives of the same sources are identical.
+var modTime = time.Date(2008, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type context struct {
+	outputFile   string
+	sourceFS     fs.FS
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Writes a tar or zip archive of every file in each project that must
+share source code, i.e. each project listshare would list, read from the
+source tree.
+
+The archive starts with a %s file mapping each project to the
+origin:condition pairs that require sharing it:
+
+  {
+    "projects": [
+      {
+        "project": "device/library",
+        "conditions": [
+          {"origin": "lib/liba.so.meta_lic", "condition": "restricted"}
+        ]
+      }
+    ]
+  }
+
+The entries follow in project order with the files of each project in
+lexical order, all with the same fixed modification time, so archiving
+the same sources always produces the same bytes.
+
+Projects containing symlinks or other special files fail to archive
+rather than silently leaving those files out.
+
+The -o file extension selects the archive type.
+
+Options:
+`, filepath.Base(os.Args[0]), manifestName)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*outputFile, os.DirFS(*sourceTree), *graphCache, *licenseKinds, *paths}
+
+	err := shareSource(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested || err == failBadOutput {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// manifest describes the projects in the archive.
+type manifest struct {
+	Projects []projectRecord `json:"projects"`
+}
+
+// projectRecord describes why a project must be shared.
+type projectRecord struct {
+	Project    string            `json:"project"`
+	Conditions []conditionRecord `json:"conditions"`
+}
+
+// conditionRecord describes a source-sharing license condition.
+type conditionRecord struct {
+	Origin    string `json:"origin"`
+	Condition string `json:"condition"`
+}
+
+// archiveWriter abstracts over the tar and zip archive formats.
+type archiveWriter interface {
+	// add writes the file `name` with `mode` and `data` to the archive.
+	add(name string, mode fs.FileMode, data []byte) error
+
+	// Close finishes the archive.
+	Close() error
+}
+
+// tarWriter implements archiveWriter for tar archives.
+type tarWriter struct {
+	*tar.Writer
+}
+
+func (w tarWriter) add(name string, mode fs.FileMode, data []byte) error {
+	err := w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(mode),
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// zipWriter implements archiveWriter for zip archives.
+type zipWriter struct {
+	*zip.Writer
+}
+
+func (w zipWriter) add(name string, mode fs.FileMode, data []byte) error {
+	fh := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+	fh.SetMode(mode)
+	f, err := w.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// shareSource implements the sharesource utility.
+func shareSource(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	// Must be at least one root file.
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	if !strings.HasSuffix(ctx.outputFile, ".tar") && !strings.HasSuffix(ctx.outputFile, ".zip") {
+		return failBadOutput
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	// shareSource contains all source-sharing resolutions.
+	shareSource := compliance.ResolveSourceSharingWithPolicy(licenseGraph, kinds.Policy())
+
+	// Group the resolutions by project.
+	presolution := make(map[string]*compliance.LicenseConditionSet)
+	for _, target := range shareSource.AttachesTo() {
+		for _, r := range shareSource.Resolutions(target) {
+			for _, p := range r.ActsOn().Projects() {
+				if _, ok := presolution[p]; !ok {
+					presolution[p] = r.Resolves().Copy()
+					continue
+				}
+				presolution[p].AddSet(r.Resolves())
+			}
+		}
+	}
+
+	// Sort the projects for repeatability/stability.
+	projects := make([]string, 0, len(presolution))
+	for p := range presolution {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+
+	m := manifest{make([]projectRecord, 0, len(projects))}
+	for _, p := range projects {
+		conditions := presolution[p].AsList()
+		sort.Sort(conditions)
+
+		pr := projectRecord{p, make([]conditionRecord, 0, len(conditions))}
+		for _, lc := range conditions {
+			pr.Conditions = append(pr.Conditions, conditionRecord{ctx.paths.OutputName(lc.Origin().Name()), lc.Name()})
+		}
+		m.Projects = append(m.Projects, pr)
+	}
+	manifestData, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to create manifest: %w", err)
+	}
+
+	// Write the archive to a temporary file renamed into place when complete.
+	tmp, err := os.CreateTemp(filepath.Dir(ctx.outputFile), filepath.Base(ctx.outputFile)+".*")
+	if err != nil {
+		return fmt.Errorf("Unable to create %q: %w", ctx.outputFile, err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var w archiveWriter
+	if strings.HasSuffix(ctx.outputFile, ".zip") {
+		w = zipWriter{zip.NewWriter(tmp)}
+	} else {
+		w = tarWriter{tar.NewWriter(tmp)}
+	}
+	err = w.add(manifestName, 0644, append(manifestData, '\n'))
+	if err != nil {
+		return fmt.Errorf("Unable to write %q: %w", ctx.outputFile, err)
+	}
+	err = archiveProjects(ctx.sourceFS, w, projects)
+	if err != nil {
+		return fmt.Errorf("Unable to write %q: %w", ctx.outputFile, err)
+	}
+	err = w.Close()
+	if err != nil {
+		return fmt.Errorf("Unable to write %q: %w", ctx.outputFile, err)
+	}
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("Unable to write %q: %w", ctx.outputFile, err)
+	}
+	// os.CreateTemp creates files readable only by the owner.
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to write %q: %w", ctx.outputFile, err)
+	}
+	err = os.Rename(tmp.Name(), ctx.outputFile)
+	if err != nil {
+		return fmt.Errorf("Unable to write %q: %w", ctx.outputFile, err)
+	}
+	return nil
+}
+
+// archiveProjects adds the regular files of each of `projects` in `sourceFS`
+// to `w` in lexical order.
+//
+// Files of nested projects get added only once. Symlinks and other special
+// files cannot be shared as source and return an error.
+func archiveProjects(sourceFS fs.FS, w archiveWriter, projects []string) error {
+	added := make(map[string]bool)
+	for _, p := range projects {
+		err := fs.WalkDir(sourceFS, p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || added[path] {
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return fmt.Errorf("%q is not a regular file", path)
+			}
+			added[path] = true
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			data, err := fs.ReadFile(sourceFS, path)
+			if err != nil {
+				return err
+			}
+			mode := fs.FileMode(0644)
+			if fi.Mode()&0111 != 0 {
+				mode = 0755
+			}
+			return w.add(path, mode, data)
+		})
+		if err != nil {
+			return fmt.Errorf("project %q: %w", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testSourceTree is a test source tree with the projects in testdata.
+var testSourceTree = fstest.MapFS{
+	"device/library/liba.c":         {Data: []byte("liba\n")},
+	"device/library/include/liba.h": {Data: []byte("liba.h\n")},
+	"static/binary/bin1.c":          {Data: []byte("bin1\n")},
+	"static/binary/run.sh":          {Data: []byte("#!/bin/sh\n"), Mode: 0755},
+	"static/library/libc.c":         {Data: []byte("libc\n")},
+	"base/library/libb.c":           {Data: []byte("libb\n")},
+	"dynamic/library/libd.c":        {Data: []byte("libd\n")},
+	"highest/apex/apex.txt":         {Data: []byte("apex\n")},
+	"distributable/application/a.c": {Data: []byte("app\n")},
+	"container/zip/zip.txt":         {Data: []byte("zip\n")},
+}
+
+// archiveEntry describes a file read back from an archive.
+type archiveEntry struct {
+	name string
+	mode os.FileMode
+	data string
+}
+
+// readArchive returns the entries of the tar or zip archive `path`.
+func readArchive(t *testing.T, path string) []archiveEntry {
+	result := make([]archiveEntry, 0)
+	if filepath.Ext(path) == ".zip" {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			t.Fatalf("sharesource: cannot open %q: %v", path, err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("sharesource: cannot open %q in %q: %v", f.Name, path, err)
+			}
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("sharesource: cannot read %q in %q: %v", f.Name, path, err)
+			}
+			result = append(result, archiveEntry{f.Name, f.Mode().Perm(), string(data)})
+		}
+		return result
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("sharesource: cannot open %q: %v", path, err)
+	}
+	defer f.Close()
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("sharesource: cannot read %q: %v", path, err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("sharesource: cannot read %q in %q: %v", h.Name, path, err)
+		}
+		result = append(result, archiveEntry{h.Name, os.FileMode(h.Mode).Perm(), string(data)})
+	}
+	return result
+}
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition        string
+		name             string
+		roots            []string
+		expectedProjects []projectRecord
+		expectedFiles    []archiveEntry
+	}{
+		{
+			condition:        "notice",
+			name:             "apex",
+			roots:            []string{"highest.apex.meta_lic"},
+			expectedProjects: []projectRecord{},
+			expectedFiles:    []archiveEntry{},
+		},
+		{
+			condition: "reciprocal",
+			name:      "application",
+			roots:     []string{"application.meta_lic"},
+			expectedProjects: []projectRecord{
+				{"device/library", []conditionRecord{{"testdata/reciprocal/lib/liba.so.meta_lic", "reciprocal"}}},
+			},
+			expectedFiles: []archiveEntry{
+				{"device/library/include/liba.h", 0644, "liba.h\n"},
+				{"device/library/liba.c", 0644, "liba\n"},
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "binary",
+			roots:     []string{"bin/bin1.meta_lic"},
+			expectedProjects: []projectRecord{
+				{"device/library", []conditionRecord{{"testdata/restricted/lib/liba.so.meta_lic", "restricted"}}},
+				{"static/binary", []conditionRecord{{"testdata/restricted/lib/liba.so.meta_lic", "restricted"}}},
+				{"static/library", []conditionRecord{
+					{"testdata/restricted/lib/liba.so.meta_lic", "restricted"},
+					{"testdata/restricted/lib/libc.a.meta_lic", "reciprocal"},
+				}},
+			},
+			expectedFiles: []archiveEntry{
+				{"device/library/include/liba.h", 0644, "liba.h\n"},
+				{"device/library/liba.c", 0644, "liba\n"},
+				{"static/binary/bin1.c", 0644, "bin1\n"},
+				{"static/binary/run.sh", 0755, "#!/bin/sh\n"},
+				{"static/library/libc.c", 0644, "libc\n"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, ext := range []string{".tar", ".zip"} {
+			t.Run(tt.condition+" "+tt.name+ext, func(t *testing.T) {
+				rootFiles := make([]string, 0, len(tt.roots))
+				for _, r := range tt.roots {
+					rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+				}
+				outputFile := filepath.Join(t.TempDir(), "source"+ext)
+				stdout := &bytes.Buffer{}
+				stderr := &bytes.Buffer{}
+				err := shareSource(&context{outputFile: outputFile, sourceFS: testSourceTree}, stdout, stderr, rootFiles...)
+				if err != nil {
+					t.Fatalf("sharesource: error = %v, stderr = %v", err, stderr)
+				}
+				if stderr.Len() > 0 {
+					t.Errorf("sharesource: gotStderr = %v, want none", stderr)
+				}
+				if fi, err := os.Stat(outputFile); err != nil || fi.Mode().Perm() != 0644 {
+					t.Errorf("sharesource: got %q stat %v, %v, want mode %v", outputFile, fi, err, os.FileMode(0644))
+				}
+
+				entries := readArchive(t, outputFile)
+				if len(entries) == 0 || entries[0].name != manifestName {
+					t.Fatalf("sharesource: got entries %v, want %s first", entries, manifestName)
+				}
+				var m manifest
+				if err := json.Unmarshal([]byte(entries[0].data), &m); err != nil {
+					t.Fatalf("sharesource: cannot parse manifest %q: %v", entries[0].data, err)
+				}
+				if !reflect.DeepEqual(m.Projects, tt.expectedProjects) {
+					t.Errorf("sharesource: got projects %v, want %v", m.Projects, tt.expectedProjects)
+				}
+				if !reflect.DeepEqual(entries[1:], tt.expectedFiles) {
+					t.Errorf("sharesource: got files %v, want %v", entries[1:], tt.expectedFiles)
+				}
+			})
+		}
+	}
+}
+
+func Test_deterministic(t *testing.T) {
+	for _, ext := range []string{".tar", ".zip"} {
+		t.Run(ext, func(t *testing.T) {
+			dir := t.TempDir()
+			contents := make([][]byte, 0, 2)
+			for _, name := range []string{"first" + ext, "second" + ext} {
+				outputFile := filepath.Join(dir, name)
+				stderr := &bytes.Buffer{}
+				err := shareSource(&context{outputFile: outputFile, sourceFS: testSourceTree}, &bytes.Buffer{}, stderr, "testdata/restricted/bin/bin1.meta_lic")
+				if err != nil {
+					t.Fatalf("sharesource: error = %v, stderr = %v", err, stderr)
+				}
+				data, err := os.ReadFile(outputFile)
+				if err != nil {
+					t.Fatalf("sharesource: cannot read %q: %v", outputFile, err)
+				}
+				contents = append(contents, data)
+			}
+			if !bytes.Equal(contents[0], contents[1]) {
+				t.Errorf("sharesource: archives differ between runs")
+			}
+		})
+	}
+}
+
+func Test_errors(t *testing.T) {
+	t.Run("bad output", func(t *testing.T) {
+		err := shareSource(&context{outputFile: "source.tgz", sourceFS: testSourceTree}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/bin/bin1.meta_lic")
+		if err != failBadOutput {
+			t.Errorf("sharesource: got error %v, want %v", err, failBadOutput)
+		}
+	})
+	t.Run("missing project", func(t *testing.T) {
+		outputFile := filepath.Join(t.TempDir(), "source.tar")
+		err := shareSource(&context{outputFile: outputFile, sourceFS: fstest.MapFS{}}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/bin/bin1.meta_lic")
+		if err == nil {
+			t.Fatalf("sharesource: got no error, want missing project error")
+		}
+		if _, err := os.Stat(outputFile); err == nil {
+			t.Errorf("sharesource: got partial archive %q, want none", outputFile)
+		}
+	})
+	t.Run("symlink", func(t *testing.T) {
+		sourceFS := fstest.MapFS{}
+		for name, f := range testSourceTree {
+			sourceFS[name] = f
+		}
+		sourceFS["static/library/libc.h"] = &fstest.MapFile{Data: []byte("../include/libc.h"), Mode: fs.ModeSymlink}
+		outputFile := filepath.Join(t.TempDir(), "source.tar")
+		err := shareSource(&context{outputFile: outputFile, sourceFS: sourceFS}, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/bin/bin1.meta_lic")
+		if err == nil || !strings.Contains(err.Error(), "not a regular file") {
+			t.Errorf("sharesource: got error %v, want not a regular file", err)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	format       = flag.String("format", "tagvalue", "Output format: tagvalue or json.")
+	documentName = flag.String("name", "", "Name of the SPDX document. (defaults to the first root)")
+	namespace    = flag.String("namespace", "", "Unique URI for the SPDX document. (defaults to a URI derived from the name)")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+)
+
+type context struct {
+	format       string
+	documentName string
+	namespace    string
+	created      time.Time
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Outputs an SPDX 2.3 software bill of materials describing the license
+graph rooted at the given license metadata files.
+
+Each target becomes a package with its license kinds, projects, license
+texts and installed files. Each dependency becomes a relationship with
+a type derived from the edge annotations: BUILD_TOOL_OF for toolchain,
+DYNAMIC_LINK for dynamic, CONTAINS for containers and STATIC_LINK
+otherwise.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*format, *documentName, *namespace, time.Now(), *graphCache, *licenseKinds, *paths}
+
+	err := spdxSBOM(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// spdxSBOM implements the spdxsbom utility.
+func spdxSBOM(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	if ctx.format != "tagvalue" && ctx.format != "json" {
+		return fmt.Errorf("Unknown output format %q: want tagvalue or json", ctx.format)
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	name := ctx.documentName
+	if len(name) == 0 {
+		name = strings.TrimSuffix(filepath.Base(files[0]), ".meta_lic")
+	}
+	ns := ctx.namespace
+	if len(ns) == 0 {
+		ns = "https://android.googlesource.com/spdx/" + name + "-" + ctx.created.UTC().Format("20060102T150405Z")
+	}
+
+	doc := compliance.NewSPDXDocument(rootFS, licenseGraph, name, ns, "Tool: spdxsbom", ctx.created)
+	for i := range doc.Packages {
+		doc.Packages[i].Name = ctx.paths.OutputName(doc.Packages[i].Name)
+	}
+	for i := range doc.Files {
+		doc.Files[i].FileName = ctx.paths.OutputName(doc.Files[i].FileName)
+	}
+	if ctx.format == "json" {
+		return doc.WriteJSON(stdout)
+	}
+	return doc.WriteTagValue(stdout)
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_tagvalue(t *testing.T) {
+	tests := []struct {
+		condition     string
+		name          string
+		roots         []string
+		expectedLines []string
+	}{
+		{
+			condition: "firstparty",
+			name:      "apex",
+			roots:     []string{"highest.apex.meta_lic"},
+			expectedLines: []string{
+				"SPDXVersion: SPDX-2.3",
+				"DocumentName: highest.apex",
+				"DocumentNamespace: https://android.googlesource.com/spdx/highest.apex-19700101T000000Z",
+				"Creator: Tool: spdxsbom",
+				"Created: 1970-01-01T00:00:00Z",
+				"PackageName: testdata/firstparty/highest.apex.meta_lic",
+				"PackageSourceInfo: <text>built from project(s): highest/apex</text>",
+				"PackageLicenseDeclared: Apache-2.0",
+				"PackageLicenseComments: <text>license text(s): build/soong/licenses/LICENSE</text>",
+				"PackageName: out/target/product/fictional/system/apex/highest.apex",
+				"Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-testdata-firstparty-highest.apex.meta-lic",
+				"Relationship: SPDXRef-testdata-firstparty-bin-bin2.meta-lic DYNAMIC_LINK SPDXRef-testdata-firstparty-lib-libb.so.meta-lic",
+				"Relationship: SPDXRef-testdata-firstparty-bin-bin1.meta-lic STATIC_LINK SPDXRef-testdata-firstparty-lib-liba.so.meta-lic",
+				"Relationship: SPDXRef-testdata-firstparty-highest.apex.meta-lic CONTAINS SPDXRef-testdata-firstparty-bin-bin1.meta-lic",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "application",
+			roots:     []string{"application.meta_lic"},
+			expectedLines: []string{
+				"PackageName: testdata/restricted/bin/bin3.meta_lic",
+				"PackageLicenseDeclared: LGPL-2.0-only",
+				"Relationship: SPDXRef-testdata-restricted-bin-bin3.meta-lic BUILD_TOOL_OF SPDXRef-testdata-restricted-application.meta-lic",
+				"Relationship: SPDXRef-testdata-restricted-application.meta-lic DYNAMIC_LINK SPDXRef-testdata-restricted-lib-libb.so.meta-lic",
+			},
+		},
+		{
+			condition: "proprietary",
+			name:      "library",
+			roots:     []string{"lib/liba.so.meta_lic"},
+			expectedLines: []string{
+				"PackageLicenseDeclared: LicenseRef-legacy-proprietary",
+				"LicenseID: LicenseRef-legacy-proprietary",
+				"LicenseName: legacy_proprietary",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			ctx := &context{format: "tagvalue", created: time.Unix(0, 0)}
+			err := spdxSBOM(ctx, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("spdxsbom: error = %v, stderr = %v", err, stderr)
+				return
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("spdxsbom: gotStderr = %v, want none", stderr)
+			}
+			lines := make(map[string]bool)
+			for _, l := range strings.Split(stdout.String(), "\n") {
+				lines[l] = true
+			}
+			for _, expected := range tt.expectedLines {
+				if !lines[expected] {
+					t.Errorf("spdxsbom: missing line %q in output:\n%s", expected, stdout.String())
+				}
+			}
+		})
+	}
+}
+
+func Test_json(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{format: "json", documentName: "test", namespace: "https://example.com/test", created: time.Unix(0, 0)}
+	err := spdxSBOM(ctx, stdout, stderr, "testdata/notice/container.zip.meta_lic")
+	if err != nil {
+		t.Fatalf("spdxsbom: error = %v, stderr = %v", err, stderr)
+	}
+	var doc struct {
+		SPDXVersion       string `json:"spdxVersion"`
+		Name              string `json:"name"`
+		DocumentNamespace string `json:"documentNamespace"`
+		Packages          []struct {
+			Name            string `json:"name"`
+			LicenseDeclared string `json:"licenseDeclared"`
+		} `json:"packages"`
+		Relationships []struct {
+			Type string `json:"relationshipType"`
+		} `json:"relationships"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &doc); err != nil {
+		t.Fatalf("spdxsbom: unable to decode json output: %v", err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.Name != "test" || doc.DocumentNamespace != "https://example.com/test" {
+		t.Errorf("spdxsbom: unexpected document header: got %q %q %q", doc.SPDXVersion, doc.Name, doc.DocumentNamespace)
+	}
+	// 7 targets plus 5 installed files missing from the testdata
+	if len(doc.Packages) != 12 {
+		t.Errorf("spdxsbom: got %d packages, want 12", len(doc.Packages))
+	}
+	contains := 0
+	for _, r := range doc.Relationships {
+		if r.Type == "CONTAINS" {
+			contains++
+		}
+	}
+	if contains == 0 {
+		t.Errorf("spdxsbom: got no CONTAINS relationships, want some")
+	}
+}
+
+func Test_unknownFormat(t *testing.T) {
+	ctx := &context{format: "xml"}
+	err := spdxSBOM(ctx, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/notice/container.zip.meta_lic")
+	if err == nil {
+		t.Errorf("spdxsbom: got no error, want unknown format error")
+	}
+}
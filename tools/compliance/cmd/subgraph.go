@@ -0,0 +1,203 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	roots           = newMultiString("reroot", "License metadata file of a target to re-root the graph at. (may be given multiple times)")
+	installedPrefix = flag.String("installed_prefix", "", "Re-root the graph at every target installed under this path prefix.")
+	shipped         = flag.Bool("shipped", false, "Whether to output the shipped targets instead of the edges.")
+	format          = flag.String("format", "text", "Output format: text or json.")
+	graphCache      = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds    = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths           = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+	failBadRoots      = fmt.Errorf("\nexactly one of -reroot or -installed_prefix is required")
+	failBadFormat     = fmt.Errorf("\n-format must be one of text or json")
+)
+
+type context struct {
+	roots           []string
+	installedPrefix string
+	shipped         bool
+	format          string
+	graphCache      string
+	licenseKinds    string
+	paths           compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Reads the license graph for the file.meta_lic roots, e.g. for a full
+build, and derives the sub-graph re-rooted at the -reroot targets, or at
+every target installed under -installed_prefix, e.g. one partition.
+The sub-graph keeps every dependency reachable from the new roots, even
+the dependencies installed outside -installed_prefix.
+
+Outputs space-separated Target Dependency Annotations tuples for each
+edge in the sub-graph with multiple annotations colon-separated, or
+when -shipped given, outputs the name of each target the sub-graph
+ships. Both in name order.
+
+When -format=json given, outputs an object with the "roots" and the
+"shipped" lists of target names, and the "edges" list of {"target",
+"dependency", "annotations"} objects.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+// newMultiString creates a flag that allows multiple values in an array.
+func newMultiString(name, usage string) *multiString {
+	var f multiString
+	flag.Var(&f, name, usage)
+	return &f
+}
+
+// multiString implements the flag `Value` interface for multiple strings.
+type multiString []string
+
+func (ms *multiString) String() string     { return strings.Join(*ms, ", ") }
+func (ms *multiString) Set(s string) error { *ms = append(*ms, s); return nil }
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*roots, *installedPrefix, *shipped, *format, *graphCache, *licenseKinds, *paths}
+
+	err := subGraph(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested || err == failBadRoots || err == failBadFormat {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// edgeRecord describes an edge in -format=json output.
+type edgeRecord struct {
+	Target      string   `json:"target"`
+	Dependency  string   `json:"dependency"`
+	Annotations []string `json:"annotations"`
+}
+
+// subGraphRecord describes the sub-graph in -format=json output.
+type subGraphRecord struct {
+	Roots   []string     `json:"roots"`
+	Shipped []string     `json:"shipped"`
+	Edges   []edgeRecord `json:"edges"`
+}
+
+// subGraph implements the subgraph utility.
+func subGraph(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	if (len(ctx.roots) > 0) == (len(ctx.installedPrefix) > 0) {
+		return failBadRoots
+	}
+	switch ctx.format {
+	case "", "text", "json":
+	default:
+		return failBadFormat
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	var sub *compliance.LicenseGraph
+	if len(ctx.roots) > 0 {
+		sub, err = compliance.SubGraph(licenseGraph, ctx.roots...)
+	} else {
+		sub, err = compliance.InstalledSubGraph(licenseGraph, ctx.installedPrefix)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to derive sub-graph: %w", err)
+	}
+
+	shippedNames := make([]string, 0)
+	for _, name := range compliance.ShippedNodes(sub, kinds.Policy()).Names() {
+		shippedNames = append(shippedNames, ctx.paths.OutputName(name))
+	}
+	sort.Strings(shippedNames)
+
+	edges := sub.Edges()
+	sort.Sort(edges)
+
+	if ctx.format == "json" {
+		result := subGraphRecord{make([]string, 0), shippedNames, make([]edgeRecord, 0, len(edges))}
+		for _, tn := range sub.Roots() {
+			result.Roots = append(result.Roots, ctx.paths.OutputName(tn.Name()))
+		}
+		for _, e := range edges {
+			result.Edges = append(result.Edges, edgeRecord{ctx.paths.OutputName(e.Target().Name()), ctx.paths.OutputName(e.Dependency().Name()), e.Annotations().AsList()})
+		}
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	if ctx.shipped {
+		for _, name := range shippedNames {
+			fmt.Fprintln(stdout, name)
+		}
+		return nil
+	}
+	for _, e := range edges {
+		fmt.Fprintf(stdout, "%s %s %s\n", ctx.paths.OutputName(e.Target().Name()), ctx.paths.OutputName(e.Dependency().Name()), strings.Join(e.Annotations().AsList(), ":"))
+	}
+	return nil
+}
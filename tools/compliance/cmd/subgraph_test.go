@@ -0,0 +1,152 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition       string
+		name            string
+		roots           []string
+		installedPrefix string
+		shipped         bool
+		expectedOut     []string
+	}{
+		{
+			condition: "restricted",
+			name:      "bin1",
+			roots:     []string{"bin/bin1"},
+			expectedOut: []string{
+				"bin/bin1.meta_lic lib/liba.so.meta_lic static",
+				"bin/bin1.meta_lic lib/libc.a.meta_lic static",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "bins",
+			roots:     []string{"bin/bin1", "bin/bin2.meta_lic"},
+			expectedOut: []string{
+				"bin/bin1.meta_lic lib/liba.so.meta_lic static",
+				"bin/bin1.meta_lic lib/libc.a.meta_lic static",
+				"bin/bin2.meta_lic lib/libb.so.meta_lic dynamic",
+				"bin/bin2.meta_lic lib/libd.so.meta_lic dynamic",
+			},
+		},
+		{
+			condition:   "restricted",
+			name:        "bin2",
+			roots:       []string{"bin/bin2"},
+			shipped:     true,
+			expectedOut: []string{"bin/bin2.meta_lic"},
+		},
+		{
+			condition:       "restricted",
+			name:            "lib",
+			installedPrefix: "out/target/product/fictional/system/lib/",
+			expectedOut:     []string{},
+		},
+		{
+			condition:       "restricted",
+			name:            "lib",
+			installedPrefix: "out/target/product/fictional/system/lib/",
+			shipped:         true,
+			expectedOut:     []string{"lib/liba.so.meta_lic", "lib/libb.so.meta_lic", "lib/libd.so.meta_lic"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name+" "+strings.Join(tt.roots, ",")+tt.installedPrefix, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			prefix := "testdata/" + tt.condition + "/"
+			roots := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				roots = append(roots, prefix+r)
+			}
+			ctx := &context{roots: roots, installedPrefix: tt.installedPrefix, shipped: tt.shipped, paths: compliance.PathFlags{StripPrefix: prefix}}
+			err := subGraph(ctx, stdout, stderr, prefix+"highest.apex.meta_lic")
+			if err != nil {
+				t.Fatalf("subgraph: error = %v, stderr = %v", err, stderr)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("subgraph: gotStderr = %v, want none", stderr)
+			}
+			actual := make([]string, 0)
+			for _, line := range strings.Split(stdout.String(), "\n") {
+				if len(line) > 0 {
+					actual = append(actual, line)
+				}
+			}
+			if !reflect.DeepEqual(actual, tt.expectedOut) {
+				t.Errorf("subgraph: got %q, want %q", actual, tt.expectedOut)
+			}
+		})
+	}
+}
+
+func Test_json(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{roots: []string{"testdata/restricted/bin/bin2.meta_lic"}, format: "json", paths: compliance.PathFlags{StripPrefix: "testdata/restricted/"}}
+	err := subGraph(ctx, stdout, stderr, "testdata/restricted/highest.apex.meta_lic")
+	if err != nil {
+		t.Fatalf("subgraph: error = %v, stderr = %v", err, stderr)
+	}
+	var actual subGraphRecord
+	if err := json.Unmarshal(stdout.Bytes(), &actual); err != nil {
+		t.Fatalf("subgraph: cannot parse %q: %v", stdout, err)
+	}
+	expected := subGraphRecord{
+		Roots:   []string{"bin/bin2.meta_lic"},
+		Shipped: []string{"bin/bin2.meta_lic"},
+		Edges: []edgeRecord{
+			{Target: "bin/bin2.meta_lic", Dependency: "lib/libb.so.meta_lic", Annotations: []string{"dynamic"}},
+			{Target: "bin/bin2.meta_lic", Dependency: "lib/libd.so.meta_lic", Annotations: []string{"dynamic"}},
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("subgraph: got %+v, want %+v", actual, expected)
+	}
+}
+
+func Test_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      *context
+		expected string
+	}{
+		{"no roots", &context{}, failBadRoots.Error()},
+		{"both roots", &context{roots: []string{"testdata/restricted/bin/bin1"}, installedPrefix: "out/"}, failBadRoots.Error()},
+		{"bad format", &context{installedPrefix: "out/", format: "csv"}, failBadFormat.Error()},
+		{"missing root", &context{roots: []string{"testdata/restricted/bin/bin3"}}, "missing from graph"},
+		{"nothing installed", &context{installedPrefix: "out/target/product/fictional/vendor/"}, "no targets installed under"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := subGraph(tt.ctx, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/highest.apex.meta_lic")
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("subgraph: got error %v, want %q", err, tt.expected)
+			}
+		})
+	}
+}
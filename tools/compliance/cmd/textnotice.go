@@ -0,0 +1,197 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	outputFile   = flag.String("o", "-", "Where to write the NOTICE text file. (default stdout)")
+	title        = flag.String("title", "", "The title of the notice file.")
+	compress     = flag.Bool("gzip", false, "Compress the output with gzip.")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+)
+
+type context struct {
+	title        string
+	gzip         bool
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {options} file.meta_lic {file.meta_lic...}
+
+Outputs a text NOTICE file for the license graph rooted at the given
+license metadata files.
+
+Install paths sharing identical license texts get grouped together so
+that each distinct license text appears exactly once. A table of
+contents lists the notices for each install path.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Write the notice file to a temporary file renamed into place on
+	// success so a failed run leaves no partial notice file behind.
+	ofile := os.Stdout
+	if *outputFile != "-" {
+		f, err := os.CreateTemp(filepath.Dir(*outputFile), filepath.Base(*outputFile)+".*")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create %q: %s\n", *outputFile, err)
+			os.Exit(1)
+		}
+		ofile = f
+	}
+
+	ctx := &context{*title, *compress, *graphCache, *licenseKinds, *paths}
+
+	err := textNotice(ctx, ofile, os.Stderr, flag.Args()...)
+	if ofile != os.Stdout {
+		err = closeOutput(ofile, *outputFile, err)
+	}
+	if err != nil {
+		if err == failNoneRequested {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// closeOutput closes the temporary file `tmp` and, when `err` is nil, renames
+// it to `outputFile`. Otherwise, removes `tmp` and returns `err`.
+func closeOutput(tmp *os.File, outputFile string, err error) error {
+	if cerr := tmp.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("cannot write %q: %w", outputFile, cerr)
+	}
+	if err == nil {
+		// os.CreateTemp creates files readable only by the owner.
+		if cerr := os.Chmod(tmp.Name(), 0644); cerr != nil {
+			err = fmt.Errorf("cannot write %q: %w", outputFile, cerr)
+		}
+	}
+	if err == nil {
+		if rerr := os.Rename(tmp.Name(), outputFile); rerr != nil {
+			err = fmt.Errorf("cannot write %q: %w", outputFile, rerr)
+		}
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// textNotice implements the textnotice utility.
+func textNotice(ctx *context, stdout, stderr io.Writer, files ...string) (err error) {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	ni, err := compliance.IndexLicenseTextsWithPolicy(rootFS, licenseGraph, kinds.Policy())
+	if err != nil {
+		return fmt.Errorf("Unable to read license text file(s) for %q: %w\n", files, err)
+	}
+
+	w := stdout
+	if ctx.gzip {
+		zw := gzip.NewWriter(stdout)
+		defer func() {
+			if cerr := zw.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		w = zw
+	}
+
+	// number each license text in index order
+	notice := make(map[string]int)
+	for i, h := range ni.Hashes() {
+		notice[h] = i + 1
+	}
+
+	if len(ctx.title) > 0 {
+		fmt.Fprintf(w, "%s\n\n", ctx.title)
+	}
+	fmt.Fprintln(w, "Table of contents:")
+	for _, path := range ni.InstallPaths() {
+		numbers := make([]string, 0)
+		for _, h := range ni.InstallHashes(path) {
+			numbers = append(numbers, fmt.Sprintf("%d", notice[h]))
+		}
+		fmt.Fprintf(w, "  %s: notice(s) %s\n", ctx.paths.OutputName(path), strings.Join(numbers, ", "))
+	}
+	for _, h := range ni.Hashes() {
+		fmt.Fprintln(w, strings.Repeat("=", 78))
+		fmt.Fprintf(w, "Notice %d for file(s):\n", notice[h])
+		for _, path := range ni.HashInstallPaths(h) {
+			fmt.Fprintf(w, "  %s\n", ctx.paths.OutputName(path))
+		}
+		fmt.Fprintln(w, strings.Repeat("-", 78))
+		text := ni.HashText(h)
+		w.Write(text)
+		if len(text) > 0 && text[len(text)-1] != '\n' {
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
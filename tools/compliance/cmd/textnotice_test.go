@@ -0,0 +1,178 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition     string
+		name          string
+		roots         []string
+		stripPrefix   string
+		expectedLines []string
+	}{
+		{
+			condition:   "notices",
+			name:        "apex",
+			roots:       []string{"highest.apex.meta_lic"},
+			stripPrefix: "out/target/product/fictional",
+			expectedLines: []string{
+				"Table of contents:",
+				"  /system/apex/highest.apex: notice(s) 1, 2, 3",
+				"  /system/bin/bin1: notice(s) 1, 2, 3",
+				"  /system/lib/liba.so: notice(s) 2",
+				"  /system/lib/libb.so: notice(s) 2",
+				"Notice 1 for file(s):",
+				"  /system/apex/highest.apex",
+				"  /system/bin/bin1",
+				"$$$Reciprocal License$$$",
+				"Notice 2 for file(s):",
+				"  /system/apex/highest.apex",
+				"  /system/bin/bin1",
+				"  /system/lib/liba.so",
+				"  /system/lib/libb.so",
+				"%%%Notice License%%%",
+				"Notice 3 for file(s):",
+				"  /system/apex/highest.apex",
+				"  /system/bin/bin1",
+				"&&&First Party License&&&",
+			},
+		},
+		{
+			condition:   "notices",
+			name:        "binary",
+			roots:       []string{"bin/bin1.meta_lic"},
+			stripPrefix: "out/target/product/fictional",
+			expectedLines: []string{
+				"Table of contents:",
+				"  /system/bin/bin1: notice(s) 1, 2, 3",
+				"Notice 1 for file(s):",
+				"  /system/bin/bin1",
+				"$$$Reciprocal License$$$",
+				"Notice 2 for file(s):",
+				"  /system/bin/bin1",
+				"%%%Notice License%%%",
+				"Notice 3 for file(s):",
+				"  /system/bin/bin1",
+				"&&&First Party License&&&",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			ctx := &context{paths: compliance.PathFlags{StripPrefix: tt.stripPrefix}}
+			err := textNotice(ctx, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("textnotice: error = %v, stderr = %v", err, stderr)
+				return
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("textnotice: gotStderr = %v, want none", stderr)
+			}
+			checkLines(t, stdout.String(), tt.expectedLines)
+		})
+	}
+}
+
+func Test_gzip(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	ctx := &context{title: "Notices", gzip: true}
+	err := textNotice(ctx, stdout, stderr, "testdata/notices/highest.apex.meta_lic")
+	if err != nil {
+		t.Fatalf("textnotice: error = %v, stderr = %v", err, stderr)
+	}
+	zr, err := gzip.NewReader(stdout)
+	if err != nil {
+		t.Fatalf("textnotice: unable to read gzip output: %v", err)
+	}
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("textnotice: unable to decompress output: %v", err)
+	}
+	checkLines(t, string(text), []string{
+		"Notices",
+		"Table of contents:",
+		"$$$Reciprocal License$$$",
+		"%%%Notice License%%%",
+		"&&&First Party License&&&",
+	})
+}
+
+func Test_closeOutput(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "NOTICE")
+
+	failed, err := os.CreateTemp(dir, "NOTICE.*")
+	if err != nil {
+		t.Fatalf("textnotice: unable to create temporary file: %v", err)
+	}
+	failed.WriteString("partial")
+	if err := closeOutput(failed, outputFile, failNoLicenses); err != failNoLicenses {
+		t.Errorf("textnotice: got error %v, want %v", err, failNoLicenses)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("textnotice: got %v after failure, want no files", entries)
+	}
+
+	done, err := os.CreateTemp(dir, "NOTICE.*")
+	if err != nil {
+		t.Fatalf("textnotice: unable to create temporary file: %v", err)
+	}
+	done.WriteString("complete")
+	if err := closeOutput(done, outputFile, nil); err != nil {
+		t.Fatalf("textnotice: got error %v, want none", err)
+	}
+	fi, err := os.Stat(outputFile)
+	if err != nil {
+		t.Fatalf("textnotice: unable to stat %q: %v", outputFile, err)
+	}
+	if fi.Mode().Perm() != 0644 {
+		t.Errorf("textnotice: got mode %v, want %v", fi.Mode().Perm(), os.FileMode(0644))
+	}
+}
+
+// checkLines verifies `expectedLines` appear in `output` in order.
+func checkLines(t *testing.T, output string, expectedLines []string) {
+	lines := strings.Split(output, "\n")
+	next := 0
+	for _, expected := range expectedLines {
+		for next < len(lines) && lines[next] != expected {
+			next++
+		}
+		if next >= len(lines) {
+			t.Errorf("textnotice: missing line %q in order in output:\n%s", expected, output)
+			return
+		}
+		next++
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"compliance"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	target       = flag.String("target", "", "License metadata file of the target to explain.")
+	project      = flag.String("project", "", "Project to explain. i.e. every target built from the project")
+	maxPaths     = flag.Int("max_paths", 0, "Maximum number of paths to output per target. 0 outputs every path. (optional)")
+	graphCache   = flag.String("graph_cache", "", "Path to a cache of parsed license metadata to reuse between runs. (optional)")
+	licenseKinds = flag.String("license_kinds", "", "Path to a JSON file mapping license kinds to license conditions and dynamic link rules. (optional)")
+	paths        = compliance.NewPathFlags(flag.CommandLine)
+
+	failNoneRequested = fmt.Errorf("\nNo license metadata files requested")
+	failNoTarget      = fmt.Errorf("\nExactly one of -target or -project required")
+	failNoLicenses    = fmt.Errorf("No licenses found")
+)
+
+type context struct {
+	target       string
+	project      string
+	maxPaths     int
+	graphCache   string
+	licenseKinds string
+	paths        compliance.PathFlags
+}
+
+func init() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `Usage: %s {-target file.meta_lic | -project path} {options} root.meta_lic {root.meta_lic...}
+
+Explains why policy requires sharing the source of the given target, or
+of the targets built from the given project, by outputting every path
+from the roots that carries a source-sharing condition to the target.
+The number of paths can grow quickly in large graphs. Use -max_paths to
+output only the shortest paths.
+
+Each explanation starts with a line naming the target and the condition
+as target origin:condition followed by one line per edge in the path
+from the root as target -> dependency [annotations].
+
+Edges the condition climbs from its origin start with ^, and edges the
+condition descends to the target start with v. Both are followed by the
+policy rule letting the condition cross the edge.
+
+When -max_paths leaves out paths for a target, the output for the target
+ends with a line saying so.
+
+Options:
+`, filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	// Must specify at least one root target.
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := &context{*target, *project, *maxPaths, *graphCache, *licenseKinds, *paths}
+
+	err := whyShare(ctx, os.Stdout, os.Stderr, flag.Args()...)
+	if err != nil {
+		if err == failNoneRequested || err == failNoTarget {
+			flag.Usage()
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// whyShare implements the whyshare utility.
+func whyShare(ctx *context, stdout, stderr io.Writer, files ...string) error {
+	if len(files) < 1 {
+		return failNoneRequested
+	}
+	if (len(ctx.target) == 0) == (len(ctx.project) == 0) {
+		return failNoTarget
+	}
+
+	kinds, err := compliance.LoadLicenseKindMap(ctx.licenseKinds)
+	if err != nil {
+		return err
+	}
+
+	rootFS, err := ctx.paths.OpenRoot()
+	if err != nil {
+		return err
+	}
+
+	// Read the license graph from the license metadata files (*.meta_lic).
+	licenseGraph, err := compliance.ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, ctx.graphCache, kinds)
+	if err != nil {
+		return fmt.Errorf("Unable to read license metadata file(s) %q: %w\n", files, err)
+	}
+	if licenseGraph == nil {
+		return failNoLicenses
+	}
+
+	// targets lists the targets to explain.
+	var targets compliance.TargetNodeList
+	if len(ctx.target) > 0 {
+		if !licenseGraph.HasTargetNode(ctx.target) {
+			return fmt.Errorf("Target %q not in license graph of %q", ctx.target, files)
+		}
+		targets = append(targets, licenseGraph.TargetNode(ctx.target))
+	} else {
+		for _, tn := range licenseGraph.Targets() {
+			for _, p := range tn.Projects() {
+				if p == ctx.project {
+					targets = append(targets, tn)
+					break
+				}
+			}
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("Project %q not in license graph of %q", ctx.project, files)
+		}
+	}
+	sort.Sort(targets)
+
+	for _, tn := range targets {
+		explanations, truncated := compliance.ExplainSourceSharingLimit(licenseGraph, kinds.Policy(), tn, ctx.maxPaths)
+		if len(explanations) == 0 {
+			fmt.Fprintf(stdout, "%s need not share source\n", ctx.paths.OutputName(tn.Name()))
+			continue
+		}
+		outputs := make([]string, 0, len(explanations))
+		for _, x := range explanations {
+			outputs = append(outputs, ctx.explanation(x))
+		}
+		sort.Strings(outputs)
+		for _, o := range outputs {
+			fmt.Fprintln(stdout, o)
+		}
+		if truncated {
+			fmt.Fprintf(stdout, "%s has more than %d paths; raise -max_paths to output them\n", ctx.paths.OutputName(tn.Name()), ctx.maxPaths)
+		}
+	}
+	return nil
+}
+
+// explanation returns the text output for explanation `x`.
+func (ctx *context) explanation(x compliance.ShareExplanation) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s:%s\n", ctx.paths.OutputName(x.Target.Name()), ctx.paths.OutputName(x.Condition.Origin().Name()), x.Condition.Name())
+
+	// output the common edges then the climb from the origin then the descent to the target
+	for _, xe := range x.TargetPath {
+		if !xe.Crossed {
+			fmt.Fprintf(&sb, "  %s\n", ctx.edge(xe.Edge))
+		}
+	}
+	for _, xe := range x.OriginPath {
+		if xe.Crossed {
+			fmt.Fprintf(&sb, "^ %s: %s\n", ctx.edge(xe.Edge), xe.Rule)
+		}
+	}
+	for _, xe := range x.TargetPath {
+		if xe.Crossed {
+			fmt.Fprintf(&sb, "v %s: %s\n", ctx.edge(xe.Edge), xe.Rule)
+		}
+	}
+	return sb.String()
+}
+
+// edge returns the text output for edge `e`.
+func (ctx *context) edge(e compliance.TargetEdge) string {
+	return fmt.Sprintf("%s -> %s [%s]", ctx.paths.OutputName(e.Target().Name()), ctx.paths.OutputName(e.Dependency().Name()), strings.Join(e.Annotations().AsList(), ":"))
+}
@@ -0,0 +1,153 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"compliance"
+	"strings"
+	"testing"
+)
+
+func Test(t *testing.T) {
+	tests := []struct {
+		condition   string
+		name        string
+		roots       []string
+		target      string
+		project     string
+		maxPaths    int
+		expectedOut []string
+	}{
+		{
+			condition: "firstparty",
+			name:      "application",
+			roots:     []string{"application.meta_lic"},
+			target:    "lib/liba.so.meta_lic",
+			expectedOut: []string{
+				"lib/liba.so.meta_lic need not share source",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "dynamic",
+			roots:     []string{"application.meta_lic"},
+			target:    "application.meta_lic",
+			expectedOut: []string{
+				"application.meta_lic lib/liba.so.meta_lic:restricted",
+				"^ application.meta_lic -> lib/liba.so.meta_lic [static]: target is a derivative work of dependency",
+				"",
+				"application.meta_lic lib/libb.so.meta_lic:restricted",
+				"^ application.meta_lic -> lib/libb.so.meta_lic [dynamic]: restricted crosses dynamic link (SPDX-license-identifier-GPL-2.0: shared)",
+				"",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "maxpaths",
+			roots:     []string{"application.meta_lic"},
+			target:    "application.meta_lic",
+			maxPaths:  1,
+			expectedOut: []string{
+				"application.meta_lic lib/liba.so.meta_lic:restricted",
+				"^ application.meta_lic -> lib/liba.so.meta_lic [static]: target is a derivative work of dependency",
+				"",
+				"application.meta_lic has more than 1 paths; raise -max_paths to output them",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "sibling",
+			roots:     []string{"application.meta_lic"},
+			target:    "lib/liba.so.meta_lic",
+			expectedOut: []string{
+				"lib/liba.so.meta_lic lib/liba.so.meta_lic:restricted",
+				"  application.meta_lic -> lib/liba.so.meta_lic [static]",
+				"",
+				"lib/liba.so.meta_lic lib/libb.so.meta_lic:restricted",
+				"^ application.meta_lic -> lib/libb.so.meta_lic [dynamic]: restricted crosses dynamic link (SPDX-license-identifier-GPL-2.0: shared)",
+				"v application.meta_lic -> lib/liba.so.meta_lic [static]: target is a derivative work of dependency",
+				"",
+			},
+		},
+		{
+			condition: "restricted",
+			name:      "project",
+			roots:     []string{"container.zip.meta_lic"},
+			project:   "dynamic/binary",
+			expectedOut: []string{
+				"bin/bin2.meta_lic lib/libb.so.meta_lic:restricted",
+				"  container.zip.meta_lic -> bin/bin2.meta_lic [static]",
+				"^ bin/bin2.meta_lic -> lib/libb.so.meta_lic [dynamic]: restricted crosses dynamic link (SPDX-license-identifier-GPL-2.0: shared)",
+				"",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition+" "+tt.name, func(t *testing.T) {
+			stdout := &bytes.Buffer{}
+			stderr := &bytes.Buffer{}
+
+			rootFiles := make([]string, 0, len(tt.roots))
+			for _, r := range tt.roots {
+				rootFiles = append(rootFiles, "testdata/"+tt.condition+"/"+r)
+			}
+			ctx := &context{project: tt.project, maxPaths: tt.maxPaths, paths: compliance.PathFlags{StripPrefix: "testdata/" + tt.condition + "/"}}
+			if len(tt.target) > 0 {
+				ctx.target = "testdata/" + tt.condition + "/" + tt.target
+			}
+			err := whyShare(ctx, stdout, stderr, rootFiles...)
+			if err != nil {
+				t.Fatalf("whyshare: error = %v, stderr = %v", err, stderr)
+				return
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("whyshare: gotStderr = %v, want none", stderr)
+			}
+			out := strings.TrimSuffix(stdout.String(), "\n")
+			lines := strings.Split(out, "\n")
+			if len(lines) != len(tt.expectedOut) {
+				t.Errorf("whyshare: got %d lines, want %d lines:\n%s", len(lines), len(tt.expectedOut), stdout.String())
+				return
+			}
+			for i := range lines {
+				if lines[i] != tt.expectedOut[i] {
+					t.Errorf("whyshare: unexpected line %d: got %q, want %q", i+1, lines[i], tt.expectedOut[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		ctx           *context
+		expectedError string
+	}{
+		{"neither", &context{}, "Exactly one of -target or -project required"},
+		{"both", &context{target: "a", project: "b"}, "Exactly one of -target or -project required"},
+		{"missingtarget", &context{target: "testdata/restricted/bin/bin1.meta_lic"}, "not in license graph"},
+		{"missingproject", &context{project: "no/such/project"}, "not in license graph"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := whyShare(tt.ctx, &bytes.Buffer{}, &bytes.Buffer{}, "testdata/restricted/application.meta_lic")
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("whyshare: got error %v, want %q", err, tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CycleError describes a dependency cycle found in the license metadata.
+//
+// Resolving license conditions requires an acyclic graph.
+type CycleError struct {
+	// Path lists the edges of the cycle starting and ending at the same target.
+	Path TargetEdgePath
+}
+
+// Error returns a string describing the cycle.
+func (ce *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle %s", ce.Path.String())
+}
+
+// checkCycles returns a *CycleError for the first dependency cycle found in
+// `lg` or, when `breakCycles` is true, removes the edge closing each cycle
+// reporting a warning for each on `stderr` and returns nil.
+//
+// The search starts at the root files in name order and follows the edges of
+// each target in dependency order so the same graph always reports, or
+// breaks, the same cycles.
+func (lg *LicenseGraph) checkCycles(stderr io.Writer, breakCycles bool) error {
+	lg.indexForward()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	path := NewTargetEdgePath(32)
+	broken := make(map[*dependencyEdge]bool)
+
+	var visit func(target string) error
+	visit = func(target string) error {
+		state[target] = visiting
+		edges := append([]*dependencyEdge{}, lg.index[target]...)
+		sort.Slice(edges, func(i, j int) bool {
+			if edges[i].dependency == edges[j].dependency {
+				return edges[i].annotations.Compare(edges[j].annotations) < 0
+			}
+			return edges[i].dependency < edges[j].dependency
+		})
+		for _, e := range edges {
+			switch state[e.dependency] {
+			case visited:
+				continue
+			case visiting:
+				cycle := TargetEdgePath{}
+				for i := len(*path) - 1; i >= 0; i-- {
+					if (*path)[i].e.target == e.dependency {
+						cycle = append(cycle, (*path)[i:]...)
+						break
+					}
+				}
+				cycle = append(cycle, TargetEdge{lg, e})
+				if !breakCycles {
+					return &CycleError{cycle}
+				}
+				fmt.Fprintf(stderr, "warning: breaking dependency cycle %s at edge %s -> %s\n", cycle.String(), e.target, e.dependency)
+				broken[e] = true
+				continue
+			}
+			path.Push(TargetEdge{lg, e})
+			err := visit(e.dependency)
+			path.Pop()
+			if err != nil {
+				return err
+			}
+		}
+		state[target] = visited
+		return nil
+	}
+
+	roots := append([]string{}, lg.rootFiles...)
+	sort.Strings(roots)
+	for _, root := range roots {
+		if state[root] != unvisited {
+			continue
+		}
+		if err := visit(root); err != nil {
+			return err
+		}
+	}
+
+	if len(broken) > 0 {
+		edges := make([]*dependencyEdge, 0, len(lg.edges)-len(broken))
+		for _, e := range lg.edges {
+			if !broken[e] {
+				edges = append(edges, e)
+			}
+		}
+		lg.edges = edges
+		lg.index = nil
+		lg.reverseIndex = nil
+	}
+	return nil
+}
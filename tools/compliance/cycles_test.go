@@ -0,0 +1,113 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// cycleFS is a test file system with a 3 target cycle below a binary and a
+// library depending on itself.
+var cycleFS = testFS{
+	"apacheBin.meta_lic": []byte(AOSP +
+		"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"gplLib.meta_lic": []byte(GPL +
+		"deps: {\n  file: \"mplLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"mplLib.meta_lic": []byte(MPL +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n"),
+	"mitLib.meta_lic": []byte(MIT +
+		"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"selfLib.meta_lic": []byte(MIT +
+		"deps: {\n  file: \"selfLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"apacheLib.meta_lic": []byte(AOSP),
+}
+
+func TestCycles(t *testing.T) {
+	tests := []struct {
+		name          string
+		roots         []string
+		expectedCycle string
+		expectedEdges []string
+	}{
+		{
+			name:          "cycle",
+			roots:         []string{"apacheBin.meta_lic"},
+			expectedCycle: "[gplLib.meta_lic -> mplLib.meta_lic -> mitLib.meta_lic -> gplLib.meta_lic]",
+			expectedEdges: []string{
+				"apacheBin.meta_lic -> gplLib.meta_lic",
+				"apacheBin.meta_lic -> mitLib.meta_lic",
+				"gplLib.meta_lic -> mplLib.meta_lic",
+				"mplLib.meta_lic -> mitLib.meta_lic",
+			},
+		},
+		{
+			name:          "self",
+			roots:         []string{"apacheLib.meta_lic", "selfLib.meta_lic"},
+			expectedCycle: "[selfLib.meta_lic -> selfLib.meta_lic]",
+			expectedEdges: []string{},
+		},
+		{
+			name:          "starting inside cycle",
+			roots:         []string{"mitLib.meta_lic"},
+			expectedCycle: "[mitLib.meta_lic -> gplLib.meta_lic -> mplLib.meta_lic -> mitLib.meta_lic]",
+			expectedEdges: []string{
+				"gplLib.meta_lic -> mplLib.meta_lic",
+				"mitLib.meta_lic -> gplLib.meta_lic",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			lg, err := ReadLicenseGraph(&cycleFS, stderr, tt.roots)
+			if lg != nil {
+				t.Errorf("ReadLicenseGraph: got graph, want nil graph")
+			}
+			var ce *CycleError
+			if !errors.As(err, &ce) {
+				t.Fatalf("ReadLicenseGraph: got error %v, want *CycleError", err)
+			}
+			if ce.Path.String() != tt.expectedCycle {
+				t.Errorf("ReadLicenseGraph: got cycle %s, want %s", ce.Path.String(), tt.expectedCycle)
+			}
+
+			// read twice to check the same edges get removed
+			for i := 0; i < 2; i++ {
+				stderr := &bytes.Buffer{}
+				lg, err := ReadLicenseGraphWithOptions(&cycleFS, stderr, tt.roots, ReadOptions{BreakCycles: true})
+				if err != nil {
+					t.Fatalf("ReadLicenseGraph: got error %v, want no error when breaking cycles", err)
+				}
+				if !strings.Contains(stderr.String(), "warning: breaking dependency cycle "+tt.expectedCycle) {
+					t.Errorf("ReadLicenseGraph: got stderr %q, want warning for %s", stderr.String(), tt.expectedCycle)
+				}
+				actual := make([]string, 0)
+				for _, e := range lg.Edges() {
+					actual = append(actual, e.Target().Name()+" -> "+e.Dependency().Name())
+				}
+				sort.Strings(actual)
+				checkSameStrings("edge", actual, tt.expectedEdges, t)
+
+				// analysis must complete without recursing forever
+				ResolveTopDownConditions(lg, DefaultPolicy)
+			}
+		})
+	}
+}
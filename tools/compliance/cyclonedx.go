@@ -0,0 +1,271 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// CycloneDXVersion identifies the version of the CycloneDX specification implemented by CycloneDXBOM.
+	CycloneDXVersion = "1.5"
+
+	// cycloneDXNamespace identifies the XML schema for CycloneDXVersion.
+	cycloneDXNamespace = "http://cyclonedx.org/schema/bom/1.5"
+)
+
+// CycloneDXBOM describes a software bill of materials for a license graph per
+// the CycloneDX 1.5 specification.
+//
+// Each target node becomes a component nested inside the containers that
+// bundle it, and each edge becomes a dependency.
+type CycloneDXBOM struct {
+	XMLName      xml.Name              `json:"-" xml:"bom"`
+	XMLNS        string                `json:"-" xml:"xmlns,attr"`
+	BOMFormat    string                `json:"bomFormat" xml:"-"`
+	SpecVersion  string                `json:"specVersion" xml:"-"`
+	Version      int                   `json:"version" xml:"version,attr"`
+	Metadata     CycloneDXMetadata     `json:"metadata" xml:"metadata"`
+	Components   []CycloneDXComponent  `json:"components" xml:"components>component"`
+	Dependencies []CycloneDXDependency `json:"dependencies" xml:"dependencies>dependency"`
+}
+
+// CycloneDXMetadata describes when and how the BOM was created.
+type CycloneDXMetadata struct {
+	Timestamp string          `json:"timestamp" xml:"timestamp"`
+	Tools     []CycloneDXTool `json:"tools" xml:"tools>tool"`
+}
+
+// CycloneDXTool describes the tool that created the BOM.
+type CycloneDXTool struct {
+	Name string `json:"name" xml:"name"`
+}
+
+// CycloneDXComponent describes a single target node as a CycloneDX component.
+//
+// Scope is "required" for components distributed either directly or as
+// derivative works and "excluded" otherwise. e.g. build tools
+type CycloneDXComponent struct {
+	Type       string                   `json:"type" xml:"type,attr"`
+	BOMRef     string                   `json:"bom-ref" xml:"bom-ref,attr"`
+	Group      string                   `json:"group,omitempty" xml:"group,omitempty"`
+	Name       string                   `json:"name" xml:"name"`
+	Scope      string                   `json:"scope" xml:"scope"`
+	Licenses   []CycloneDXLicenseChoice `json:"licenses,omitempty" xml:"licenses>license,omitempty"`
+	Components []CycloneDXComponent     `json:"components,omitempty" xml:"components>component,omitempty"`
+}
+
+// CycloneDXLicenseChoice wraps a license per the CycloneDX JSON format.
+type CycloneDXLicenseChoice struct {
+	License CycloneDXLicense `json:"license"`
+}
+
+// MarshalXML encodes the wrapped license directly per the CycloneDX XML format.
+func (lc CycloneDXLicenseChoice) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(lc.License, start)
+}
+
+// CycloneDXLicense identifies a license by SPDX identifier or by name.
+//
+// The name holds license expressions with exceptions because CycloneDX does
+// not allow mixing expressions with other licenses.
+type CycloneDXLicense struct {
+	ID   string `json:"id,omitempty" xml:"id,omitempty"`
+	Name string `json:"name,omitempty" xml:"name,omitempty"`
+}
+
+// CycloneDXDependency describes the components that the `Ref` component depends on.
+type CycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// MarshalXML encodes the dependency as nested dependency elements per the CycloneDX XML format.
+func (d CycloneDXDependency) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "ref"}, Value: d.Ref})
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, ref := range d.DependsOn {
+		child := xml.StartElement{
+			Name: xml.Name{Local: "dependency"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "ref"}, Value: ref}},
+		}
+		if err := e.EncodeToken(child); err != nil {
+			return err
+		}
+		if err := e.EncodeToken(child.End()); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// NewCycloneDXBOM converts license graph `lg` into a CycloneDX BOM created by
+// `tool` at time `created` with the components distributed under `policy`
+// in the required scope.
+func NewCycloneDXBOM(lg *LicenseGraph, policy Policy, tool string, created time.Time) *CycloneDXBOM {
+	bom := &CycloneDXBOM{
+		XMLNS:       cycloneDXNamespace,
+		BOMFormat:   "CycloneDX",
+		SpecVersion: CycloneDXVersion,
+		Version:     1,
+		Metadata: CycloneDXMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools:     []CycloneDXTool{{tool}},
+		},
+		Components:   []CycloneDXComponent{},
+		Dependencies: []CycloneDXDependency{},
+	}
+
+	shipped := ShippedNodes(lg, policy)
+
+	// must be indexed for fast lookup
+	lg.indexForward()
+
+	targets := lg.Targets()
+	sort.Sort(targets)
+
+	// parent maps each target bundled by a container to the first container bundling it.
+	parent := make(map[*TargetNode]*TargetNode)
+	for _, tn := range targets {
+		if !tn.IsContainer() {
+			continue
+		}
+		for _, edge := range lg.index[tn.name] {
+			dep := lg.targets[edge.dependency]
+			if _, ok := parent[dep]; ok || dep == tn || !policy.IsDerivation(TargetEdge{lg, edge}) {
+				continue
+			}
+			parent[dep] = tn
+		}
+	}
+
+	// children lists the targets nested directly inside each container.
+	children := make(map[*TargetNode]TargetNodeList)
+	for _, tn := range targets {
+		if p, ok := parent[tn]; ok {
+			children[p] = append(children[p], tn)
+		}
+	}
+
+	// nested identifies the targets already placed in the BOM.
+	nested := make(map[*TargetNode]bool)
+
+	var component func(tn *TargetNode) CycloneDXComponent
+	component = func(tn *TargetNode) CycloneDXComponent {
+		nested[tn] = true
+		c := newCycloneDXComponent(tn, shipped.Contains(tn))
+		for _, child := range children[tn] {
+			if nested[child] {
+				continue
+			}
+			c.Components = append(c.Components, component(child))
+		}
+		return c
+	}
+
+	// place each target under its container or at the top level when not
+	// bundled; containers bundling each other get broken at the first visited
+	for _, tn := range targets {
+		if _, ok := parent[tn]; ok {
+			continue
+		}
+		bom.Components = append(bom.Components, component(tn))
+	}
+	for _, tn := range targets {
+		if !nested[tn] {
+			bom.Components = append(bom.Components, component(tn))
+		}
+	}
+
+	for _, tn := range targets {
+		d := CycloneDXDependency{Ref: tn.name}
+		seen := make(map[string]bool)
+		for _, edge := range lg.index[tn.name] {
+			if seen[edge.dependency] {
+				continue
+			}
+			seen[edge.dependency] = true
+			d.DependsOn = append(d.DependsOn, edge.dependency)
+		}
+		sort.Strings(d.DependsOn)
+		bom.Dependencies = append(bom.Dependencies, d)
+	}
+
+	return bom
+}
+
+// WriteJSON outputs the BOM in the CycloneDX JSON format.
+func (bom *CycloneDXBOM) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bom)
+}
+
+// WriteXML outputs the BOM in the CycloneDX XML format.
+func (bom *CycloneDXBOM) WriteXML(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(bom); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// newCycloneDXComponent describes target node `tn` without nested components.
+func newCycloneDXComponent(tn *TargetNode, isShipped bool) CycloneDXComponent {
+	c := CycloneDXComponent{
+		Type:   "library",
+		BOMRef: tn.name,
+		Group:  tn.PackageName(),
+		Name:   tn.name,
+		Scope:  "excluded",
+	}
+	if tn.IsContainer() {
+		c.Type = "file"
+	} else {
+		for _, mc := range tn.proto.ModuleClasses {
+			if mc == "EXECUTABLES" || mc == "APPS" {
+				c.Type = "application"
+				break
+			}
+		}
+	}
+	if isShipped {
+		c.Scope = "required"
+	}
+	kinds := tn.LicenseKinds()
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		if expression, ok := spdxLicenseExpression(kind); ok && !strings.Contains(expression, " ") {
+			c.Licenses = append(c.Licenses, CycloneDXLicenseChoice{CycloneDXLicense{ID: expression}})
+		} else if ok {
+			c.Licenses = append(c.Licenses, CycloneDXLicenseChoice{CycloneDXLicense{Name: expression}})
+		} else {
+			c.Licenses = append(c.Licenses, CycloneDXLicenseChoice{CycloneDXLicense{Name: kind}})
+		}
+	}
+	return c
+}
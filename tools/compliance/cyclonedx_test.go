@@ -0,0 +1,199 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCycloneDXBOM(t *testing.T) {
+	tests := []struct {
+		name                 string
+		roots                []string
+		edges                []annotated
+		policy               Policy
+		expectedComponents   []string
+		expectedDependencies []string
+	}{
+		{
+			name:                 "singleton",
+			roots:                []string{"apacheBin.meta_lic"},
+			expectedComponents:   []string{"apacheBin.meta_lic required [Apache-2.0]"},
+			expectedDependencies: []string{"apacheBin.meta_lic ->"},
+		},
+		{
+			name:                 "exception",
+			roots:                []string{"gplWithClasspathException.meta_lic"},
+			expectedComponents:   []string{"gplWithClasspathException.meta_lic required [GPL-2.0-only WITH Classpath-exception-2.0]"},
+			expectedDependencies: []string{"gplWithClasspathException.meta_lic ->"},
+		},
+		{
+			name:  "toolchain",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{
+				{"apacheBin.meta_lic", "gplBin.meta_lic", []string{"toolchain"}},
+				{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"dynamic"}},
+			},
+			expectedComponents: []string{
+				"apacheBin.meta_lic required [Apache-2.0]",
+				"gplBin.meta_lic excluded [GPL-2.0-only]",
+				"mitLib.meta_lic excluded [MIT]",
+			},
+			expectedDependencies: []string{
+				"apacheBin.meta_lic -> gplBin.meta_lic mitLib.meta_lic",
+				"gplBin.meta_lic ->",
+				"mitLib.meta_lic ->",
+			},
+		},
+		{
+			name:  "custompolicy",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{
+				{"apacheBin.meta_lic", "gplBin.meta_lic", []string{"toolchain"}},
+				{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"dynamic"}},
+			},
+			policy: &dynamicAsStaticPolicy{DefaultPolicy},
+			expectedComponents: []string{
+				"apacheBin.meta_lic required [Apache-2.0]",
+				"gplBin.meta_lic excluded [GPL-2.0-only]",
+				"mitLib.meta_lic required [MIT]",
+			},
+			expectedDependencies: []string{
+				"apacheBin.meta_lic -> gplBin.meta_lic mitLib.meta_lic",
+				"gplBin.meta_lic ->",
+				"mitLib.meta_lic ->",
+			},
+		},
+		{
+			name:  "container",
+			roots: []string{"apacheContainer.meta_lic"},
+			edges: []annotated{
+				{"apacheContainer.meta_lic", "apacheBin.meta_lic", []string{"static"}},
+				{"apacheContainer.meta_lic", "proprietary.meta_lic", []string{"static"}},
+				{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"static"}},
+			},
+			expectedComponents: []string{
+				"apacheContainer.meta_lic required [Apache-2.0]",
+				"  apacheBin.meta_lic required [Apache-2.0]",
+				"  proprietary.meta_lic required [legacy_proprietary]",
+				"mitLib.meta_lic required [MIT]",
+			},
+			expectedDependencies: []string{
+				"apacheBin.meta_lic -> mitLib.meta_lic",
+				"apacheContainer.meta_lic -> apacheBin.meta_lic proprietary.meta_lic",
+				"mitLib.meta_lic ->",
+				"proprietary.meta_lic ->",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			lg, err := toGraph(stderr, tt.roots, tt.edges)
+			if err != nil {
+				t.Errorf("unexpected test data error: got %s, want no error", err)
+				return
+			}
+			policy := tt.policy
+			if policy == nil {
+				policy = DefaultPolicy
+			}
+			bom := NewCycloneDXBOM(lg, policy, "test", time.Unix(0, 0))
+			if bom.SpecVersion != CycloneDXVersion {
+				t.Errorf("unexpected version: got %q, want %q", bom.SpecVersion, CycloneDXVersion)
+			}
+			actualComponents := make([]string, 0)
+			var flatten func(indent string, components []CycloneDXComponent)
+			flatten = func(indent string, components []CycloneDXComponent) {
+				for _, c := range components {
+					licenses := make([]string, 0, len(c.Licenses))
+					for _, l := range c.Licenses {
+						licenses = append(licenses, l.License.ID+l.License.Name)
+					}
+					actualComponents = append(actualComponents, indent+c.BOMRef+" "+c.Scope+" ["+strings.Join(licenses, " ")+"]")
+					flatten(indent+"  ", c.Components)
+				}
+			}
+			flatten("", bom.Components)
+			checkSameStrings("component", actualComponents, tt.expectedComponents, t)
+			actualDependencies := make([]string, 0, len(bom.Dependencies))
+			for _, d := range bom.Dependencies {
+				actualDependencies = append(actualDependencies, strings.TrimSpace(d.Ref+" -> "+strings.Join(d.DependsOn, " ")))
+			}
+			sort.Strings(actualDependencies)
+			checkSameStrings("dependency", actualDependencies, tt.expectedDependencies, t)
+		})
+	}
+}
+
+func TestCycloneDXBOM_Write(t *testing.T) {
+	lg, err := toGraph(&bytes.Buffer{}, []string{"apacheContainer.meta_lic"}, []annotated{
+		{"apacheContainer.meta_lic", "apacheBin.meta_lic", []string{"static"}},
+		{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"dynamic"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	bom := NewCycloneDXBOM(lg, DefaultPolicy, "test", time.Unix(0, 0))
+
+	js := &bytes.Buffer{}
+	if err := bom.WriteJSON(js); err != nil {
+		t.Fatalf("unexpected json error: got %s, want no error", err)
+	}
+	var actual map[string]interface{}
+	if err := json.Unmarshal(js.Bytes(), &actual); err != nil {
+		t.Fatalf("unexpected json decode error: got %s, want no error", err)
+	}
+	if actual["bomFormat"] != "CycloneDX" || actual["specVersion"] != "1.5" {
+		t.Errorf("unexpected json header: got %v %v, want CycloneDX 1.5", actual["bomFormat"], actual["specVersion"])
+	}
+	if !strings.Contains(js.String(), `"license": {`) {
+		t.Errorf("missing json license choice: got %s", js.String())
+	}
+
+	lg, err = toGraph(&bytes.Buffer{}, []string{"gplWithClasspathException.meta_lic"}, []annotated{})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	js.Reset()
+	if err := NewCycloneDXBOM(lg, DefaultPolicy, "test", time.Unix(0, 0)).WriteJSON(js); err != nil {
+		t.Fatalf("unexpected json error: got %s, want no error", err)
+	}
+	if !strings.Contains(js.String(), `"name": "GPL-2.0-only WITH Classpath-exception-2.0"`) {
+		t.Errorf("missing json license name: got %s", js.String())
+	}
+
+	x := &bytes.Buffer{}
+	if err := bom.WriteXML(x); err != nil {
+		t.Fatalf("unexpected xml error: got %s, want no error", err)
+	}
+	for _, expected := range []string{
+		`<bom xmlns="http://cyclonedx.org/schema/bom/1.5" version="1">`,
+		`<component type="file" bom-ref="apacheContainer.meta_lic">`,
+		`<license>`,
+		`<id>Apache-2.0</id>`,
+		`<dependency ref="apacheBin.meta_lic">`,
+		`<dependency ref="mitLib.meta_lic"></dependency>`,
+	} {
+		if !strings.Contains(x.String(), expected) {
+			t.Errorf("missing xml output: got %s, want %q", x.String(), expected)
+		}
+	}
+}
@@ -63,23 +63,28 @@ type LicenseGraph struct {
 	// This is a forward index from target to dependencies. i.e. "top-down"
 	index map[string][]*dependencyEdge
 
-	// rsBU caches the results of a full bottom-up resolve. (creation guarded by mu)
+	// reverseIndex facilitates looking up edges from dependencies. (creation guarded by mu)
+	//
+	// This is a reverse index from dependency to targets. i.e. "bottom-up"
+	reverseIndex map[string][]*dependencyEdge
+
+	// rsBU caches the results of a full bottom-up resolve per policy. (guarded by mu)
 	//
 	// A bottom-up resolve is a prerequisite for all of the top-down resolves so caching
 	// the result is a performance win.
-	rsBU *ResolutionSet
+	rsBU map[Policy]*ResolutionSet
 
-	// rsTD caches the results of a full top-down resolve. (creation guarded by mu)
+	// rsTD caches the results of a full top-down resolve per policy. (guarded by mu)
 	//
 	// A top-down resolve is a prerequisite for final resolutions.
 	// e.g. a shipped node inheriting a `restricted` condition from a parent through a
 	// dynamic dependency implies a notice dependency on the parent; even though, the
 	// distribution does not happen as a result of the dynamic dependency itself.
-	rsTD *ResolutionSet
+	rsTD map[Policy]*ResolutionSet
 
 	// shippedNodes caches the results of a full walk of nodes identifying targets
-	// distributed either directly or as derivative works. (creation guarded by mu)
-	shippedNodes *TargetNodeSet
+	// distributed either directly or as derivative works per policy. (guarded by mu)
+	shippedNodes map[Policy]*TargetNodeSet
 
 	// mu guards against concurrent update.
 	mu sync.Mutex
@@ -109,6 +114,32 @@ func (lg *LicenseGraph) Edges() TargetEdgeList {
 	return edges
 }
 
+// Roots returns the list of root target nodes where top-down walks start.
+// (ordered as given)
+func (lg *LicenseGraph) Roots() TargetNodeList {
+	roots := make(TargetNodeList, 0, len(lg.rootFiles))
+	for _, r := range lg.rootFiles {
+		if tn, ok := lg.targets[r]; ok {
+			roots = append(roots, tn)
+		}
+	}
+	return roots
+}
+
+// Dependents returns the list of edges depending on `tn`. (unordered)
+//
+// i.e. the edges where `tn` is the dependency.
+func (lg *LicenseGraph) Dependents(tn *TargetNode) TargetEdgeList {
+	// must be indexed for fast lookup
+	lg.indexReverse()
+
+	edges := make(TargetEdgeList, 0, len(lg.reverseIndex[tn.name]))
+	for _, e := range lg.reverseIndex[tn.name] {
+		edges = append(edges, TargetEdge{lg, e})
+	}
+	return edges
+}
+
 // Targets returns the list of target nodes in the graph. (unordered)
 func (lg *LicenseGraph) Targets() TargetNodeList {
 	targets := make(TargetNodeList, 0, len(lg.targets))
@@ -123,9 +154,12 @@ func (lg *LicenseGraph) Targets() TargetNodeList {
 // newLicenseGraph constructs a new, empty instance of LicenseGraph.
 func newLicenseGraph() *LicenseGraph {
 	return &LicenseGraph{
-		rootFiles: []string{},
-		edges:     make([]*dependencyEdge, 0, 1000),
-		targets:   make(map[string]*TargetNode),
+		rootFiles:    []string{},
+		edges:        make([]*dependencyEdge, 0, 1000),
+		targets:      make(map[string]*TargetNode),
+		rsBU:         make(map[Policy]*ResolutionSet),
+		rsTD:         make(map[Policy]*ResolutionSet),
+		shippedNodes: make(map[Policy]*TargetNodeSet),
 	}
 }
 
@@ -151,6 +185,24 @@ func (lg *LicenseGraph) indexForward() {
 	}
 }
 
+// indexReverse guarantees the `reverseIndex` map is populated to look up edges
+// by `dependency`.
+func (lg *LicenseGraph) indexReverse() {
+	lg.mu.Lock()
+	defer func() {
+		lg.mu.Unlock()
+	}()
+
+	if lg.reverseIndex != nil {
+		return
+	}
+
+	lg.reverseIndex = make(map[string][]*dependencyEdge)
+	for _, e := range lg.edges {
+		lg.reverseIndex[e.dependency] = append(lg.reverseIndex[e.dependency], e)
+	}
+}
+
 // TargetEdge describes a directed, annotated edge from a target to a
 // dependency. (immutable)
 //
@@ -341,6 +393,13 @@ func (tn *TargetNode) IsContainer() bool {
 	return tn.proto.GetIsContainer()
 }
 
+// IsPlaceholder returns true if the target stands in for a license metadata
+// file that failed to read or parse when reading with
+// ReadOptions.TolerateReadErrors.
+func (tn *TargetNode) IsPlaceholder() bool {
+	return tn.placeholder
+}
+
 // Built returns the list of files built by the module or target. (unordered)
 func (tn *TargetNode) Built() []string {
 	return append([]string{}, tn.proto.Built...)
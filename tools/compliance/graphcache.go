@@ -0,0 +1,286 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// graphCacheVersion identifies the format of the cache file. Caches with any
+// other version get ignored.
+const graphCacheVersion = 3
+
+// ReadLicenseGraphCached reads and parses `files` and their dependencies into
+// a LicenseGraph like ReadLicenseGraph, but reuses the parsed metadata stored
+// in `cacheFile` for any license metadata file unchanged since the last read.
+//
+// The cache keys each license metadata file by the identity of `rootFS` and
+// the path of the file inside it, so one cache may serve several roots or
+// archives. Files with the cached size and modification time get neither read
+// nor parsed. Other files get read and hashed, but only files whose content
+// hash differs from the cached one get re-parsed. Cached files of the same
+// root not read during the call get dropped from the cache.
+//
+// `cacheFile` is a path in the operating system file system and not in
+// `rootFS`. A missing or unusable cache gets rebuilt. An empty `cacheFile`
+// reads the graph without caching.
+func ReadLicenseGraphCached(rootFS fs.FS, stderr io.Writer, files []string, cacheFile string) (*LicenseGraph, error) {
+	return ReadLicenseGraphCachedWithKindMap(rootFS, stderr, files, cacheFile, nil)
+}
+
+// ReadLicenseGraphCachedWithKindMap reads a LicenseGraph like
+// ReadLicenseGraphCached deriving license conditions from license kinds using
+// `kinds` like ReadLicenseGraphWithKindMap.
+//
+// The cache holds the license metadata as read, so one cache may serve reads
+// with different `kinds`.
+func ReadLicenseGraphCachedWithKindMap(rootFS fs.FS, stderr io.Writer, files []string, cacheFile string, kinds *LicenseKindMap) (*LicenseGraph, error) {
+	return ReadLicenseGraphWithOptions(rootFS, stderr, files, ReadOptions{Kinds: kinds, CacheFile: cacheFile})
+}
+
+// readLicenseGraphCached reads a LicenseGraph as adjusted by `opts` through
+// the cache in `opts.CacheFile`.
+func readLicenseGraphCached(rootFS fs.FS, stderr io.Writer, files []string, opts ReadOptions) (*LicenseGraph, error) {
+	cacheFile := opts.CacheFile
+	cache, err := loadGraphCache(cacheFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "warning: ignoring license graph cache: %s\n", err.Error())
+		cache = newGraphCache()
+	}
+	cache.root = fsIdentity(rootFS)
+	lg, err := readLicenseGraph(rootFS, stderr, files, opts, cache)
+	if err != nil {
+		return lg, err
+	}
+	cache.prune()
+	if err := cache.save(cacheFile); err != nil {
+		fmt.Fprintf(stderr, "warning: unable to write license graph cache: %s\n", err.Error())
+	}
+	return lg, nil
+}
+
+// graphCache maps license metadata file paths to their parsed contents.
+type graphCache struct {
+	// root identifies the file system currently read through the cache.
+	root string
+
+	// mu guards `entries`, `touched` and `dirty` against concurrent readers.
+	mu sync.Mutex
+
+	// entries maps each license metadata file to its cached metadata.
+	entries map[graphCacheKey]*graphCacheEntry
+
+	// touched records the entries read since the cache got loaded.
+	touched map[graphCacheKey]bool
+
+	// dirty is true when `entries` differs from the cache file.
+	dirty bool
+}
+
+// graphCacheKey identifies a license metadata file in the cache.
+type graphCacheKey struct {
+	// Root identifies the file system holding the file. See fsIdentity.
+	Root string
+
+	// File is the path of the file inside the file system.
+	File string
+}
+
+// graphCacheEntry describes the cached metadata for a single file.
+type graphCacheEntry struct {
+	// Size is the size of the file in bytes, or -1 when unknown.
+	Size int64
+
+	// ModTime is the modification time of the file in nanoseconds since the
+	// Unix epoch, or 0 when unknown.
+	ModTime int64
+
+	// Hash is the sha256 hash of the file content.
+	Hash [sha256.Size]byte
+
+	// Metadata is the parsed license metadata in binary proto format.
+	Metadata []byte
+}
+
+// graphCacheFile describes the content of the cache file.
+type graphCacheFile struct {
+	Version int
+	Entries map[graphCacheKey]*graphCacheEntry
+}
+
+// newGraphCache returns an empty cache.
+func newGraphCache() *graphCache {
+	return &graphCache{
+		entries: make(map[graphCacheKey]*graphCacheEntry),
+		touched: make(map[graphCacheKey]bool),
+	}
+}
+
+// fsIdentity returns a string identifying `rootFS` in cache keys: the result
+// of its String method when it has one, e.g. ArchiveFS, the absolute directory
+// for os.DirFS, and otherwise just its type.
+func fsIdentity(rootFS fs.FS) string {
+	if s, ok := rootFS.(fmt.Stringer); ok {
+		return s.String()
+	}
+	if v := reflect.ValueOf(rootFS); v.Kind() == reflect.String {
+		dir, err := filepath.Abs(v.String())
+		if err != nil {
+			dir = v.String()
+		}
+		return fmt.Sprintf("%T:%s", rootFS, dir)
+	}
+	return fmt.Sprintf("%T", rootFS)
+}
+
+// loadGraphCache reads the cache stored in `cacheFile` returning an empty
+// cache when the file does not exist.
+func loadGraphCache(cacheFile string) (*graphCache, error) {
+	f, err := os.Open(cacheFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return newGraphCache(), nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var cf graphCacheFile
+	if err := gob.NewDecoder(f).Decode(&cf); err != nil {
+		return nil, fmt.Errorf("error decoding %q: %w", cacheFile, err)
+	}
+	if cf.Version != graphCacheVersion {
+		return nil, fmt.Errorf("%q has version %d, want %d", cacheFile, cf.Version, graphCacheVersion)
+	}
+	cache := newGraphCache()
+	if cf.Entries != nil {
+		cache.entries = cf.Entries
+	}
+	return cache, nil
+}
+
+// prune drops the entries of the current root not read since the cache got
+// loaded. Entries of other roots get kept for later reads of those roots.
+func (cache *graphCache) prune() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for key := range cache.entries {
+		if key.Root == cache.root && !cache.touched[key] {
+			delete(cache.entries, key)
+			cache.dirty = true
+		}
+	}
+}
+
+// save writes the cache to `cacheFile` when changed since loaded.
+//
+// The cache gets written to a temporary file renamed into place so concurrent
+// readers never see a partial cache.
+func (cache *graphCache) save(cacheFile string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if !cache.dirty {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(graphCacheFile{graphCacheVersion, cache.entries}); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(cacheFile), filepath.Base(cacheFile)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), cacheFile); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	cache.dirty = false
+	return nil
+}
+
+// readTarget returns the target node for license metadata `file` in `rootFS`
+// using the cached metadata when the size and modification time of the file
+// are unchanged, or else when the content of the file is unchanged.
+func (cache *graphCache) readTarget(rootFS fs.FS, file string) (*TargetNode, error) {
+	key := graphCacheKey{cache.root, file}
+
+	cache.mu.Lock()
+	entry, ok := cache.entries[key]
+	cache.touched[key] = true
+	cache.mu.Unlock()
+
+	size, modTime := int64(-1), int64(0)
+	if info, err := fs.Stat(rootFS, file); err == nil && !info.ModTime().IsZero() {
+		size, modTime = info.Size(), info.ModTime().UnixNano()
+	}
+
+	tn := &TargetNode{name: file}
+	if ok && modTime != 0 && entry.Size == size && entry.ModTime == modTime {
+		if err := proto.Unmarshal(entry.Metadata, &tn.proto); err == nil {
+			return tn, nil
+		}
+	}
+
+	data, err := readData(rootFS, file)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	if ok && entry.Hash == hash {
+		if err := proto.Unmarshal(entry.Metadata, &tn.proto); err == nil {
+			cache.store(key, &graphCacheEntry{size, modTime, hash, entry.Metadata})
+			return tn, nil
+		}
+	}
+
+	err = UnmarshalLicenseMetadata(data, &tn.proto)
+	if err != nil {
+		return nil, fmt.Errorf("error license metadata %q: %w", file, err)
+	}
+	metadata, err := proto.MarshalOptions{Deterministic: true}.Marshal(&tn.proto)
+	if err != nil {
+		return nil, fmt.Errorf("error caching license metadata %q: %w", file, err)
+	}
+	cache.store(key, &graphCacheEntry{size, modTime, hash, metadata})
+	return tn, nil
+}
+
+// store records `entry` as the cached metadata for `key`.
+func (cache *graphCache) store(key graphCacheKey, entry *graphCacheEntry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.entries[key] = entry
+	cache.dirty = true
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReadLicenseGraphCached(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "graph.cache")
+	then := time.Unix(1000, 0)
+	later := time.Unix(2000, 0)
+	latest := time.Unix(3000, 0)
+
+	app := AOSP + "deps: {\n  file: \"lib.meta_lic\"\n}\n"
+	rootFS := fstest.MapFS{
+		"app.meta_lic": &fstest.MapFile{Data: []byte(app), ModTime: then},
+		"lib.meta_lic": &fstest.MapFile{Data: []byte(AOSP), ModTime: then},
+	}
+
+	// read checks the graph read through the cache has `expectedEdges`.
+	read := func(name string, expectedEdges ...string) {
+		t.Run(name, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			lg, err := ReadLicenseGraphCached(rootFS, stderr, []string{"app.meta_lic"}, cacheFile)
+			if err != nil {
+				t.Fatalf("unexpected error: got %s, want no error", err)
+			}
+			if stderr.Len() > 0 {
+				t.Errorf("unexpected stderr: got %q, want none", stderr.String())
+			}
+			actual := make([]string, 0)
+			for _, e := range lg.Edges() {
+				actual = append(actual, e.Target().Name()+" -> "+e.Dependency().Name())
+			}
+			sort.Strings(actual)
+			checkSameStrings("edge", actual, expectedEdges, t)
+		})
+	}
+
+	read("initial", "app.meta_lic -> lib.meta_lic")
+	if _, err := os.Stat(cacheFile); err != nil {
+		t.Fatalf("missing cache file: %s", err)
+	}
+
+	// unchanged modification time and size reuse the parsed metadata without reading
+	lix := strings.Replace(app, "lib.meta_lic", "lix.meta_lic", 1)
+	rootFS["lix.meta_lic"] = &fstest.MapFile{Data: []byte(AOSP), ModTime: then}
+	rootFS["app.meta_lic"].Data = []byte(lix)
+	read("unchanged", "app.meta_lic -> lib.meta_lic")
+
+	// new content with a new modification time and the same size gets re-parsed
+	rootFS["app.meta_lic"] = &fstest.MapFile{Data: []byte(lix), ModTime: later}
+	read("same size", "app.meta_lic -> lix.meta_lic")
+
+	// new modification time with the same content reuses the parsed metadata
+	rootFS["app.meta_lic"] = &fstest.MapFile{Data: []byte(lix), ModTime: latest}
+	read("touched", "app.meta_lic -> lix.meta_lic")
+
+	// new content gets re-parsed
+	rootFS["app.meta_lic"] = &fstest.MapFile{Data: []byte(lix + "deps: {\n  file: \"bin.meta_lic\"\n}\n"), ModTime: latest}
+	rootFS["bin.meta_lic"] = &fstest.MapFile{Data: []byte(AOSP), ModTime: latest}
+	read("changed", "app.meta_lic -> bin.meta_lic", "app.meta_lic -> lix.meta_lic")
+
+	// files no longer read get dropped from the cache
+	cache, err := loadGraphCache(cacheFile)
+	if err != nil {
+		t.Fatalf("unexpected error loading cache: got %s, want no error", err)
+	}
+	actual := make([]string, 0, len(cache.entries))
+	for key := range cache.entries {
+		actual = append(actual, key.File)
+	}
+	sort.Strings(actual)
+	checkSameStrings("cached file", actual, []string{"app.meta_lic", "bin.meta_lic", "lix.meta_lic"}, t)
+}
+
+func TestReadLicenseGraphCached_roots(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "graph.cache")
+	mtime := time.Unix(1000, 0)
+
+	// Two roots hold files with the same names, sizes and modification times.
+	roots := make([]string, 0, 2)
+	for _, dep := range []string{"lib.meta_lic", "lix.meta_lic"} {
+		dir := t.TempDir()
+		files := map[string]string{
+			"app.meta_lic": AOSP + "deps: {\n  file: \"" + dep + "\"\n}\n",
+			dep:            AOSP,
+		}
+		for name, data := range files {
+			file := filepath.Join(dir, name)
+			if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+				t.Fatalf("unable to write %q: %s", file, err)
+			}
+			if err := os.Chtimes(file, mtime, mtime); err != nil {
+				t.Fatalf("unable to set time of %q: %s", file, err)
+			}
+		}
+		roots = append(roots, dir)
+	}
+
+	for i := 0; i < 2; i++ {
+		for _, root := range roots {
+			lg, err := ReadLicenseGraphCached(os.DirFS(root), &bytes.Buffer{}, []string{"app.meta_lic"}, cacheFile)
+			if err != nil {
+				t.Fatalf("unexpected error: got %s, want no error", err)
+			}
+			edges := lg.Edges()
+			if len(edges) != 1 || !lg.HasTargetNode(edges[0].Dependency().Name()) {
+				t.Fatalf("unexpected edges for %q: got %v", root, edges)
+			}
+			if _, err := os.Stat(filepath.Join(root, edges[0].Dependency().Name())); err != nil {
+				t.Errorf("unexpected dependency for %q: got %q from another root", root, edges[0].Dependency().Name())
+			}
+		}
+	}
+
+	cache, err := loadGraphCache(cacheFile)
+	if err != nil {
+		t.Fatalf("unexpected error loading cache: got %s, want no error", err)
+	}
+	if len(cache.entries) != 4 {
+		t.Errorf("unexpected number of cache entries: got %d, want 4", len(cache.entries))
+	}
+}
+
+func TestReadLicenseGraphCached_badCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "graph.cache")
+	if err := os.WriteFile(cacheFile, []byte("not a cache"), 0644); err != nil {
+		t.Fatalf("unable to write cache file: %s", err)
+	}
+	rootFS := fstest.MapFS{
+		"app.meta_lic": &fstest.MapFile{Data: []byte(AOSP), ModTime: time.Unix(1000, 0)},
+	}
+	stderr := &bytes.Buffer{}
+	lg, err := ReadLicenseGraphCached(rootFS, stderr, []string{"app.meta_lic"}, cacheFile)
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	if !lg.HasTargetNode("app.meta_lic") {
+		t.Errorf("missing target app.meta_lic")
+	}
+	if !strings.Contains(stderr.String(), "ignoring license graph cache") {
+		t.Errorf("unexpected stderr: got %q, want warning", stderr.String())
+	}
+
+	// the rebuilt cache replaces the bad one
+	if _, err := loadGraphCache(cacheFile); err != nil {
+		t.Errorf("unexpected error loading rebuilt cache: got %s, want no error", err)
+	}
+}
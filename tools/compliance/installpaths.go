@@ -0,0 +1,107 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// InstallPaths maps each target in `lg` to the sorted full paths of the files
+// it installs, composing the install maps of the containers holding it.
+//
+// An installed path that a container's install map moves into the container
+// becomes the container path joined to each full path of the container
+// itself, so a file inside an APEX inside a partition image shows the path
+// inside the image. A container path starting with "/" is already a full path
+// on the device, e.g. for a partition image mapping the product out
+// directory to "/", and is not composed further. Installed paths that no
+// container maps keep their original name.
+//
+// Targets that install nothing, e.g. static libraries, map to no paths.
+func InstallPaths(lg *LicenseGraph) map[*TargetNode][]string {
+	result := make(map[*TargetNode][]string)
+
+	// inProgress detects containers that directly or indirectly contain themselves.
+	inProgress := make(map[*TargetNode]bool)
+
+	var pathsFor func(tn *TargetNode) []string
+	pathsFor = func(tn *TargetNode) []string {
+		if paths, ok := result[tn]; ok {
+			return paths
+		}
+		if inProgress[tn] {
+			return nil
+		}
+		inProgress[tn] = true
+		defer delete(inProgress, tn)
+
+		paths := make(map[string]bool)
+		for _, installed := range tn.proto.Installed {
+			mapped := false
+			for _, e := range lg.Dependents(tn) {
+				container := e.Target()
+				if !container.IsContainer() {
+					continue
+				}
+				for _, im := range container.proto.InstallMap {
+					containerPath, ok := mapInstallPath(im.GetFromPath(), im.GetContainerPath(), installed)
+					if !ok {
+						continue
+					}
+					if strings.HasPrefix(containerPath, "/") {
+						paths[containerPath] = true
+						mapped = true
+						continue
+					}
+					for _, p := range pathsFor(container) {
+						paths[path.Join(p, containerPath)] = true
+						mapped = true
+					}
+				}
+			}
+			if !mapped {
+				paths[installed] = true
+			}
+		}
+
+		sorted := make([]string, 0, len(paths))
+		for p := range paths {
+			sorted = append(sorted, p)
+		}
+		sort.Strings(sorted)
+		result[tn] = sorted
+		return sorted
+	}
+
+	for _, tn := range lg.targets {
+		pathsFor(tn)
+	}
+	return result
+}
+
+// mapInstallPath returns the path inside a container for `installed` when
+// the install map entry from `fromPath` to `containerPath` applies to it.
+// A `fromPath` ending in "/" moves every file under the directory.
+func mapInstallPath(fromPath, containerPath, installed string) (string, bool) {
+	if installed == fromPath {
+		return containerPath, true
+	}
+	if strings.HasSuffix(fromPath, "/") && strings.HasPrefix(installed, fromPath) {
+		return path.Join(containerPath, strings.TrimPrefix(installed, fromPath)), true
+	}
+	return "", false
+}
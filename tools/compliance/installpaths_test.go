@@ -0,0 +1,101 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// installPathsFS is a test file system for a partition image holding an APEX
+// and a zip file holding libraries from the partition.
+var installPathsFS = testFS{
+	"system.img.meta_lic": []byte(AOSP + "is_container: true\ninstalled: \"out/system.img\"\n" +
+		"install_map {\n  from_path: \"out/\"\n  container_path: \"/\"\n}\n" +
+		"deps: {\n  file: \"apacheBin.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"highest.apex.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"highest.apex.meta_lic": []byte(AOSP + "is_container: true\ninstalled: \"out/system/apex/highest.apex\"\n" +
+		"install_map {\n  from_path: \"out/apex/lib/gplLib.so\"\n  container_path: \"lib/gplLib.so\"\n}\n" +
+		"install_map {\n  from_path: \"out/system/lib/mitLib.so\"\n  container_path: \"lib/mitLib.so\"\n}\n" +
+		"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"libs.zip.meta_lic": []byte(AOSP + "is_container: true\ninstalled: \"dist/libs.zip\"\n" +
+		"install_map {\n  from_path: \"out/system/lib/\"\n  container_path: \"\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"apacheBin.meta_lic": []byte(AOSP + "installed: \"out/system/bin/apacheBin\"\n" +
+		"deps: {\n  file: \"apacheLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n"),
+	"apacheLib.meta_lic": []byte(AOSP),
+	"gplLib.meta_lic":    []byte(GPL + "installed: \"out/apex/lib/gplLib.so\"\n"),
+	"mitLib.meta_lic":    []byte(MIT + "installed: \"out/system/lib/mitLib.so\"\n"),
+	"mplBin.meta_lic":    []byte(MPL + "installed: \"out/vendor/bin/mplBin\"\n"),
+}
+
+func TestInstallPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		roots    []string
+		expected map[string][]string
+	}{
+		{
+			name:  "image",
+			roots: []string{"system.img.meta_lic"},
+			expected: map[string][]string{
+				"system.img.meta_lic":   {"out/system.img"},
+				"highest.apex.meta_lic": {"/system/apex/highest.apex"},
+				"apacheBin.meta_lic":    {"/system/bin/apacheBin"},
+				"apacheLib.meta_lic":    {},
+				"gplLib.meta_lic":       {"/system/apex/highest.apex/lib/gplLib.so"},
+				"mitLib.meta_lic":       {"/system/apex/highest.apex/lib/mitLib.so", "/system/lib/mitLib.so"},
+			},
+		},
+		{
+			name:  "apex",
+			roots: []string{"highest.apex.meta_lic"},
+			expected: map[string][]string{
+				"highest.apex.meta_lic": {"out/system/apex/highest.apex"},
+				"gplLib.meta_lic":       {"out/system/apex/highest.apex/lib/gplLib.so"},
+				"mitLib.meta_lic":       {"out/system/apex/highest.apex/lib/mitLib.so"},
+			},
+		},
+		{
+			name:  "zip",
+			roots: []string{"libs.zip.meta_lic", "mplBin.meta_lic"},
+			expected: map[string][]string{
+				"libs.zip.meta_lic": {"dist/libs.zip"},
+				"mitLib.meta_lic":   {"dist/libs.zip/mitLib.so"},
+				"mplBin.meta_lic":   {"out/vendor/bin/mplBin"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			lg, err := ReadLicenseGraph(&installPathsFS, stderr, tt.roots)
+			if err != nil {
+				t.Fatalf("unexpected test data error: got %s, want no error", err)
+			}
+			actual := make(map[string][]string)
+			for tn, paths := range InstallPaths(lg) {
+				actual[tn.Name()] = paths
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("InstallPaths: got %v, want %v", actual, tt.expected)
+			}
+		})
+	}
+}
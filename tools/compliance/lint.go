@@ -0,0 +1,221 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+var (
+	// RecognizedAnnotations lists the edge annotations policy prescribes meaning to.
+	RecognizedAnnotations = []string{"dynamic", "static", "toolchain"}
+)
+
+// LintProblem describes a single problem with a license metadata file.
+type LintProblem struct {
+	// File identifies the license metadata file with the problem.
+	File string
+
+	// Problem describes what is wrong with the file.
+	Problem string
+}
+
+// Error returns a string describing the problem.
+func (p LintProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.File, p.Problem)
+}
+
+// LintProblemList orders arrays of LintProblem by File and Problem.
+type LintProblemList []LintProblem
+
+// Len returns the length of the list.
+func (l LintProblemList) Len() int { return len(l) }
+
+// Swap rearranges 2 elements in the list so each occupies the other's former position.
+func (l LintProblemList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// Less returns true when the `i`th element is lexicographically less than the `j`th element.
+func (l LintProblemList) Less(i, j int) bool {
+	if l[i].File == l[j].File {
+		return l[i].Problem < l[j].Problem
+	}
+	return l[i].File < l[j].File
+}
+
+// LintLicenseMetadata reads `files` and their dependencies from `rootFS`
+// like ReadLicenseGraph, but instead of stopping at the first error,
+// returns every problem found in the license metadata sorted by file.
+//
+// The problems include unreadable or unparseable files, dependencies on
+// missing files, dependencies with no file name, duplicate dependencies with
+// conflicting annotations, unrecognized annotations, unrecognized license
+// condition names, license kinds without license conditions, missing license
+// text files, empty package names, and containers without dependencies.
+func LintLicenseMetadata(rootFS fs.FS, files []string) (LintProblemList, error) {
+	return LintLicenseMetadataWithKindMap(rootFS, files, nil)
+}
+
+// LintLicenseMetadataWithKindMap lints like LintLicenseMetadata, but
+// recognizes the condition names of the classes in license kind map `km` and
+// accepts license kinds that `km` maps to license conditions.
+func LintLicenseMetadataWithKindMap(rootFS fs.FS, files []string, km *LicenseKindMap) (LintProblemList, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no license metadata to lint")
+	}
+
+	policy := km.Policy()
+	recognizedConditions := make(map[string]bool)
+	for class := UnencumberedClass; class <= SharedClass; class++ {
+		for _, name := range policy.Implies(class) {
+			recognizedConditions[name] = true
+		}
+	}
+	recognizedAnnotations := make(map[string]bool)
+	for _, a := range RecognizedAnnotations {
+		recognizedAnnotations[a] = true
+	}
+
+	problems := make(LintProblemList, 0)
+	report := func(file, format string, args ...interface{}) {
+		problems = append(problems, LintProblem{file, fmt.Sprintf(format, args...)})
+	}
+
+	// textExists caches whether each license text file exists.
+	textExists := make(map[string]bool)
+
+	// targets maps each file read to its parsed metadata or to nil if unparseable.
+	targets := make(map[string]*TargetNode)
+
+	// missing records the files that cannot be read.
+	missing := make(map[string]bool)
+
+	// read parses `file` into `targets` or records it in `missing`.
+	read := func(file string) {
+		if _, ok := targets[file]; ok || missing[file] {
+			return
+		}
+		data, err := readData(rootFS, file)
+		if err != nil {
+			missing[file] = true
+			return
+		}
+		tn := &TargetNode{name: file}
+		if err := UnmarshalLicenseMetadata(data, &tn.proto); err != nil {
+			report(file, "cannot parse license metadata: %s", err.Error())
+			tn = nil
+		}
+		targets[file] = tn
+	}
+
+	queue := make([]string, 0, len(files))
+	for _, f := range files {
+		f = MetadataFileName(f)
+		read(f)
+		if missing[f] {
+			report(f, "cannot read license metadata")
+			continue
+		}
+		queue = append(queue, f)
+	}
+
+	linted := make(map[string]bool)
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		if linted[file] {
+			continue
+		}
+		linted[file] = true
+		tn := targets[file]
+		if tn == nil {
+			continue
+		}
+
+		if len(tn.proto.GetPackageName()) == 0 {
+			report(file, "empty package name")
+		}
+		conditions := tn.proto.LicenseConditions
+		if km != nil {
+			conditions = km.licenseConditions(tn.proto.LicenseKinds, conditions)
+		}
+		for _, lc := range conditions {
+			if !recognizedConditions[lc] {
+				report(file, "unrecognized license condition %q", lc)
+			}
+		}
+		if len(tn.proto.LicenseKinds) > 0 && len(conditions) == 0 {
+			report(file, "license kinds %s without license conditions", strings.Join(tn.proto.LicenseKinds, ", "))
+		}
+		for _, text := range tn.proto.LicenseTexts {
+			exists, ok := textExists[text]
+			if !ok {
+				f, err := rootFS.Open(text)
+				if err == nil {
+					f.Close()
+				}
+				exists = err == nil
+				textExists[text] = exists
+			}
+			if !exists {
+				report(file, "missing license text file %q", text)
+			}
+		}
+		if tn.proto.GetIsContainer() && len(tn.proto.Deps) == 0 {
+			report(file, "container without dependencies")
+		}
+
+		// annotations maps each dependency to the annotations of its first edge.
+		annotations := make(map[string]string)
+		for _, ad := range tn.proto.Deps {
+			dependency := ad.GetFile()
+			if len(dependency) == 0 {
+				report(file, "dependency with no file name")
+				continue
+			}
+			edgeAnnotations := make([]string, 0, len(ad.Annotations))
+			for _, a := range ad.Annotations {
+				if len(a) == 0 {
+					continue
+				}
+				if !recognizedAnnotations[a] {
+					report(file, "unrecognized annotation %q on dependency %q", a, dependency)
+				}
+				edgeAnnotations = append(edgeAnnotations, a)
+			}
+			sort.Strings(edgeAnnotations)
+			joined := strings.Join(edgeAnnotations, ":")
+			if previous, ok := annotations[dependency]; ok {
+				if previous != joined {
+					report(file, "duplicate dependency %q with conflicting annotations [%s] and [%s]", dependency, previous, joined)
+				}
+				continue
+			}
+			annotations[dependency] = joined
+
+			read(dependency)
+			if missing[dependency] {
+				report(file, "dependency %q missing", dependency)
+				continue
+			}
+			queue = append(queue, dependency)
+		}
+	}
+
+	sort.Sort(problems)
+	return problems, nil
+}
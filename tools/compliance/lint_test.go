@@ -0,0 +1,119 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"strings"
+	"testing"
+)
+
+// lintFS is a test file system with one of each kind of problem.
+var lintFS = testFS{
+	"LICENSE": []byte("license text\n"),
+	"apacheBin.meta_lic": []byte(AOSP + "license_texts: \"LICENSE\"\n" +
+		"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mplLib.meta_lic\"\n  annotations: \"linked\"\n}\n" +
+		"deps: {\n  file: \"missingLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"emptyContainer.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"garbled.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"gplLib.meta_lic": []byte("package_name: \"Free Software\"\n" +
+		"license_kinds: \"SPDX-license-identifier-GPL-2.0\"\n" +
+		"license_texts: \"GPL-2.0-LICENSE\"\n"),
+	"mitLib.meta_lic":         []byte(MIT + "license_conditions: \"noticed\"\n"),
+	"mplLib.meta_lic":         []byte("license_kinds: \"SPDX-license-identifier-MPL-2.0\"\nlicense_conditions: \"reciprocal\"\n"),
+	"emptyContainer.meta_lic": []byte(AOSP + "is_container: true\n"),
+	"garbled.meta_lic":        []byte("package_name: {\n"),
+}
+
+func TestLintLicenseMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		kinds    string
+		roots    []string
+		expected []string
+	}{
+		{
+			name:  "all",
+			roots: []string{"apacheBin.meta_lic"},
+			expected: []string{
+				"apacheBin.meta_lic: dependency \"missingLib.meta_lic\" missing",
+				"apacheBin.meta_lic: dependency with no file name",
+				"apacheBin.meta_lic: duplicate dependency \"gplLib.meta_lic\" with conflicting annotations [static] and [dynamic]",
+				"apacheBin.meta_lic: unrecognized annotation \"linked\" on dependency \"mplLib.meta_lic\"",
+				"emptyContainer.meta_lic: container without dependencies",
+				"garbled.meta_lic: cannot parse license metadata",
+				"gplLib.meta_lic: license kinds SPDX-license-identifier-GPL-2.0 without license conditions",
+				"gplLib.meta_lic: missing license text file \"GPL-2.0-LICENSE\"",
+				"mitLib.meta_lic: unrecognized license condition \"noticed\"",
+				"mplLib.meta_lic: empty package name",
+			},
+		},
+		{
+			name:  "unreadable",
+			roots: []string{"LICENSE", "mitLib"},
+			expected: []string{
+				"LICENSE.meta_lic: cannot read license metadata",
+				"mitLib.meta_lic: unrecognized license condition \"noticed\"",
+			},
+		},
+		{
+			name: "kindmap",
+			kinds: `{
+  "condition_classes": {"notice": ["notice", "noticed"]},
+  "license_kinds": [{"kind": "SPDX-license-identifier-GPL-2.0", "conditions": ["restricted"]}]
+}`,
+			roots: []string{"gplLib", "mitLib"},
+			expected: []string{
+				"gplLib.meta_lic: missing license text file \"GPL-2.0-LICENSE\"",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var km *LicenseKindMap
+			if len(tt.kinds) > 0 {
+				var err error
+				km, err = ParseLicenseKindMap([]byte(tt.kinds))
+				if err != nil {
+					t.Fatalf("unexpected test data error: got %s, want no error", err)
+				}
+			}
+			problems, err := LintLicenseMetadataWithKindMap(&lintFS, tt.roots, km)
+			if err != nil {
+				t.Fatalf("LintLicenseMetadata: got error %v, want no error", err)
+			}
+			actual := make([]string, 0, len(problems))
+			for _, p := range problems {
+				actual = append(actual, p.Error())
+			}
+			// parse errors end with the detail from the proto library
+			matches := len(actual) == len(tt.expected)
+			for i := 0; matches && i < len(actual); i++ {
+				matches = strings.HasPrefix(actual[i], tt.expected[i])
+			}
+			if !matches {
+				t.Errorf("LintLicenseMetadata: got %q, want %q", actual, tt.expected)
+			}
+		})
+	}
+
+	if _, err := LintLicenseMetadata(&lintFS, []string{}); err == nil {
+		t.Errorf("LintLicenseMetadata: got no error for no files, want error")
+	}
+}
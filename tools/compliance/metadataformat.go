@@ -0,0 +1,134 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"android/soong/compliance/license_metadata_proto"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// MetadataFormat identifies an encoding of license metadata files.
+type MetadataFormat int
+
+const (
+	// TextFormat is the protobuf text format written by the build.
+	TextFormat MetadataFormat = iota
+
+	// BinaryFormat is the protobuf binary wire format.
+	BinaryFormat
+
+	// JSONFormat is the protobuf JSON format.
+	JSONFormat
+)
+
+// metadataFormatNames maps each format to its name.
+var metadataFormatNames = map[MetadataFormat]string{
+	TextFormat:   "text",
+	BinaryFormat: "binary",
+	JSONFormat:   "json",
+}
+
+// String returns the name of the format: text, binary or json.
+func (f MetadataFormat) String() string {
+	if name, ok := metadataFormatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("MetadataFormat(%d)", int(f))
+}
+
+// ParseMetadataFormat returns the format named `name`: text, binary or json.
+func ParseMetadataFormat(name string) (MetadataFormat, error) {
+	for f, n := range metadataFormatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return TextFormat, fmt.Errorf("unknown license metadata format %q: want text, binary or json", name)
+}
+
+// MetadataFileName returns the name of the license metadata file read for
+// `file`: unchanged when `file` ends in .meta_lic, and with .meta_lic
+// appended otherwise. e.g. etc/foo.json reads etc/foo.json.meta_lic
+func MetadataFileName(file string) string {
+	if strings.HasSuffix(file, ".meta_lic") {
+		return file
+	}
+	return file + ".meta_lic"
+}
+
+// DetectMetadataFormat returns the format of license metadata `data`.
+//
+// JSON starts with "{", text contains only printable UTF-8 and whitespace,
+// and anything else is binary. Binary data can look like text or JSON, so
+// tools that must read such data take an explicit format instead.
+func DetectMetadataFormat(data []byte) MetadataFormat {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return JSONFormat
+	}
+	if !utf8.Valid(data) {
+		return BinaryFormat
+	}
+	for _, r := range string(data) {
+		if r < ' ' && r != '\t' && r != '\n' && r != '\r' {
+			return BinaryFormat
+		}
+	}
+	return TextFormat
+}
+
+// UnmarshalLicenseMetadata decodes license metadata `data` in the format
+// given by DetectMetadataFormat into `pb`.
+func UnmarshalLicenseMetadata(data []byte, pb *license_metadata_proto.LicenseMetadata) error {
+	return UnmarshalLicenseMetadataAs(data, DetectMetadataFormat(data), pb)
+}
+
+// UnmarshalLicenseMetadataAs decodes license metadata `data` in `format`
+// into `pb`.
+func UnmarshalLicenseMetadataAs(data []byte, format MetadataFormat, pb *license_metadata_proto.LicenseMetadata) error {
+	switch format {
+	case TextFormat:
+		return prototext.Unmarshal(data, pb)
+	case BinaryFormat:
+		return proto.Unmarshal(data, pb)
+	case JSONFormat:
+		return protojson.Unmarshal(data, pb)
+	}
+	return fmt.Errorf("unknown license metadata format %s", format.String())
+}
+
+// MarshalLicenseMetadata encodes `pb` in `format`.
+//
+// Only the BinaryFormat output is deterministic. The text and JSON encoders
+// deliberately vary their whitespace between builds of the program.
+func MarshalLicenseMetadata(pb *license_metadata_proto.LicenseMetadata, format MetadataFormat) ([]byte, error) {
+	switch format {
+	case TextFormat:
+		return prototext.MarshalOptions{Multiline: true}.Marshal(pb)
+	case BinaryFormat:
+		return proto.MarshalOptions{Deterministic: true}.Marshal(pb)
+	case JSONFormat:
+		return protojson.MarshalOptions{Multiline: true}.Marshal(pb)
+	}
+	return nil, fmt.Errorf("unknown license metadata format %s", format.String())
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"android/soong/compliance/license_metadata_proto"
+
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// encodeMetadata returns the text license metadata `text` encoded in `format`.
+func encodeMetadata(t *testing.T, text string, format MetadataFormat) []byte {
+	var pb license_metadata_proto.LicenseMetadata
+	if err := prototext.Unmarshal([]byte(text), &pb); err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	data, err := MarshalLicenseMetadata(&pb, format)
+	if err != nil {
+		t.Fatalf("MarshalLicenseMetadata(%s): got error %s, want no error", format, err)
+	}
+	return data
+}
+
+func TestMetadataFormat(t *testing.T) {
+	text := GPL + "installed: \"out/system/lib/gplLib.so\"\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n"
+	var expected license_metadata_proto.LicenseMetadata
+	if err := prototext.Unmarshal([]byte(text), &expected); err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+
+	for _, format := range []MetadataFormat{TextFormat, BinaryFormat, JSONFormat} {
+		t.Run(format.String(), func(t *testing.T) {
+			parsed, err := ParseMetadataFormat(format.String())
+			if err != nil || parsed != format {
+				t.Errorf("ParseMetadataFormat(%q): got %s, %v, want %s", format.String(), parsed, err, format)
+			}
+
+			data := encodeMetadata(t, text, format)
+			if detected := DetectMetadataFormat(data); detected != format {
+				t.Errorf("DetectMetadataFormat: got %s, want %s", detected, format)
+			}
+			var actual license_metadata_proto.LicenseMetadata
+			if err := UnmarshalLicenseMetadata(data, &actual); err != nil {
+				t.Fatalf("UnmarshalLicenseMetadata: got error %s, want no error", err)
+			}
+			if !proto.Equal(&actual, &expected) {
+				t.Errorf("UnmarshalLicenseMetadata: got %v, want %v", &actual, &expected)
+			}
+
+			var forced license_metadata_proto.LicenseMetadata
+			if err := UnmarshalLicenseMetadataAs(data, format, &forced); err != nil {
+				t.Fatalf("UnmarshalLicenseMetadataAs: got error %s, want no error", err)
+			}
+			if !proto.Equal(&forced, &expected) {
+				t.Errorf("UnmarshalLicenseMetadataAs: got %v, want %v", &forced, &expected)
+			}
+
+			if format == BinaryFormat {
+				again := encodeMetadata(t, text, format)
+				if !bytes.Equal(data, again) {
+					t.Errorf("MarshalLicenseMetadata: got %q then %q, want same output", data, again)
+				}
+			}
+		})
+	}
+
+	contents := []struct {
+		data     string
+		expected MetadataFormat
+	}{
+		{"", TextFormat},
+		{"  {\n}\n", JSONFormat},
+		{"package_name: \"Android\"\n", TextFormat},
+		{"\x12\x07Android", BinaryFormat},
+		{"\xff", BinaryFormat},
+	}
+	for _, tt := range contents {
+		if actual := DetectMetadataFormat([]byte(tt.data)); actual != tt.expected {
+			t.Errorf("DetectMetadataFormat(%q): got %s, want %s", tt.data, actual, tt.expected)
+		}
+	}
+
+	if err := UnmarshalLicenseMetadataAs(nil, MetadataFormat(-1), &license_metadata_proto.LicenseMetadata{}); err == nil {
+		t.Errorf("UnmarshalLicenseMetadataAs(MetadataFormat(-1)): got no error, want error")
+	}
+
+	if _, err := ParseMetadataFormat("yaml"); err == nil {
+		t.Errorf("ParseMetadataFormat(\"yaml\"): got no error, want error")
+	}
+}
+
+func TestMetadataFileName(t *testing.T) {
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"lib.meta_lic", "lib.meta_lic"},
+		{"bin/app", "bin/app.meta_lic"},
+		{"etc/foo.json", "etc/foo.json.meta_lic"},
+		{"lib.pb", "lib.pb.meta_lic"},
+	}
+	for _, tt := range tests {
+		if actual := MetadataFileName(tt.file); actual != tt.expected {
+			t.Errorf("MetadataFileName(%q): got %q, want %q", tt.file, actual, tt.expected)
+		}
+	}
+}
+
+func TestReadLicenseGraph_formats(t *testing.T) {
+	fs := testFS{
+		"apacheBin.json.meta_lic": encodeMetadata(t, AOSP+
+			"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"static\"\n}\n"+
+			"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n", JSONFormat),
+		"gplLib.meta_lic": encodeMetadata(t, GPL, BinaryFormat),
+		"mitLib.meta_lic": encodeMetadata(t, MIT, TextFormat),
+	}
+	lg, err := ReadLicenseGraph(&fs, &bytes.Buffer{}, []string{"apacheBin.json"})
+	if err != nil {
+		t.Fatalf("ReadLicenseGraph: got error %s, want no error", err)
+	}
+	actual := make([]string, 0)
+	for _, e := range lg.Edges() {
+		actual = append(actual, e.Target().Name()+" -> "+e.Dependency().Name()+" "+e.Dependency().PackageName())
+	}
+	sort.Strings(actual)
+	checkSameStrings("edge", actual, []string{
+		"apacheBin.json.meta_lic -> gplLib.meta_lic Free Software",
+		"apacheBin.json.meta_lic -> mitLib.meta_lic Android",
+	}, t)
+}
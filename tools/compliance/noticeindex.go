@@ -0,0 +1,184 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// NoticeIndex groups the install paths of distributed targets by the
+// identical license texts for which policy requires notice.
+//
+// Each distinct license text appears once in the index regardless of how
+// many targets or license text files contain the same text.
+type NoticeIndex struct {
+	// hashes lists the hashes of the distinct license texts in index order.
+	hashes []string
+
+	// text maps license text hashes to the license text.
+	text map[string][]byte
+
+	// hashInstalls maps license text hashes to the install paths requiring notice.
+	hashInstalls map[string]map[string]bool
+
+	// installHashes maps install paths to the hashes of the license texts requiring notice.
+	installHashes map[string]map[string]bool
+}
+
+// IndexLicenseTexts reads the license texts for every target acted on by the
+// notice resolutions of `lg` from `rootFS` and indexes the install paths of
+// the targets the resolutions attach to.
+//
+// Targets without license texts have nothing to index and get skipped.
+// Targets without installed files get indexed by their built files.
+func IndexLicenseTexts(rootFS fs.FS, lg *LicenseGraph) (*NoticeIndex, error) {
+	return IndexLicenseTextsWithPolicy(rootFS, lg, DefaultPolicy)
+}
+
+// IndexLicenseTextsWithPolicy indexes like IndexLicenseTexts, but resolves
+// notices under `policy`.
+func IndexLicenseTextsWithPolicy(rootFS fs.FS, lg *LicenseGraph, policy Policy) (*NoticeIndex, error) {
+	ni := &NoticeIndex{
+		hashes:        []string{},
+		text:          make(map[string][]byte),
+		hashInstalls:  make(map[string]map[string]bool),
+		installHashes: make(map[string]map[string]bool),
+	}
+
+	// fileHash caches the hash for each license text file already read.
+	fileHash := make(map[string]string)
+
+	hashesFor := func(tn *TargetNode) ([]string, error) {
+		result := make([]string, 0, len(tn.proto.LicenseTexts))
+		for _, f := range tn.proto.LicenseTexts {
+			if h, ok := fileHash[f]; ok {
+				result = append(result, h)
+				continue
+			}
+			text, err := fs.ReadFile(rootFS, f)
+			if err != nil {
+				return nil, fmt.Errorf("error reading license text file %q for %q: %w", f, tn.name, err)
+			}
+			h := fmt.Sprintf("%x", sha256.Sum256(text))
+			fileHash[f] = h
+			if _, ok := ni.text[h]; !ok {
+				ni.text[h] = text
+				ni.hashInstalls[h] = make(map[string]bool)
+			}
+			result = append(result, h)
+		}
+		return result, nil
+	}
+
+	rs := ResolveNoticesWithPolicy(lg, policy)
+	attachesTo := rs.AttachesTo()
+	sort.Sort(attachesTo)
+	for _, target := range attachesTo {
+		installed := target.Installed()
+		if len(installed) == 0 {
+			installed = target.Built()
+		}
+		if len(installed) == 0 {
+			continue
+		}
+		rl := rs.Resolutions(target)
+		sort.Sort(rl)
+		for _, r := range rl {
+			hashes, err := hashesFor(r.actsOn)
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range hashes {
+				for _, path := range installed {
+					ni.hashInstalls[h][path] = true
+					if _, ok := ni.installHashes[path]; !ok {
+						ni.installHashes[path] = make(map[string]bool)
+					}
+					ni.installHashes[path][h] = true
+				}
+			}
+		}
+	}
+
+	// order the license texts by the first install path requiring each then by text
+	first := make(map[string]string)
+	for h, paths := range ni.hashInstalls {
+		if len(paths) == 0 {
+			continue
+		}
+		ni.hashes = append(ni.hashes, h)
+		for path := range paths {
+			if f, ok := first[h]; !ok || path < f {
+				first[h] = path
+			}
+		}
+	}
+	sort.Slice(ni.hashes, func(i, j int) bool {
+		hi, hj := ni.hashes[i], ni.hashes[j]
+		if first[hi] == first[hj] {
+			return bytes.Compare(ni.text[hi], ni.text[hj]) < 0
+		}
+		return first[hi] < first[hj]
+	})
+
+	return ni, nil
+}
+
+// Hashes returns the hashes of the distinct license texts in index order.
+func (ni *NoticeIndex) Hashes() []string {
+	return append([]string{}, ni.hashes...)
+}
+
+// HashText returns the license text for hash `h`.
+func (ni *NoticeIndex) HashText(h string) []byte {
+	return ni.text[h]
+}
+
+// HashInstallPaths returns the ordered list of install paths requiring notice
+// of the license text with hash `h`.
+func (ni *NoticeIndex) HashInstallPaths(h string) []string {
+	result := make([]string, 0, len(ni.hashInstalls[h]))
+	for path := range ni.hashInstalls[h] {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// InstallPaths returns the ordered list of install paths requiring notice.
+func (ni *NoticeIndex) InstallPaths() []string {
+	result := make([]string, 0, len(ni.installHashes))
+	for path := range ni.installHashes {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// InstallHashes returns the hashes of the license texts requiring notice for
+// install path `path` in index order.
+func (ni *NoticeIndex) InstallHashes(path string) []string {
+	result := make([]string, 0, len(ni.installHashes[path]))
+	for _, h := range ni.hashes {
+		if ni.installHashes[path][h] {
+			result = append(result, h)
+		}
+	}
+	return result
+}
@@ -0,0 +1,124 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIndexLicenseTexts(t *testing.T) {
+	tests := []struct {
+		name          string
+		fs            *testFS
+		roots         []string
+		expectedError string
+		expectedIndex []string
+	}{
+		{
+			name: "singleton",
+			fs: &testFS{
+				"bin.meta_lic": []byte(AOSP + "license_texts: \"AOSP\"\ninstalled: \"/system/bin/bin\"\n"),
+				"AOSP":         []byte("apache"),
+			},
+			roots:         []string{"bin.meta_lic"},
+			expectedIndex: []string{"apache: /system/bin/bin"},
+		},
+		{
+			name: "dedup",
+			fs: &testFS{
+				"bin.meta_lic": []byte(AOSP + "license_texts: \"AOSP\"\ninstalled: \"/system/bin/bin\"\n" +
+					"deps: {\n  file: \"lib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+					"deps: {\n  file: \"mit.meta_lic\"\n  annotations: \"static\"\n}\n"),
+				"lib.meta_lic": []byte(AOSP + "license_texts: \"COPY_OF_AOSP\"\n"),
+				"mit.meta_lic": []byte(MIT + "license_texts: \"MIT\"\n"),
+				"AOSP":         []byte("apache"),
+				"COPY_OF_AOSP": []byte("apache"),
+				"MIT":          []byte("mit"),
+			},
+			roots:         []string{"bin.meta_lic"},
+			expectedIndex: []string{"apache: /system/bin/bin", "mit: /system/bin/bin"},
+		},
+		{
+			name: "grouped",
+			fs: &testFS{
+				"container.meta_lic": []byte(AOSP + "is_container: true\n" +
+					"deps: {\n  file: \"bin1.meta_lic\"\n  annotations: \"static\"\n}\n" +
+					"deps: {\n  file: \"bin2.meta_lic\"\n  annotations: \"static\"\n}\n"),
+				"bin1.meta_lic": []byte(MIT + "license_texts: \"MIT\"\ninstalled: \"/system/bin/bin1\"\n"),
+				"bin2.meta_lic": []byte(MIT + "license_texts: \"MIT\"\ninstalled: \"/system/bin/bin2\"\n"),
+				"MIT":           []byte("mit"),
+			},
+			roots:         []string{"container.meta_lic"},
+			expectedIndex: []string{"mit: /system/bin/bin1 /system/bin/bin2"},
+		},
+		{
+			name: "builtonly",
+			fs: &testFS{
+				"bin.meta_lic": []byte(AOSP + "license_texts: \"AOSP\"\nbuilt: \"out/bin\"\n"),
+				"AOSP":         []byte("apache"),
+			},
+			roots:         []string{"bin.meta_lic"},
+			expectedIndex: []string{"apache: out/bin"},
+		},
+		{
+			name: "missingtext",
+			fs: &testFS{
+				"bin.meta_lic": []byte(AOSP + "license_texts: \"AOSP\"\ninstalled: \"/system/bin/bin\"\n"),
+			},
+			roots:         []string{"bin.meta_lic"},
+			expectedError: `error reading license text file "AOSP"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lg, err := ReadLicenseGraph(tt.fs, &bytes.Buffer{}, tt.roots)
+			if err != nil {
+				t.Fatalf("unexpected test data error: got %s, want no error", err)
+			}
+			ni, err := IndexLicenseTexts(tt.fs, lg)
+			if err != nil {
+				if len(tt.expectedError) == 0 {
+					t.Errorf("unexpected error: got %s, want no error", err)
+				} else if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("unexpected error: got %s, want %q", err, tt.expectedError)
+				}
+				return
+			}
+			if len(tt.expectedError) > 0 {
+				t.Fatalf("unexpected success: got no error, want %q", tt.expectedError)
+			}
+			actualIndex := make([]string, 0)
+			for _, h := range ni.Hashes() {
+				actualIndex = append(actualIndex, string(ni.HashText(h))+": "+strings.Join(ni.HashInstallPaths(h), " "))
+			}
+			checkSameStrings("index entry", actualIndex, tt.expectedIndex, t)
+			for _, path := range ni.InstallPaths() {
+				for _, h := range ni.InstallHashes(path) {
+					found := false
+					for _, p := range ni.HashInstallPaths(h) {
+						if p == path {
+							found = true
+						}
+					}
+					if !found {
+						t.Errorf("unexpected inconsistent index: %q not in install paths for %q", path, string(ni.HashText(h)))
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// PrefixRewrites maps path prefixes to their replacements in reports. e.g.
+// "out/target/product/x/" to "<product>/" makes the reports for different
+// products comparable.
+//
+// Implements the flag `Value` interface for flags given as from=to.
+type PrefixRewrites map[string]string
+
+// Rewrite returns `path` with the longest matching prefix replaced, or returns
+// `path` unchanged when no prefix matches.
+func (pr PrefixRewrites) Rewrite(path string) string {
+	longest := ""
+	matched := false
+	for from := range pr {
+		if strings.HasPrefix(path, from) && (!matched || len(from) > len(longest)) {
+			longest = from
+			matched = true
+		}
+	}
+	if !matched {
+		return path
+	}
+	return pr[longest] + strings.TrimPrefix(path, longest)
+}
+
+// String returns the rewrites as from=to in prefix order.
+func (pr *PrefixRewrites) String() string {
+	if pr == nil {
+		return ""
+	}
+	rewrites := make([]string, 0, len(*pr))
+	for from, to := range *pr {
+		rewrites = append(rewrites, from+"="+to)
+	}
+	sort.Strings(rewrites)
+	return strings.Join(rewrites, ", ")
+}
+
+// Set adds the rewrite `s` given as from=to.
+func (pr *PrefixRewrites) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 {
+		return fmt.Errorf("prefix rewrite %q not from=to", s)
+	}
+	from, to := parts[0], parts[1]
+	if *pr == nil {
+		*pr = make(PrefixRewrites)
+	}
+	if existing, ok := (*pr)[from]; ok && existing != to {
+		return fmt.Errorf("prefix %q rewritten to both %q and %q", from, existing, to)
+	}
+	(*pr)[from] = to
+	return nil
+}
+
+// PathFlags holds the flags shared by the compliance commands for locating
+// the license metadata and for naming files in reports.
+type PathFlags struct {
+	// Root is the directory holding the license metadata.
+	Root string
+
+	// Archive is a zip, tar or tar.gz archive holding the license metadata.
+	Archive string
+
+	// ArchiveStripPrefix is the directory prefix to remove from the paths
+	// inside Archive.
+	ArchiveStripPrefix string
+
+	// StripPrefix is the prefix to remove from the paths in reports.
+	StripPrefix string
+
+	// Rewrites maps the prefixes of the paths in reports to their
+	// replacements after removing StripPrefix.
+	Rewrites PrefixRewrites
+
+	// RootFS, when not nil, is the file system holding the license metadata
+	// in place of Root and Archive. e.g. an in-memory file system in tests
+	RootFS fs.FS
+}
+
+// NewPathFlags registers both the input and the output path flags on `fset`.
+func NewPathFlags(fset *flag.FlagSet) *PathFlags {
+	pf := &PathFlags{}
+	pf.registerInput(fset)
+	pf.registerOutput(fset)
+	return pf
+}
+
+// NewInputPathFlags registers only the flags locating the license metadata,
+// -root, -archive and -archive_strip_prefix, on `fset`.
+func NewInputPathFlags(fset *flag.FlagSet) *PathFlags {
+	pf := &PathFlags{}
+	pf.registerInput(fset)
+	return pf
+}
+
+// NewOutputPathFlags registers only the flags naming files in reports,
+// -strip_prefix and -rewrite_prefix, on `fset`.
+func NewOutputPathFlags(fset *flag.FlagSet) *PathFlags {
+	pf := &PathFlags{}
+	pf.registerOutput(fset)
+	return pf
+}
+
+// registerInput registers -root, -archive and -archive_strip_prefix.
+func (pf *PathFlags) registerInput(fset *flag.FlagSet) {
+	fset.StringVar(&pf.Root, "root", ".", "Directory to read the license metadata from, or with -archive, directory inside the archive.")
+	fset.StringVar(&pf.Archive, "archive", "", "Zip, tar or tar.gz archive to read the license metadata from instead of the file system. (optional)")
+	fset.StringVar(&pf.ArchiveStripPrefix, "archive_strip_prefix", "", "Directory prefix to remove from the paths inside -archive. (optional)")
+}
+
+// registerOutput registers -strip_prefix and -rewrite_prefix.
+func (pf *PathFlags) registerOutput(fset *flag.FlagSet) {
+	fset.StringVar(&pf.StripPrefix, "strip_prefix", "", "Prefix to remove from paths. i.e. path to root")
+	fset.Var(&pf.Rewrites, "rewrite_prefix", "Path prefix to rewrite after -strip_prefix given as from=to, e.g. out/target/product/x/=<product>/ (may be given multiple times)")
+}
+
+// OpenRoot returns the file system holding the license metadata: RootFS when
+// not nil, directory Root of Archive when given, or else directory Root. The
+// zero PathFlags open the current directory.
+func (pf *PathFlags) OpenRoot() (fs.FS, error) {
+	if pf.RootFS != nil {
+		return pf.RootFS, nil
+	}
+	root := pf.Root
+	if len(root) == 0 {
+		root = "."
+	}
+	if len(pf.Archive) == 0 {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("-root %q is not a directory", root)
+		}
+		return os.DirFS(root), nil
+	}
+	afs, err := OpenArchiveFS(pf.Archive, path.Join(pf.ArchiveStripPrefix, root))
+	if err != nil {
+		return nil, err
+	}
+	return afs, nil
+}
+
+// OutputName returns `name` as it appears in reports: with StripPrefix
+// removed and any Rewrites applied.
+func (pf *PathFlags) OutputName(name string) string {
+	return pf.Rewrites.Rewrite(strings.TrimPrefix(name, pf.StripPrefix))
+}
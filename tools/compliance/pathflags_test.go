@@ -0,0 +1,168 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrefixRewrites(t *testing.T) {
+	var pr PrefixRewrites
+	for _, s := range []string{"out/target/product/x/=<product>/", "out/=<out>/", "out/target/product/x/=<product>/"} {
+		if err := pr.Set(s); err != nil {
+			t.Fatalf("Set(%q): got error %v, want no error", s, err)
+		}
+	}
+	if pr.String() != "out/=<out>/, out/target/product/x/=<product>/" {
+		t.Errorf("String(): got %q, want %q", pr.String(), "out/=<out>/, out/target/product/x/=<product>/")
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"out/target/product/x/system/bin/bin1", "<product>/system/bin/bin1"},
+		{"out/target/product/y/system/bin/bin1", "<out>/target/product/y/system/bin/bin1"},
+		{"external/x/bin1.meta_lic", "external/x/bin1.meta_lic"},
+	}
+	for _, tt := range tests {
+		if actual := pr.Rewrite(tt.path); actual != tt.expected {
+			t.Errorf("Rewrite(%q): got %q, want %q", tt.path, actual, tt.expected)
+		}
+	}
+
+	for _, s := range []string{"out/", "=<out>/", "out/=<other>/"} {
+		if err := pr.Set(s); err == nil {
+			t.Errorf("Set(%q): got no error, want error", s)
+		}
+	}
+
+	var empty PrefixRewrites
+	if actual := empty.Rewrite("out/bin1"); actual != "out/bin1" {
+		t.Errorf("Rewrite(%q) without rewrites: got %q, want %q", "out/bin1", actual, "out/bin1")
+	}
+}
+
+func TestPathFlags(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "out", "bin"), 0777); err != nil {
+		t.Fatalf("cannot create directories: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "out", "bin", "bin1.meta_lic"), []byte(AOSP), 0666); err != nil {
+		t.Fatalf("cannot write metadata: %v", err)
+	}
+	archive := filepath.Join(dir, "meta_lic.tar")
+	writeTar(t, archive, false)
+
+	tests := []struct {
+		name          string
+		newFlags      func(fset *flag.FlagSet) *PathFlags
+		args          []string
+		expectedFile  string
+		expectedError bool
+	}{
+		{
+			name:         "root",
+			newFlags:     NewPathFlags,
+			args:         []string{"-root", filepath.Join(dir, "out"), "-strip_prefix", "bin/", "-rewrite_prefix", "bin1=binary1"},
+			expectedFile: "bin/bin1.meta_lic",
+		},
+		{
+			name:         "archive",
+			newFlags:     NewInputPathFlags,
+			args:         []string{"-archive", archive, "-archive_strip_prefix", "out", "-root", "product"},
+			expectedFile: "lib/gplLib.meta_lic",
+		},
+		{
+			name:          "root not directory",
+			newFlags:      NewInputPathFlags,
+			args:          []string{"-root", archive},
+			expectedError: true,
+		},
+		{
+			name:          "root missing",
+			newFlags:      NewInputPathFlags,
+			args:          []string{"-root", filepath.Join(dir, "missing")},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := flag.NewFlagSet(tt.name, flag.ContinueOnError)
+			fset.SetOutput(io.Discard)
+			pf := tt.newFlags(fset)
+			if err := fset.Parse(tt.args); err != nil {
+				t.Fatalf("Parse(%q): got error %v, want no error", tt.args, err)
+			}
+			rootFS, err := pf.OpenRoot()
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("OpenRoot(): got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OpenRoot(): got error %v, want no error", err)
+			}
+			if _, err := fs.ReadFile(rootFS, tt.expectedFile); err != nil {
+				t.Errorf("OpenRoot(): got error %v reading %q, want no error", err, tt.expectedFile)
+			}
+		})
+	}
+
+	fset := flag.NewFlagSet("output", flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+	pf := NewOutputPathFlags(fset)
+	if err := fset.Parse([]string{"-root", dir}); err == nil {
+		t.Errorf("NewOutputPathFlags: got no error parsing -root, want error")
+	}
+	if err := fset.Parse([]string{"-strip_prefix", "out/", "-rewrite_prefix", "bin/=b/"}); err != nil {
+		t.Fatalf("NewOutputPathFlags: got error %v, want no error", err)
+	}
+	if pf.StripPrefix != "out/" || pf.Rewrites.Rewrite("bin/bin1") != "b/bin1" {
+		t.Errorf("NewOutputPathFlags: got strip prefix %q and rewrites %q, want \"out/\" and \"bin/=b/\"", pf.StripPrefix, pf.Rewrites.String())
+	}
+}
+
+func TestPathFlags_zero(t *testing.T) {
+	var pf PathFlags
+	rootFS, err := pf.OpenRoot()
+	if err != nil {
+		t.Fatalf("OpenRoot(): got error %v, want no error", err)
+	}
+	if _, err := fs.Stat(rootFS, "pathflags.go"); err != nil {
+		t.Errorf("OpenRoot(): got %v, want the current directory", err)
+	}
+	if actual := pf.OutputName("out/bin1"); actual != "out/bin1" {
+		t.Errorf("OutputName(%q): got %q, want %q", "out/bin1", actual, "out/bin1")
+	}
+
+	pf = PathFlags{
+		StripPrefix: "out/target/product/",
+		Rewrites:    PrefixRewrites{"x/": "<product>/"},
+		RootFS:      &testFS{},
+	}
+	if rootFS, err := pf.OpenRoot(); err != nil || rootFS == nil {
+		t.Errorf("OpenRoot(): got %v, %v, want RootFS", rootFS, err)
+	}
+	if actual := pf.OutputName("out/target/product/x/bin1"); actual != "<product>/bin1" {
+		t.Errorf("OutputName(%q): got %q, want %q", "out/target/product/x/bin1", actual, "<product>/bin1")
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"sort"
+)
+
+// ExplainedEdge describes an edge along a ShareExplanation path.
+type ExplainedEdge struct {
+	// Edge identifies the target, dependency and annotations.
+	Edge TargetEdge
+
+	// Crossed is true when the license condition crosses the edge.
+	Crossed bool
+
+	// Rule describes the policy rule letting the condition cross the edge.
+	// Empty unless `Crossed` is true.
+	Rule string
+}
+
+// ShareExplanation describes one way a source-sharing condition reaches a
+// target.
+//
+// The condition originates at `Condition.Origin()`, climbs up `OriginPath`
+// from the origin to the last node the paths have in common, and descends
+// `TargetPath` from that node to `Target`. Both paths start at the same root.
+type ShareExplanation struct {
+	// Target identifies the target that must share source.
+	Target *TargetNode
+
+	// Condition identifies the source-sharing license condition.
+	Condition LicenseCondition
+
+	// TargetPath lists the edges from the root to `Target`.
+	TargetPath []ExplainedEdge
+
+	// OriginPath lists the edges from the root to the origin of `Condition`.
+	OriginPath []ExplainedEdge
+}
+
+// ExplainSourceSharing returns every explanation of how the source-sharing
+// conditions resolved by `target` under `policy` reach the target from the
+// roots of `lg`.
+//
+// The number of explanations grows with the number of paths through the
+// graph. Use ExplainSourceSharingLimit to bound it.
+func ExplainSourceSharing(lg *LicenseGraph, policy Policy, target *TargetNode) []ShareExplanation {
+	result, _ := ExplainSourceSharingLimit(lg, policy, target, 0)
+	return result
+}
+
+// ExplainSourceSharingLimit returns at most `limit` explanations of how the
+// source-sharing conditions resolved by `target` under `policy` reach the
+// target from the roots of `lg`, and whether it left out any explanations.
+// Shorter paths come first. A `limit` of 0 returns every explanation.
+func ExplainSourceSharingLimit(lg *LicenseGraph, policy Policy, target *TargetNode, limit int) ([]ShareExplanation, bool) {
+	rs := WalkResolutionsForCondition(lg, policy, ResolveTopDownConditions(lg, policy), policy.Implies(SharedClass))
+	conditions := newLicenseConditionSet()
+	for _, r := range rs.ResolutionsByActsOn(target) {
+		conditions.AddSet(r.Resolves())
+	}
+	cl := conditions.AsList()
+	sort.Sort(cl)
+
+	// nodes of interest are the target and the origins of the conditions
+	interesting := make(map[*TargetNode]bool)
+	interesting[target] = true
+	for _, lc := range cl {
+		interesting[lc.origin] = true
+	}
+	paths := make(map[*TargetNode][]TargetEdgePath)
+	WalkTopDown(lg, func(lg *LicenseGraph, tn *TargetNode, path TargetEdgePath) bool {
+		if interesting[tn] {
+			paths[tn] = append(paths[tn], append(TargetEdgePath{}, path...))
+		}
+		return true
+	})
+	for _, tps := range paths {
+		sort.Sort(byPathLength(tps))
+	}
+
+	explainer, _ := policy.(PolicyExplainer)
+
+	result := make([]ShareExplanation, 0)
+	for _, lc := range cl {
+		for _, tp := range paths[target] {
+			for _, op := range paths[lc.origin] {
+				if lc.origin == target && !samePath(tp, op) {
+					continue
+				}
+				if x, ok := explainPaths(lg, policy, explainer, target, lc, tp, op); ok {
+					if limit > 0 && len(result) == limit {
+						return result, true
+					}
+					result = append(result, x)
+				}
+			}
+		}
+	}
+	return result, false
+}
+
+// explainPaths returns the explanation for `lc` reaching `target` along
+// target path `tp` and origin path `op` if the condition crosses every edge
+// after the paths diverge.
+func explainPaths(lg *LicenseGraph, policy Policy, explainer PolicyExplainer, target *TargetNode, lc LicenseCondition, tp, op TargetEdgePath) (ShareExplanation, bool) {
+	if pathRoot(lg, tp, target) != pathRoot(lg, op, lc.origin) {
+		return ShareExplanation{}, false
+	}
+	common := 0
+	for common < len(tp) && common < len(op) && tp[common].e == op[common].e {
+		common++
+	}
+
+	// the divergent parts of the paths must not meet again
+	seen := make(map[string]bool)
+	for _, e := range tp[common:] {
+		seen[e.e.dependency] = true
+	}
+	for _, e := range op[common:] {
+		if seen[e.e.dependency] {
+			return ShareExplanation{}, false
+		}
+	}
+
+	x := ShareExplanation{
+		Target:     target,
+		Condition:  lc,
+		TargetPath: make([]ExplainedEdge, 0, len(tp)),
+		OriginPath: make([]ExplainedEdge, 0, len(op)),
+	}
+
+	// the condition climbs from the origin up to the common node
+	aggregates := pathAggregates(lg, op)
+	for i := len(op) - 1; i >= 0; i-- {
+		if i < common {
+			break
+		}
+		if _, actsOnTarget := policy.BottomUp(op[i], lc.origin, lc, aggregates[i]); !actsOnTarget {
+			return ShareExplanation{}, false
+		}
+	}
+	for i, e := range op {
+		xe := ExplainedEdge{Edge: e}
+		if i >= common {
+			xe.Crossed = true
+			xe.Rule = "bottom-up"
+			if explainer != nil {
+				xe.Rule = explainer.ExplainBottomUp(e, lc.origin, lc, aggregates[i])
+			}
+		}
+		x.OriginPath = append(x.OriginPath, xe)
+	}
+
+	// the condition descends from the common node down to the target
+	aggregates = pathAggregates(lg, tp)
+	for i, e := range tp {
+		xe := ExplainedEdge{Edge: e}
+		if i >= common {
+			if !policy.TopDown(e, lc, aggregates[i]) {
+				return ShareExplanation{}, false
+			}
+			xe.Crossed = true
+			xe.Rule = "top-down"
+			if explainer != nil {
+				xe.Rule = explainer.ExplainTopDown(e, lc, aggregates[i])
+			}
+		}
+		x.TargetPath = append(x.TargetPath, xe)
+	}
+	return x, true
+}
+
+// pathRoot returns the name of the root where `path` starts; `tn` when empty.
+func pathRoot(lg *LicenseGraph, path TargetEdgePath, tn *TargetNode) string {
+	if len(path) == 0 {
+		return tn.name
+	}
+	return path[0].e.target
+}
+
+// pathAggregates returns whether each edge of `path` gets walked as part of a
+// pure aggregate.
+func pathAggregates(lg *LicenseGraph, path TargetEdgePath) []bool {
+	result := make([]bool, 0, len(path))
+	if len(path) == 0 {
+		return result
+	}
+	treatAsAggregate := lg.targets[path[0].e.target].IsContainer()
+	for _, e := range path {
+		result = append(result, treatAsAggregate)
+		treatAsAggregate = treatAsAggregate && lg.targets[e.e.dependency].IsContainer()
+	}
+	return result
+}
+
+// byPathLength orders paths by the number of edges and then by the names of
+// the targets along the paths.
+type byPathLength []TargetEdgePath
+
+func (l byPathLength) Len() int      { return len(l) }
+func (l byPathLength) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byPathLength) Less(i, j int) bool {
+	if len(l[i]) != len(l[j]) {
+		return len(l[i]) < len(l[j])
+	}
+	for k := range l[i] {
+		if l[i][k].e.target != l[j][k].e.target {
+			return l[i][k].e.target < l[j][k].e.target
+		}
+		if l[i][k].e.dependency != l[j][k].e.dependency {
+			return l[i][k].e.dependency < l[j][k].e.dependency
+		}
+	}
+	return false
+}
+
+// samePath returns true when `p1` and `p2` have the same edges.
+func samePath(p1, p2 TargetEdgePath) bool {
+	if len(p1) != len(p2) {
+		return false
+	}
+	for i := range p1 {
+		if p1[i].e != p2[i].e {
+			return false
+		}
+	}
+	return true
+}
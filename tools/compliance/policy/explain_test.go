@@ -0,0 +1,171 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExplainSourceSharing(t *testing.T) {
+	tests := []struct {
+		name                 string
+		roots                []string
+		edges                []annotated
+		target               string
+		expectedExplanations []string
+	}{
+		{
+			name:   "origin",
+			roots:  []string{"apacheBin.meta_lic"},
+			edges:  []annotated{{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"static"}}},
+			target: "gplLib.meta_lic",
+			expectedExplanations: []string{
+				"gplLib.meta_lic:restricted origin[apacheBin.meta_lic -> gplLib.meta_lic [static]] target[apacheBin.meta_lic -> gplLib.meta_lic [static]]",
+			},
+		},
+		{
+			name:   "bottomup",
+			roots:  []string{"apacheBin.meta_lic"},
+			edges:  []annotated{{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"static"}}},
+			target: "apacheBin.meta_lic",
+			expectedExplanations: []string{
+				"gplLib.meta_lic:restricted origin[apacheBin.meta_lic -> gplLib.meta_lic [static] (target is a derivative work of dependency)] target[]",
+			},
+		},
+		{
+			name:   "topdown",
+			roots:  []string{"gplBin.meta_lic"},
+			edges:  []annotated{{"gplBin.meta_lic", "apacheLib.meta_lic", []string{"static"}}},
+			target: "apacheLib.meta_lic",
+			expectedExplanations: []string{
+				"gplBin.meta_lic:restricted origin[] target[gplBin.meta_lic -> apacheLib.meta_lic [static] (target is a derivative work of dependency)]",
+			},
+		},
+		{
+			name:  "sibling",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{
+				{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"static"}},
+				{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"static"}},
+			},
+			target: "mitLib.meta_lic",
+			expectedExplanations: []string{
+				"gplLib.meta_lic:restricted" +
+					" origin[apacheBin.meta_lic -> gplLib.meta_lic [static] (target is a derivative work of dependency)]" +
+					" target[apacheBin.meta_lic -> mitLib.meta_lic [static] (target is a derivative work of dependency)]",
+			},
+		},
+		{
+			name:   "dynamic",
+			roots:  []string{"apacheBin.meta_lic"},
+			edges:  []annotated{{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"dynamic"}}},
+			target: "apacheBin.meta_lic",
+			expectedExplanations: []string{
+				"gplLib.meta_lic:restricted origin[apacheBin.meta_lic -> gplLib.meta_lic [dynamic] (restricted crosses dynamic link (SPDX-license-identifier-GPL-2.0: shared))] target[]",
+			},
+		},
+		{
+			name:  "twopaths",
+			roots: []string{"apacheContainer.meta_lic"},
+			edges: []annotated{
+				{"apacheContainer.meta_lic", "apacheBin.meta_lic", []string{"static"}},
+				{"apacheContainer.meta_lic", "mitBin.meta_lic", []string{"static"}},
+				{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"static"}},
+				{"mitBin.meta_lic", "gplLib.meta_lic", []string{"static"}},
+			},
+			target: "gplLib.meta_lic",
+			expectedExplanations: []string{
+				"gplLib.meta_lic:restricted origin[apacheContainer.meta_lic -> apacheBin.meta_lic [static], apacheBin.meta_lic -> gplLib.meta_lic [static]] target[apacheContainer.meta_lic -> apacheBin.meta_lic [static], apacheBin.meta_lic -> gplLib.meta_lic [static]]",
+				"gplLib.meta_lic:restricted origin[apacheContainer.meta_lic -> mitBin.meta_lic [static], mitBin.meta_lic -> gplLib.meta_lic [static]] target[apacheContainer.meta_lic -> mitBin.meta_lic [static], mitBin.meta_lic -> gplLib.meta_lic [static]]",
+			},
+		},
+		{
+			name:                 "lgpldynamic",
+			roots:                []string{"apacheBin.meta_lic"},
+			edges:                []annotated{{"apacheBin.meta_lic", "lgplLib.meta_lic", []string{"dynamic"}}},
+			target:               "apacheBin.meta_lic",
+			expectedExplanations: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lg, err := toGraph(&bytes.Buffer{}, tt.roots, tt.edges)
+			if err != nil {
+				t.Fatalf("unexpected test data error: got %s, want no error", err)
+			}
+			actual := make([]string, 0)
+			for _, x := range ExplainSourceSharing(lg, DefaultPolicy, lg.TargetNode(tt.target)) {
+				if x.Target.Name() != tt.target {
+					t.Errorf("unexpected target: got %q, want %q", x.Target.Name(), tt.target)
+				}
+				actual = append(actual, x.Condition.asString(":")+
+					" origin["+explainedPath(x.OriginPath)+"] target["+explainedPath(x.TargetPath)+"]")
+			}
+			sort.Strings(actual)
+			checkSameStrings("explanation", actual, tt.expectedExplanations, t)
+		})
+	}
+}
+
+func TestExplainSourceSharingLimit(t *testing.T) {
+	lg, err := toGraph(&bytes.Buffer{}, []string{"apacheContainer.meta_lic"}, []annotated{
+		{"apacheContainer.meta_lic", "apacheBin.meta_lic", []string{"static"}},
+		{"apacheContainer.meta_lic", "mitBin.meta_lic", []string{"static"}},
+		{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"static"}},
+		{"mitBin.meta_lic", "gplLib.meta_lic", []string{"static"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	tests := []struct {
+		limit             int
+		expectedPaths     []string
+		expectedTruncated bool
+	}{
+		{0, []string{"apacheContainer.meta_lic -> apacheBin.meta_lic [static], apacheBin.meta_lic -> gplLib.meta_lic [static]", "apacheContainer.meta_lic -> mitBin.meta_lic [static], mitBin.meta_lic -> gplLib.meta_lic [static]"}, false},
+		{1, []string{"apacheContainer.meta_lic -> apacheBin.meta_lic [static], apacheBin.meta_lic -> gplLib.meta_lic [static]"}, true},
+		{2, []string{"apacheContainer.meta_lic -> apacheBin.meta_lic [static], apacheBin.meta_lic -> gplLib.meta_lic [static]", "apacheContainer.meta_lic -> mitBin.meta_lic [static], mitBin.meta_lic -> gplLib.meta_lic [static]"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("limit%d", tt.limit), func(t *testing.T) {
+			explanations, truncated := ExplainSourceSharingLimit(lg, DefaultPolicy, lg.TargetNode("gplLib.meta_lic"), tt.limit)
+			if truncated != tt.expectedTruncated {
+				t.Errorf("unexpected truncation: got %t, want %t", truncated, tt.expectedTruncated)
+			}
+			actual := make([]string, 0, len(explanations))
+			for _, x := range explanations {
+				actual = append(actual, explainedPath(x.TargetPath))
+			}
+			checkSameStrings("target path", actual, tt.expectedPaths, t)
+		})
+	}
+}
+
+// explainedPath returns a string representation of the explained edges in `path`.
+func explainedPath(path []ExplainedEdge) string {
+	edges := make([]string, 0, len(path))
+	for _, xe := range path {
+		s := xe.Edge.Target().Name() + " -> " + xe.Edge.Dependency().Name() + " [" + strings.Join(xe.Edge.Annotations().AsList(), " ") + "]"
+		if xe.Crossed {
+			s += " (" + xe.Rule + ")"
+		}
+		edges = append(edges, s)
+	}
+	return strings.Join(edges, ", ")
+}
@@ -0,0 +1,307 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// LicenseKindMap maps license kinds to the license conditions they imply and
+// to the rules for extending restricted conditions across dynamic links.
+//
+// A LicenseKindMap gets read from a JSON mapping file like:
+//
+//	{
+//	  "condition_classes": {
+//	    "shared": ["reciprocal", "restricted"]
+//	  },
+//	  "license_kinds": [
+//	    {
+//	      "kind": "SPDX-license-identifier-GPL-2.0",
+//	      "conditions": ["restricted"],
+//	      "dynamic_link": "shared"
+//	    },
+//	    {
+//	      "pattern": "^SPDX-license-identifier-LGPL.*",
+//	      "conditions": ["restricted"],
+//	      "dynamic_link": "exception"
+//	    }
+//	  ]
+//	}
+//
+// Each entry names either an exact `kind` or a regular expression `pattern`.
+// Exact kinds take precedence over patterns, and patterns apply in file order.
+// Omitting "conditions" keeps the conditions declared in the license metadata.
+//
+// The "dynamic_link" rule is one of "shared", "generic", "classpath_exception"
+// or "exception". See DynamicLinkRule. Omitting it means restricted does not
+// cross dynamic links.
+//
+// The optional "condition_classes" map the names of condition classes (e.g.
+// "restricted" or "by_exception_only") to the condition names belonging to
+// each class. See ConditionClass.
+//
+// A *LicenseKindMap is also a Policy that looks up condition classes and
+// dynamic link rules in the map. Classes and license kinds missing from the
+// map follow the default policy in policy/license_kinds.json.
+type LicenseKindMap struct {
+	// classes maps condition classes to their condition names.
+	classes map[ConditionClass]ConditionNames
+
+	// kinds maps exact license kinds to their entries.
+	kinds map[string]*licenseKindEntry
+
+	// patterns lists the pattern entries in file order.
+	patterns []*licenseKindEntry
+}
+
+// licenseKindEntry describes a single entry in the mapping file.
+type licenseKindEntry struct {
+	Kind        string   `json:"kind"`
+	Pattern     string   `json:"pattern"`
+	Conditions  []string `json:"conditions"`
+	DynamicLink string   `json:"dynamic_link"`
+
+	// re is the compiled `Pattern`.
+	re *regexp.Regexp
+
+	// rule is the parsed `DynamicLink`.
+	rule DynamicLinkRule
+}
+
+// dynamicLinkRules maps the mapping file names to the dynamic link rules.
+var dynamicLinkRules = map[string]DynamicLinkRule{
+	"":                    DynamicLinkUnspecified,
+	"shared":              DynamicLinkShared,
+	"generic":             DynamicLinkGeneric,
+	"classpath_exception": DynamicLinkClasspathException,
+	"exception":           DynamicLinkException,
+}
+
+// conditionClasses maps the mapping file names to the condition classes.
+var conditionClasses = map[string]ConditionClass{
+	"unencumbered":      UnencumberedClass,
+	"permissive":        PermissiveClass,
+	"notice":            NoticeClass,
+	"reciprocal":        ReciprocalClass,
+	"restricted":        RestrictedClass,
+	"proprietary":       ProprietaryClass,
+	"by_exception_only": ByExceptionOnlyClass,
+	"private":           PrivateClass,
+	"shared":            SharedClass,
+}
+
+// ReadLicenseKindMap reads and parses the mapping file `path` from `rootFS`.
+func ReadLicenseKindMap(rootFS fs.FS, path string) (*LicenseKindMap, error) {
+	data, err := fs.ReadFile(rootFS, path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading license kind map %q: %w", path, err)
+	}
+	km, err := ParseLicenseKindMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing license kind map %q: %w", path, err)
+	}
+	return km, nil
+}
+
+// LoadLicenseKindMap reads and parses the mapping file `file` from the
+// operating system file system. An empty `file` returns a nil map.
+func LoadLicenseKindMap(file string) (*LicenseKindMap, error) {
+	if len(file) == 0 {
+		return nil, nil
+	}
+	return ReadLicenseKindMap(os.DirFS(filepath.Dir(file)), filepath.Base(file))
+}
+
+// ParseLicenseKindMap parses the JSON mapping in `data`.
+func ParseLicenseKindMap(data []byte) (*LicenseKindMap, error) {
+	var file struct {
+		ConditionClasses map[string][]string `json:"condition_classes"`
+		LicenseKinds     []*licenseKindEntry `json:"license_kinds"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	km := &LicenseKindMap{
+		classes: make(map[ConditionClass]ConditionNames),
+		kinds:   make(map[string]*licenseKindEntry),
+	}
+	for name, names := range file.ConditionClasses {
+		class, ok := conditionClasses[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown condition class %q", name)
+		}
+		km.classes[class] = ConditionNames(names)
+	}
+	for i, entry := range file.LicenseKinds {
+		if (len(entry.Kind) == 0) == (len(entry.Pattern) == 0) {
+			return nil, fmt.Errorf("license kind entry %d: want exactly one of kind or pattern", i+1)
+		}
+		rule, ok := dynamicLinkRules[entry.DynamicLink]
+		if !ok {
+			return nil, fmt.Errorf("license kind entry %d: unknown dynamic_link %q", i+1, entry.DynamicLink)
+		}
+		entry.rule = rule
+		if len(entry.Kind) > 0 {
+			if _, ok := km.kinds[entry.Kind]; ok {
+				return nil, fmt.Errorf("license kind entry %d: duplicate kind %q", i+1, entry.Kind)
+			}
+			km.kinds[entry.Kind] = entry
+			continue
+		}
+		re, err := regexp.Compile(entry.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("license kind entry %d: invalid pattern %q: %w", i+1, entry.Pattern, err)
+		}
+		entry.re = re
+		km.patterns = append(km.patterns, entry)
+	}
+	return km, nil
+}
+
+// mustParseLicenseKindMap parses the JSON mapping in `data` or panics.
+func mustParseLicenseKindMap(data []byte) *LicenseKindMap {
+	km, err := ParseLicenseKindMap(data)
+	if err != nil {
+		panic(fmt.Errorf("error parsing license kind map: %w", err))
+	}
+	return km
+}
+
+// lookup returns the entry for license kind `kind` or nil if none.
+func (km *LicenseKindMap) lookup(kind string) *licenseKindEntry {
+	if entry, ok := km.kinds[kind]; ok {
+		return entry
+	}
+	for _, entry := range km.patterns {
+		if entry.re.MatchString(kind) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// HasKind returns true when the map has an entry for license kind `kind`.
+func (km *LicenseKindMap) HasKind(kind string) bool {
+	return km.lookup(kind) != nil
+}
+
+// Conditions returns the condition names implied by license kind `kind`.
+func (km *LicenseKindMap) Conditions(kind string) []string {
+	entry := km.lookup(kind)
+	if entry == nil {
+		return []string{}
+	}
+	return append([]string{}, entry.Conditions...)
+}
+
+// DynamicLink returns the dynamic link rule for license kind `kind`, or the
+// default rule when the map has no entry for `kind`.
+func (km *LicenseKindMap) DynamicLink(kind string) DynamicLinkRule {
+	entry := km.lookup(kind)
+	if entry != nil {
+		return entry.rule
+	}
+	if km == defaultLicenseKinds {
+		return DynamicLinkUnspecified
+	}
+	return defaultLicenseKinds.DynamicLink(kind)
+}
+
+// Policy returns `km` as a Policy, or DefaultPolicy when `km` is nil.
+func (km *LicenseKindMap) Policy() Policy {
+	if km == nil {
+		return DefaultPolicy
+	}
+	return km
+}
+
+// licenseConditions returns the condition names for license kinds `kinds`.
+//
+// Kinds absent from the map, or mapped without conditions, contribute the `declared` conditions from the
+// license metadata.
+func (km *LicenseKindMap) licenseConditions(kinds, declared []string) []string {
+	result := make([]string, 0, len(declared))
+	seen := make(map[string]bool)
+	add := func(names []string) {
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	useDeclared := len(kinds) == 0
+	for _, kind := range kinds {
+		if entry := km.lookup(kind); entry != nil && entry.Conditions != nil {
+			add(entry.Conditions)
+		} else {
+			useDeclared = true
+		}
+	}
+	if useDeclared {
+		add(declared)
+	}
+	return result
+}
+
+// Implies returns the condition names belonging to `class`, or the default
+// names when the map does not list `class`.
+func (km *LicenseKindMap) Implies(class ConditionClass) ConditionNames {
+	if names, ok := km.classes[class]; ok {
+		return names
+	}
+	if km == defaultLicenseKinds {
+		panic(fmt.Errorf("unknown condition class %d", class))
+	}
+	return defaultLicenseKinds.Implies(class)
+}
+
+// BottomUp returns whether `lc` propagates up `e` and whether the target must
+// act on it too.
+func (km *LicenseKindMap) BottomUp(e TargetEdge, actsOn *TargetNode, lc LicenseCondition, treatAsAggregate bool) (bool, bool) {
+	propagates, actsOnTarget, _ := bottomUpByRule(e, lc, km.Implies, km.DynamicLink)
+	return propagates, actsOnTarget
+}
+
+// TopDown returns whether `lc` propagates down `e`.
+func (km *LicenseKindMap) TopDown(e TargetEdge, lc LicenseCondition, treatAsAggregate bool) bool {
+	propagates, _ := topDownByRule(e, lc, treatAsAggregate, km.Implies, km.DynamicLink)
+	return propagates
+}
+
+// IsDerivation returns true when the target of `e` is a derivative work of the
+// dependency.
+func (km *LicenseKindMap) IsDerivation(e TargetEdge) bool {
+	return edgeIsDerivation(e)
+}
+
+// ExplainBottomUp describes the rule deciding whether `lc` propagates up `e`.
+func (km *LicenseKindMap) ExplainBottomUp(e TargetEdge, actsOn *TargetNode, lc LicenseCondition, treatAsAggregate bool) string {
+	_, _, rule := bottomUpByRule(e, lc, km.Implies, km.DynamicLink)
+	return rule.String()
+}
+
+// ExplainTopDown describes the rule deciding whether `lc` propagates down `e`.
+func (km *LicenseKindMap) ExplainTopDown(e TargetEdge, lc LicenseCondition, treatAsAggregate bool) string {
+	_, rule := topDownByRule(e, lc, treatAsAggregate, km.Implies, km.DynamicLink)
+	return rule.String()
+}
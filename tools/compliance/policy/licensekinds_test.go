@@ -0,0 +1,348 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// defaultKinds maps license kinds the same way as the default policy.
+const defaultKinds = `{
+  "license_kinds": [
+    {"pattern": "-with-classpath-exception$", "conditions": ["restricted"], "dynamic_link": "classpath_exception"},
+    {"pattern": "^SPDX-license-identifier-LGPL.*", "conditions": ["restricted"], "dynamic_link": "exception"},
+    {"pattern": "^SPDX-license-identifier-GPL-\\p{N}.*", "conditions": ["restricted"], "dynamic_link": "shared"},
+    {"kind": "SPDX-license-identifier-GPL", "conditions": ["restricted"], "dynamic_link": "generic"},
+    {"kind": "legacy_restricted", "conditions": ["restricted"], "dynamic_link": "shared"},
+    {"pattern": "^SPDX-license-identifier-CC-BY.*-SA.*", "conditions": ["restricted"], "dynamic_link": "shared"},
+    {"kind": "SPDX-license-identifier-Apache-2.0", "conditions": ["notice"]},
+    {"kind": "SPDX-license-identifier-MIT", "conditions": ["notice"]},
+    {"kind": "SPDX-license-identifier-MPL-2.0", "conditions": ["reciprocal"]}
+  ]
+}`
+
+func TestParseLicenseKindMap(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expectedError string
+	}{
+		{
+			name: "valid",
+			data: defaultKinds,
+		},
+		{
+			name:          "kindandpattern",
+			data:          `{"license_kinds": [{"kind": "a", "pattern": "b"}]}`,
+			expectedError: "want exactly one of kind or pattern",
+		},
+		{
+			name:          "neither",
+			data:          `{"license_kinds": [{"conditions": ["notice"]}]}`,
+			expectedError: "want exactly one of kind or pattern",
+		},
+		{
+			name:          "unknownrule",
+			data:          `{"license_kinds": [{"kind": "a", "dynamic_link": "sometimes"}]}`,
+			expectedError: `unknown dynamic_link "sometimes"`,
+		},
+		{
+			name:          "badpattern",
+			data:          `{"license_kinds": [{"pattern": "("}]}`,
+			expectedError: `invalid pattern "("`,
+		},
+		{
+			name:          "duplicate",
+			data:          `{"license_kinds": [{"kind": "a"}, {"kind": "a"}]}`,
+			expectedError: `duplicate kind "a"`,
+		},
+		{
+			name:          "unknownclass",
+			data:          `{"condition_classes": {"infectious": ["restricted"]}}`,
+			expectedError: `unknown condition class "infectious"`,
+		},
+		{
+			name:          "notjson",
+			data:          `license_kinds: {}`,
+			expectedError: "invalid character",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseLicenseKindMap([]byte(tt.data))
+			if err != nil {
+				if len(tt.expectedError) == 0 {
+					t.Errorf("unexpected error: got %s, want no error", err)
+				} else if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("unexpected error: got %s, want %q", err, tt.expectedError)
+				}
+				return
+			}
+			if len(tt.expectedError) > 0 {
+				t.Errorf("unexpected success: got no error, want %q", tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestLicenseKindMap_lookup(t *testing.T) {
+	km, err := ParseLicenseKindMap([]byte(`{
+  "license_kinds": [
+    {"pattern": "^SPDX-license-identifier-GPL.*", "conditions": ["restricted"], "dynamic_link": "shared"},
+    {"kind": "SPDX-license-identifier-GPL-2.0-with-classpath-exception", "conditions": ["restricted"], "dynamic_link": "classpath_exception"},
+    {"pattern": ".*", "conditions": ["notice"]}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	tests := []struct {
+		kind               string
+		expectedConditions string
+		expectedRule       DynamicLinkRule
+	}{
+		{"SPDX-license-identifier-GPL-2.0", "restricted", DynamicLinkShared},
+		{"SPDX-license-identifier-GPL-2.0-with-classpath-exception", "restricted", DynamicLinkClasspathException},
+		{"SPDX-license-identifier-MIT", "notice", DynamicLinkUnspecified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			if !km.HasKind(tt.kind) {
+				t.Errorf("unexpected missing kind %q", tt.kind)
+			}
+			actual := strings.Join(km.Conditions(tt.kind), " ")
+			if actual != tt.expectedConditions {
+				t.Errorf("unexpected conditions: got %q, want %q", actual, tt.expectedConditions)
+			}
+			if rule := km.DynamicLink(tt.kind); rule != tt.expectedRule {
+				t.Errorf("unexpected dynamic link rule: got %d, want %d", rule, tt.expectedRule)
+			}
+		})
+	}
+}
+
+func TestLicenseKindMap_policy(t *testing.T) {
+	tests := []struct {
+		name  string
+		roots []string
+		edges []annotated
+	}{
+		{
+			name:  "gpldynamic",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"dynamic"}}},
+		},
+		{
+			name:  "lgpldynamic",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{{"apacheBin.meta_lic", "lgplLib.meta_lic", []string{"dynamic"}}},
+		},
+		{
+			name:  "lgplstatic",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{{"apacheBin.meta_lic", "lgplLib.meta_lic", []string{"static"}}},
+		},
+		{
+			name:  "classpathdependent",
+			roots: []string{"dependentModule.meta_lic"},
+			edges: []annotated{{"dependentModule.meta_lic", "gplWithClasspathException.meta_lic", []string{"dynamic"}}},
+		},
+		{
+			name:  "classpathindependent",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{{"apacheBin.meta_lic", "gplWithClasspathException.meta_lic", []string{"dynamic"}}},
+		},
+		{
+			name:  "gplontop",
+			roots: []string{"gplBin.meta_lic"},
+			edges: []annotated{
+				{"gplBin.meta_lic", "apacheLib.meta_lic", []string{"dynamic"}},
+				{"gplBin.meta_lic", "mitLib.meta_lic", []string{"static"}},
+			},
+		},
+	}
+	km, err := ParseLicenseKindMap([]byte(defaultKinds))
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lg, err := toGraph(&bytes.Buffer{}, tt.roots, tt.edges)
+			if err != nil {
+				t.Fatalf("unexpected test data error: got %s, want no error", err)
+			}
+			checkSame(ResolveTopDownConditions(lg, km), ResolveTopDownConditions(lg, DefaultPolicy), t)
+		})
+	}
+}
+
+func TestLicenseKindMap_newKind(t *testing.T) {
+	// treat LGPL like GPL by changing data only
+	km, err := ParseLicenseKindMap([]byte(`{
+  "license_kinds": [
+    {"kind": "SPDX-license-identifier-LGPL-2.0", "conditions": ["restricted"], "dynamic_link": "shared"}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	lg, err := toGraph(&bytes.Buffer{}, []string{"apacheBin.meta_lic"}, []annotated{
+		{"apacheBin.meta_lic", "lgplLib.meta_lic", []string{"dynamic"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	expectedRs := toResolutionSet(lg, []res{
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "apacheBin.meta_lic", "notice"},
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "lgplLib.meta_lic", "restricted"},
+		{"apacheBin.meta_lic", "lgplLib.meta_lic", "lgplLib.meta_lic", "restricted"},
+		{"lgplLib.meta_lic", "lgplLib.meta_lic", "lgplLib.meta_lic", "restricted"},
+	})
+	checkSame(ResolveBottomUpConditions(lg, km), expectedRs, t)
+}
+
+func TestLicenseKindMap_partial(t *testing.T) {
+	// kinds missing from the map keep the default dynamic link rules
+	km, err := ParseLicenseKindMap([]byte(`{
+  "license_kinds": [
+    {"kind": "SPDX-license-identifier-MIT", "conditions": ["notice"]}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	if rule := km.DynamicLink("SPDX-license-identifier-GPL-2.0"); rule != DynamicLinkShared {
+		t.Errorf("unexpected dynamic link rule: got %s, want %s", rule, DynamicLinkShared)
+	}
+	lg, err := toGraph(&bytes.Buffer{}, []string{"apacheBin.meta_lic"}, []annotated{
+		{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"dynamic"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	checkSame(ResolveTopDownConditions(lg, km), ResolveTopDownConditions(lg, DefaultPolicy), t)
+
+	var none *LicenseKindMap
+	if none.Policy() != DefaultPolicy {
+		t.Errorf("unexpected policy for nil map: got %v, want DefaultPolicy", none.Policy())
+	}
+}
+
+func TestLicenseKindMap_implies(t *testing.T) {
+	// add a condition name to a class by changing data only
+	km, err := ParseLicenseKindMap([]byte(`{
+  "condition_classes": {
+    "restricted": ["restricted", "restricted_strict"]
+  }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	tests := []struct {
+		name          string
+		class         ConditionClass
+		expectedNames []string
+	}{
+		{"mapped", RestrictedClass, []string{"restricted", "restricted_strict"}},
+		{"default", SharedClass, []string{"reciprocal", "restricted"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSameStrings("condition", km.Implies(tt.class), tt.expectedNames, t)
+		})
+	}
+}
+
+func TestLicenseKindMap_impliesPropagation(t *testing.T) {
+	// a condition name added to the restricted class propagates like restricted
+	km, err := ParseLicenseKindMap([]byte(`{
+  "condition_classes": {
+    "restricted": ["restricted", "restricted_strict"],
+    "shared": ["reciprocal", "restricted", "restricted_strict"]
+  },
+  "license_kinds": [
+    {"kind": "SPDX-license-identifier-GPL-2.0", "conditions": ["restricted_strict"], "dynamic_link": "shared"}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	fs := &testFS{
+		"apacheBin.meta_lic": []byte(AOSP + "deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n"),
+		"gplLib.meta_lic":    []byte(GPL),
+	}
+	lg, err := ReadLicenseGraphWithKindMap(fs, &bytes.Buffer{}, []string{"apacheBin.meta_lic"}, km)
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	expectedRs := toResolutionSet(lg, []res{
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "apacheBin.meta_lic", "notice"},
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "gplLib.meta_lic", "restricted_strict"},
+		{"apacheBin.meta_lic", "gplLib.meta_lic", "gplLib.meta_lic", "restricted_strict"},
+		{"gplLib.meta_lic", "gplLib.meta_lic", "gplLib.meta_lic", "restricted_strict"},
+	})
+	checkSame(ResolveTopDownConditions(lg, km), expectedRs, t)
+}
+
+func TestReadLicenseGraphWithKindMap(t *testing.T) {
+	km, err := ParseLicenseKindMap([]byte(`{
+  "license_kinds": [
+    {"kind": "SPDX-license-identifier-Apache-2.0", "conditions": ["notice", "permissive"]},
+    {"kind": "SPDX-license-identifier-MIT", "conditions": ["notice"]}
+  ]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	tests := []struct {
+		name               string
+		meta               string
+		expectedConditions []string
+	}{
+		{
+			name:               "mapped",
+			meta:               AOSP,
+			expectedConditions: []string{"notice", "permissive"},
+		},
+		{
+			name:               "unmapped",
+			meta:               GPL,
+			expectedConditions: []string{"restricted"},
+		},
+		{
+			name:               "mixed",
+			meta:               MIT + "license_kinds: \"legacy_unknown\"\nlicense_conditions: \"by_exception_only\"\n",
+			expectedConditions: []string{"notice", "by_exception_only"},
+		},
+		{
+			name:               "overridden",
+			meta:               MIT + "license_kinds: \"SPDX-license-identifier-Apache-2.0\"\nlicense_conditions: \"restricted\"\n",
+			expectedConditions: []string{"notice", "permissive"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := &testFS{"target.meta_lic": []byte(tt.meta)}
+			lg, err := ReadLicenseGraphWithKindMap(fs, &bytes.Buffer{}, []string{"target.meta_lic"}, km)
+			if err != nil {
+				t.Fatalf("unexpected error: got %s, want no error", err)
+			}
+			actual := lg.TargetNode("target.meta_lic").proto.LicenseConditions
+			checkSameStrings("condition", actual, tt.expectedConditions, t)
+		})
+	}
+}
@@ -14,47 +14,113 @@
 
 package compliance
 
-import (
-	"regexp"
-	"strings"
-)
+import _ "embed"
 
 var (
 	// ImpliesUnencumbered lists the condition names representing an author attempt to disclaim copyright.
-	ImpliesUnencumbered = ConditionNames{"unencumbered"}
+	ImpliesUnencumbered = DefaultPolicy.Implies(UnencumberedClass)
 
 	// ImpliesPermissive lists the condition names representing copyrighted but "licensed without policy requirements".
-	ImpliesPermissive = ConditionNames{"permissive"}
+	ImpliesPermissive = DefaultPolicy.Implies(PermissiveClass)
 
 	// ImpliesNotice lists the condition names implying a notice or attribution policy.
-	ImpliesNotice = ConditionNames{"unencumbered", "permissive", "notice", "reciprocal", "restricted", "proprietary", "by_exception_only"}
+	ImpliesNotice = DefaultPolicy.Implies(NoticeClass)
 
 	// ImpliesReciprocal lists the condition names implying a local source-sharing policy.
-	ImpliesReciprocal = ConditionNames{"reciprocal"}
+	ImpliesReciprocal = DefaultPolicy.Implies(ReciprocalClass)
 
 	// Restricted lists the condition names implying an infectious source-sharing policy.
-	ImpliesRestricted = ConditionNames{"restricted"}
+	ImpliesRestricted = DefaultPolicy.Implies(RestrictedClass)
 
 	// ImpliesProprietary lists the condition names implying a confidentiality policy.
-	ImpliesProprietary = ConditionNames{"proprietary"}
+	ImpliesProprietary = DefaultPolicy.Implies(ProprietaryClass)
 
 	// ImpliesByExceptionOnly lists the condition names implying a policy for "license review and approval before use".
-	ImpliesByExceptionOnly = ConditionNames{"proprietary", "by_exception_only"}
+	ImpliesByExceptionOnly = DefaultPolicy.Implies(ByExceptionOnlyClass)
 
 	// ImpliesPrivate lists the condition names implying a source-code privacy policy.
-	ImpliesPrivate = ConditionNames{"proprietary"}
+	ImpliesPrivate = DefaultPolicy.Implies(PrivateClass)
 
 	// ImpliesShared lists the condition names implying a source-code sharing policy.
-	ImpliesShared = ConditionNames{"reciprocal", "restricted"}
+	ImpliesShared = DefaultPolicy.Implies(SharedClass)
 )
 
-var (
-	anyLgpl      = regexp.MustCompile(`^SPDX-license-identifier-LGPL.*`)
-	versionedGpl = regexp.MustCompile(`^SPDX-license-identifier-GPL-\p{N}.*`)
-	genericGpl   = regexp.MustCompile(`^SPDX-license-identifier-GPL$`)
-	ccBySa       = regexp.MustCompile(`^SPDX-license-identifier-CC-BY.*-SA.*`)
+// ConditionClass identifies a category of license condition names sharing a
+// policy requirement. e.g. notice or source-sharing
+type ConditionClass int
+
+const (
+	// UnencumberedClass represents an author attempt to disclaim copyright.
+	UnencumberedClass ConditionClass = iota
+
+	// PermissiveClass represents copyrighted but "licensed without policy requirements".
+	PermissiveClass
+
+	// NoticeClass implies a notice or attribution policy.
+	NoticeClass
+
+	// ReciprocalClass implies a local source-sharing policy.
+	ReciprocalClass
+
+	// RestrictedClass implies an infectious source-sharing policy.
+	RestrictedClass
+
+	// ProprietaryClass implies a confidentiality policy.
+	ProprietaryClass
+
+	// ByExceptionOnlyClass implies a policy for "license review and approval before use".
+	ByExceptionOnlyClass
+
+	// PrivateClass implies a source-code privacy policy.
+	PrivateClass
+
+	// SharedClass implies a source-code sharing policy.
+	SharedClass
 )
 
+// Policy establishes the rules for propagating license conditions across the
+// edges of a license graph.
+//
+// Resolutions and shipped nodes get cached in the license graph keyed by
+// policy so implementations must be comparable; e.g. pointers or structs
+// without slice, map or func fields. Using a policy that is not comparable
+// panics.
+type Policy interface {
+	// Implies returns the condition names belonging to `class`.
+	Implies(class ConditionClass) ConditionNames
+
+	// BottomUp returns whether condition `lc` acted on by `actsOn` in the
+	// dependency of `e` propagates up to the target of `e`, and whether the
+	// target must act on `lc` too.
+	//
+	// If a pure aggregation is built into a derivative work that is not a
+	// pure aggregation, per policy it ceases to be a pure aggregation in the
+	// context of that derivative work. The `treatAsAggregate` parameter will
+	// be false for non-aggregates and for aggregates in non-aggregate
+	// contexts.
+	BottomUp(e TargetEdge, actsOn *TargetNode, lc LicenseCondition, treatAsAggregate bool) (propagates bool, actsOnTarget bool)
+
+	// TopDown returns whether condition `lc` applicable to the target of `e`
+	// propagates down to the dependency of `e`.
+	TopDown(e TargetEdge, lc LicenseCondition, treatAsAggregate bool) bool
+
+	// IsDerivation returns true when the target of `e` is a derivative work
+	// of the dependency, i.e. when the target distributes the dependency.
+	IsDerivation(e TargetEdge) bool
+}
+
+// DefaultPolicy implements the standard Android policy below.
+//
+// The condition classes and dynamic link rules of the default policy come from
+// the license kind map in policy/license_kinds.json.
+var DefaultPolicy Policy = defaultLicenseKinds
+
+//go:embed policy/license_kinds.json
+var defaultLicenseKindsData []byte
+
+// defaultLicenseKinds is the license kind map of the default policy.
+var defaultLicenseKinds = mustParseLicenseKindMap(defaultLicenseKindsData)
+
 // Resolution happens in two passes:
 //
 // 1. A bottom-up traversal propagates license conditions up to targets from
@@ -63,9 +129,10 @@ var (
 // 2. For each condition of interest, a top-down traversal adjusts the attached
 // conditions pushing restricted down from targets into linked dependencies.
 //
-// The behavior of the 2 passes gets controlled by the 2 functions below.
+// The behavior of the 2 passes gets controlled by the BottomUp and TopDown
+// methods of the policy.
 //
-// The first function controls what happens during the bottom-up traversal. In
+// The first method controls what happens during the bottom-up traversal. In
 // general conditions flow up through static links but not other dependencies;
 // except, restricted sometimes flows up through dynamic links.
 //
@@ -74,145 +141,224 @@ var (
 // it requires acting on (i.e. sharing source of) both the originating module
 // and the target using the module.
 //
-// The latter function controls what happens during the top-down traversal. In
+// The latter method controls what happens during the top-down traversal. In
 // general, only restricted conditions flow down at all, and only through
 // static links.
 //
 // Not all restricted licenses are create equal. Some have special rules or
 // exceptions. e.g. LGPL or "with classpath excption".
 
-// depActionsApplicableToTarget returns the actions which propagate up an
-// edge from dependency to target.
-//
-// This function sets the policy for the bottom-up traversal and how conditions
-// flow up the graph from dependencies to targets.
-//
-// If a pure aggregation is built into a derivative work that is not a pure
-// aggregation, per policy it ceases to be a pure aggregation in the context of
-// that derivative work. The `treatAsAggregate` parameter will be false for
-// non-aggregates and for aggregates in non-aggregate contexts.
-func depActionsApplicableToTarget(e TargetEdge, depActions actionSet, treatAsAggregate bool) actionSet {
-	result := make(actionSet)
-	if edgeIsDerivation(e) {
-		result.addSet(depActions)
-		for _, cs := range depActions.byName(ImpliesRestricted) {
-			result.add(e.Target(), cs)
+// PolicyExplainer is an optional interface for policies that can describe the
+// rule deciding whether a condition crosses an edge.
+type PolicyExplainer interface {
+	// ExplainBottomUp describes the rule deciding Policy.BottomUp.
+	ExplainBottomUp(e TargetEdge, actsOn *TargetNode, lc LicenseCondition, treatAsAggregate bool) string
+
+	// ExplainTopDown describes the rule deciding Policy.TopDown.
+	ExplainTopDown(e TargetEdge, lc LicenseCondition, treatAsAggregate bool) string
+}
+
+// DynamicLinkRule describes whether the restricted conditions of a license
+// kind extend across dynamic links.
+type DynamicLinkRule int
+
+const (
+	// DynamicLinkUnspecified means restricted does not cross dynamic links.
+	DynamicLinkUnspecified DynamicLinkRule = iota
+
+	// DynamicLinkShared means restricted always crosses dynamic links. e.g. GPL-2.0
+	DynamicLinkShared
+
+	// DynamicLinkGeneric means restricted crosses dynamic links unless
+	// another license kind of the origin grants an exception. e.g. GPL
+	DynamicLinkGeneric
+
+	// DynamicLinkClasspathException means restricted crosses dynamic links
+	// only between parts of the same module. e.g. GPL-2.0-with-classpath-exception
+	DynamicLinkClasspathException
+
+	// DynamicLinkException means restricted never crosses dynamic links. e.g. LGPL-2.1
+	DynamicLinkException
+)
+
+// String returns the name of the rule as used in license kind map files.
+func (r DynamicLinkRule) String() string {
+	for name, rule := range dynamicLinkRules {
+		if rule == r && len(name) > 0 {
+			return name
 		}
-		return result
-	}
-	if !edgeIsDynamicLink(e) {
-		return result
 	}
+	return "unspecified"
+}
 
-	restricted := depActions.byName(ImpliesRestricted)
-	for actsOn, cs := range restricted {
-		for _, lc := range cs.AsList() {
-			hasGpl := false
-			hasLgpl := false
-			hasClasspath := false
-			hasGeneric := false
-			hasOther := false
-			for _, kind := range lc.origin.LicenseKinds() {
-				if strings.HasSuffix(kind, "-with-classpath-exception") {
-					hasClasspath = true
-				} else if anyLgpl.MatchString(kind) {
-					hasLgpl = true
-				} else if versionedGpl.MatchString(kind) {
-					hasGpl = true
-				} else if genericGpl.MatchString(kind) {
-					hasGeneric = true
-				} else if kind == "legacy_restricted" || ccBySa.MatchString(kind) {
-					hasOther = true
-				}
-			}
-			if hasOther || hasGpl {
-				result.addCondition(actsOn, lc)
-				result.addCondition(e.Target(), lc)
-				continue
-			}
-			if hasClasspath && !edgeNodesAreIndependentModules(e) {
-				result.addCondition(actsOn, lc)
-				result.addCondition(e.Target(), lc)
-				continue
-			}
-			if hasLgpl || hasClasspath {
-				continue
-			}
-			if !hasGeneric {
-				continue
-			}
-			result.addCondition(actsOn, lc)
-			result.addCondition(e.Target(), lc)
-		}
+// edgeRule identifies the rule deciding whether a condition crosses an edge.
+type edgeRule struct {
+	// reason summarizes the rule.
+	reason string
+
+	// kind identifies the license kind deciding a dynamic link, if any.
+	kind string
+
+	// dynamicLink is the dynamic link rule for `kind`.
+	dynamicLink DynamicLinkRule
+}
+
+// String returns a human-readable description of the rule.
+func (r edgeRule) String() string {
+	if len(r.kind) == 0 {
+		return r.reason
 	}
-	return result
+	return r.reason + " (" + r.kind + ": " + r.dynamicLink.String() + ")"
 }
 
-// targetConditionsApplicableToDep returns the conditions which propagate down
-// an edge from target to dependency.
-//
-// This function sets the policy for the top-down traversal and how conditions
-// flow down the graph from targets to dependencies.
-//
-// If a pure aggregation is built into a derivative work that is not a pure
-// aggregation, per policy it ceases to be a pure aggregation in the context of
-// that derivative work. The `treatAsAggregate` parameter will be false for
-// non-aggregates and for aggregates in non-aggregate contexts.
-func targetConditionsApplicableToDep(e TargetEdge, targetConditions *LicenseConditionSet, treatAsAggregate bool) *LicenseConditionSet {
-	result := targetConditions.Copy()
+const (
+	reasonDerivation       = "target is a derivative work of dependency"
+	reasonNotLinked        = "target is neither derived from nor linked to dependency"
+	reasonOnlyRestricted   = "only restricted conditions cross dynamic links"
+	reasonCrossesDynamic   = "restricted crosses dynamic link"
+	reasonStopsDynamic     = "restricted does not cross dynamic link"
+	reasonTargetOnly       = "condition applies to the target and not to its dependencies"
+	reasonUnknown          = "policy passes unknown conditions to dependencies"
+	reasonAggregateOwn     = "restricted pure aggregate applies restricted to its immediate dependencies"
+	reasonAggregateInherit = "pure aggregate does not pass inherited restricted conditions to dependencies"
+)
 
-	// reverse direction -- none of these apply to things depended-on, only to targets depending-on.
-	result.RemoveAllByName(ConditionNames{"unencumbered", "permissive", "notice", "reciprocal", "proprietary", "by_exception_only"})
+// targetOnlyClasses lists the classes of conditions that apply to the target
+// and not to its dependencies unless also restricted.
+var targetOnlyClasses = []ConditionClass{
+	UnencumberedClass,
+	PermissiveClass,
+	NoticeClass,
+	ReciprocalClass,
+	ProprietaryClass,
+	ByExceptionOnlyClass,
+}
 
+// bottomUpByRule implements the bottom-up half of the default policy using
+// `implies` to look up the condition names of each class and `ruleFor` to
+// look up the dynamic link rule for each license kind.
+func bottomUpByRule(e TargetEdge, lc LicenseCondition, implies func(class ConditionClass) ConditionNames, ruleFor func(kind string) DynamicLinkRule) (bool, bool, edgeRule) {
+	isRestricted := implies(RestrictedClass).Contains(lc.name)
+	if edgeIsDerivation(e) {
+		return true, isRestricted, edgeRule{reason: reasonDerivation}
+	}
+	if !edgeIsDynamicLink(e) {
+		return false, false, edgeRule{reason: reasonNotLinked}
+	}
+	if !isRestricted {
+		return false, false, edgeRule{reason: reasonOnlyRestricted}
+	}
+	if crosses, kind, dl := restrictedCrossesDynamicLink(e, lc, ruleFor); crosses {
+		return true, true, edgeRule{reasonCrossesDynamic, kind, dl}
+	} else {
+		return false, false, edgeRule{reasonStopsDynamic, kind, dl}
+	}
+}
+
+// topDownByRule implements the top-down half of the default policy using
+// `implies` to look up the condition names of each class and `ruleFor` to
+// look up the dynamic link rule for each license kind.
+func topDownByRule(e TargetEdge, lc LicenseCondition, treatAsAggregate bool, implies func(class ConditionClass) ConditionNames, ruleFor func(kind string) DynamicLinkRule) (bool, edgeRule) {
+	if !implies(RestrictedClass).Contains(lc.name) {
+		// reverse direction -- none of these apply to things depended-on, only to targets depending-on.
+		for _, class := range targetOnlyClasses {
+			if implies(class).Contains(lc.name) {
+				return false, edgeRule{reason: reasonTargetOnly}
+			}
+		}
+		return true, edgeRule{reason: reasonUnknown}
+	}
 	if !edgeIsDerivation(e) && !edgeIsDynamicLink(e) {
 		// target is not a derivative work of dependency and is not linked to dependency
-		result.RemoveAllByName(ImpliesRestricted)
-		return result
+		return false, edgeRule{reason: reasonNotLinked}
 	}
 	if treatAsAggregate {
 		// If the author of a pure aggregate licenses it restricted, apply restricted to immediate dependencies.
 		// Otherwise, restricted does not propagate back down to dependencies.
-		restricted := result.ByName(ImpliesRestricted).AsList()
-		for _, lc := range restricted {
-			if lc.origin.name != e.e.target {
-				result.Remove(lc)
-			}
+		if lc.origin.name == e.e.target {
+			return true, edgeRule{reason: reasonAggregateOwn}
 		}
-		return result
+		return false, edgeRule{reason: reasonAggregateInherit}
 	}
 	if edgeIsDerivation(e) {
-		return result
-	}
-	restricted := result.ByName(ImpliesRestricted).AsList()
-	for _, lc := range restricted {
-		hasGpl := false
-		hasLgpl := false
-		hasClasspath := false
-		hasGeneric := false
-		hasOther := false
-		for _, kind := range lc.origin.LicenseKinds() {
-			if strings.HasSuffix(kind, "-with-classpath-exception") {
-				hasClasspath = true
-			} else if anyLgpl.MatchString(kind) {
-				hasLgpl = true
-			} else if versionedGpl.MatchString(kind) {
-				hasGpl = true
-			} else if genericGpl.MatchString(kind) {
-				hasGeneric = true
-			} else if kind == "legacy_restricted" || ccBySa.MatchString(kind) {
-				hasOther = true
+		return true, edgeRule{reason: reasonDerivation}
+	}
+	crosses, kind, dl := restrictedCrossesDynamicLink(e, lc, ruleFor)
+	if crosses {
+		return true, edgeRule{reasonCrossesDynamic, kind, dl}
+	}
+	return false, edgeRule{reasonStopsDynamic, kind, dl}
+}
+
+// restrictedCrossesDynamicLink returns true when the license kinds of the
+// origin of restricted condition `lc` extend the condition across the dynamic
+// link `e`, and returns the license kind and rule deciding the outcome.
+func restrictedCrossesDynamicLink(e TargetEdge, lc LicenseCondition, ruleFor func(kind string) DynamicLinkRule) (bool, string, DynamicLinkRule) {
+	shared := ""
+	exception := ""
+	classpath := ""
+	generic := ""
+	for _, kind := range lc.origin.LicenseKinds() {
+		switch ruleFor(kind) {
+		case DynamicLinkShared:
+			if len(shared) == 0 {
+				shared = kind
+			}
+		case DynamicLinkGeneric:
+			if len(generic) == 0 {
+				generic = kind
+			}
+		case DynamicLinkClasspathException:
+			if len(classpath) == 0 {
+				classpath = kind
+			}
+		case DynamicLinkException:
+			if len(exception) == 0 {
+				exception = kind
 			}
 		}
-		if hasOther || hasGpl {
-			continue
-		}
-		if hasClasspath && !edgeNodesAreIndependentModules(e) {
-			continue
+	}
+	if len(shared) > 0 {
+		return true, shared, DynamicLinkShared
+	}
+	if len(classpath) > 0 {
+		return !edgeNodesAreIndependentModules(e), classpath, DynamicLinkClasspathException
+	}
+	if len(exception) > 0 {
+		return false, exception, DynamicLinkException
+	}
+	if len(generic) > 0 {
+		return true, generic, DynamicLinkGeneric
+	}
+	return false, "", DynamicLinkUnspecified
+}
+
+// depActionsApplicableToTarget returns the actions which propagate up an
+// edge from dependency to target according to `policy`.
+func depActionsApplicableToTarget(policy Policy, e TargetEdge, depActions actionSet, treatAsAggregate bool) actionSet {
+	result := make(actionSet)
+	for actsOn, cs := range depActions {
+		for _, lc := range cs.AsList() {
+			propagates, actsOnTarget := policy.BottomUp(e, actsOn, lc, treatAsAggregate)
+			if propagates {
+				result.addCondition(actsOn, lc)
+			}
+			if actsOnTarget {
+				result.addCondition(e.Target(), lc)
+			}
 		}
-		if hasGeneric && !hasLgpl && !hasClasspath {
-			continue
+	}
+	return result
+}
+
+// targetConditionsApplicableToDep returns the conditions which propagate down
+// an edge from target to dependency according to `policy`.
+func targetConditionsApplicableToDep(policy Policy, e TargetEdge, targetConditions *LicenseConditionSet, treatAsAggregate bool) *LicenseConditionSet {
+	result := newLicenseConditionSet()
+	for _, lc := range targetConditions.AsList() {
+		if policy.TopDown(e, lc, treatAsAggregate) {
+			result.Add(lc)
 		}
-		result.Remove(lc)
 	}
 	return result
 }
@@ -251,7 +251,7 @@ func TestPolicy_edgeConditions(t *testing.T) {
 					depActions[lg.targets[tt.edge.dep]].AddSet(otherCs)
 					depActions[lg.targets[otherTarget]] = otherCs
 				}
-				asActual := depActionsApplicableToTarget(lg.Edges()[0], depActions, tt.treatAsAggregate)
+				asActual := depActionsApplicableToTarget(DefaultPolicy, lg.Edges()[0], depActions, tt.treatAsAggregate)
 				asExpected := make(actionSet)
 				for _, triple := range tt.expectedDepActions {
 					fields := strings.Split(triple, ":")
@@ -274,6 +274,7 @@ func TestPolicy_edgeConditions(t *testing.T) {
 					targetConditions.add(lg.targets[otherTarget], otherCondition)
 				}
 				cs := targetConditionsApplicableToDep(
+					DefaultPolicy,
 					lg.Edges()[0],
 					targetConditions,
 					tt.treatAsAggregate)
@@ -298,3 +299,92 @@ func TestPolicy_edgeConditions(t *testing.T) {
 		})
 	}
 }
+
+// dynamicAsStaticPolicy treats dynamic links like static links.
+type dynamicAsStaticPolicy struct {
+	Policy
+}
+
+func (p *dynamicAsStaticPolicy) BottomUp(e TargetEdge, actsOn *TargetNode, lc LicenseCondition, treatAsAggregate bool) (bool, bool) {
+	if edgeIsDynamicLink(e) {
+		return true, p.Implies(RestrictedClass).Contains(lc.name)
+	}
+	return p.Policy.BottomUp(e, actsOn, lc, treatAsAggregate)
+}
+
+func (p *dynamicAsStaticPolicy) IsDerivation(e TargetEdge) bool {
+	return !e.e.annotations.HasAnnotation("toolchain")
+}
+
+func TestPolicy_custom(t *testing.T) {
+	lg, err := toGraph(&bytes.Buffer{}, []string{"apacheBin.meta_lic"}, []annotated{
+		{"apacheBin.meta_lic", "lgplLib.meta_lic", []string{"dynamic"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	custom := &dynamicAsStaticPolicy{DefaultPolicy}
+
+	expectedDefault := toResolutionSet(lg, []res{
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "apacheBin.meta_lic", "notice"},
+		{"lgplLib.meta_lic", "lgplLib.meta_lic", "lgplLib.meta_lic", "restricted"},
+	})
+	expectedCustom := toResolutionSet(lg, []res{
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "apacheBin.meta_lic", "notice"},
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "lgplLib.meta_lic", "restricted"},
+		{"apacheBin.meta_lic", "lgplLib.meta_lic", "lgplLib.meta_lic", "restricted"},
+		{"lgplLib.meta_lic", "lgplLib.meta_lic", "lgplLib.meta_lic", "restricted"},
+	})
+
+	checkSame(ResolveBottomUpConditions(lg, DefaultPolicy), expectedDefault, t)
+	checkSame(ResolveBottomUpConditions(lg, custom), expectedCustom, t)
+	// cached results must not leak between policies
+	checkSame(ResolveBottomUpConditions(lg, DefaultPolicy), expectedDefault, t)
+
+	actualShipped := ShippedNodes(lg, DefaultPolicy).Names()
+	sort.Strings(actualShipped)
+	checkSameStrings("default shipped", actualShipped, []string{"apacheBin.meta_lic"}, t)
+	actualShipped = ShippedNodes(lg, custom).Names()
+	sort.Strings(actualShipped)
+	checkSameStrings("custom shipped", actualShipped, []string{"apacheBin.meta_lic", "lgplLib.meta_lic"}, t)
+}
+
+// noticeSharedPolicy also requires sharing the source of notice targets.
+//
+// The map makes it non-comparable so it cannot key the graph caches.
+type noticeSharedPolicy struct {
+	Policy
+	shared map[ConditionClass]ConditionNames
+}
+
+func (p noticeSharedPolicy) Implies(class ConditionClass) ConditionNames {
+	if names, ok := p.shared[class]; ok {
+		return names
+	}
+	return p.Policy.Implies(class)
+}
+
+func TestPolicy_customImplies(t *testing.T) {
+	lg, err := toGraph(&bytes.Buffer{}, []string{"apacheBin.meta_lic"}, []annotated{
+		{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"static"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	custom := &noticeSharedPolicy{DefaultPolicy, map[ConditionClass]ConditionNames{
+		SharedClass: {"notice", "reciprocal", "restricted"},
+	}}
+
+	checkSame(ResolveSourceSharing(lg), toResolutionSet(lg, []res{}), t)
+	checkSame(ResolveSourceSharingWithPolicy(lg, custom), toResolutionSet(lg, []res{
+		{"apacheBin.meta_lic", "apacheBin.meta_lic", "apacheBin.meta_lic", "notice"},
+		{"apacheBin.meta_lic", "mitLib.meta_lic", "mitLib.meta_lic", "notice"},
+	}), t)
+
+	actualShipped := ShippedNodes(lg, custom).Names()
+	sort.Strings(actualShipped)
+	checkSameStrings("custom shipped", actualShipped, []string{"apacheBin.meta_lic", "mitLib.meta_lic"}, t)
+	if ShippedNodes(lg, custom) != ShippedNodes(lg, custom) {
+		t.Errorf("unexpected recomputed shipped nodes for cached policy %v", custom)
+	}
+}
@@ -0,0 +1,458 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query selects target nodes from a license graph. (immutable)
+//
+// A query is a boolean expression of terms combined with `and`, `or`, `not`
+// and parentheses. Adjacent terms without an operator are and-ed together,
+// and `and` binds more tightly than `or`.
+//
+// Most terms look like field:pattern where the pattern matches the whole
+// value, `*` matches any sequence of characters including `/`, and `?`
+// matches any single character. Patterns with spaces or parentheses may be
+// double-quoted using Go syntax. The fields are:
+//
+//	name:         the path to the license metadata file of the target
+//	package:      the package name
+//	project:      any of the projects defining the target
+//	module_type:  any of the module types implementing the target
+//	module_class: any of the module classes implementing the target
+//	kind:         any of the license kinds of the target
+//	condition:    any of the license conditions originating at the target
+//	resolved:     any of the license conditions the target acts on after
+//	              propagating conditions through the graph per policy
+//	annotation:   any of the annotations on edges depending on the target
+//	reaches:      the target depends, directly or transitively, on a
+//	              target with a matching name
+//	from:         a target with a matching name depends, directly or
+//	              transitively, on the target
+//
+// The remaining terms are bare keywords:
+//
+//	shipped:      the target or a derivative work gets distributed per policy
+//	root:         the target is one of the roots of the graph
+//	container:    the target merely aggregates other targets
+//
+// e.g. all shipped targets under vendor/ with restricted conditions that are
+// dynamically linked:
+//
+//	shipped and project:vendor/* and condition:restricted and annotation:dynamic
+type Query struct {
+	text string
+	expr queryExpr
+}
+
+// ParseQuery parses the query `text`.
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := tokenizeQuery(text)
+	if err != nil {
+		return nil, fmt.Errorf("query %q: %w", text, err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("query %q: empty query", text)
+	}
+	p := &queryParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q at offset %d", p.tokens[p.pos].text, p.tokens[p.pos].offset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query %q: %w", text, err)
+	}
+	return &Query{text, expr}, nil
+}
+
+// String returns the text of the query.
+func (q *Query) String() string {
+	return q.text
+}
+
+// Select returns the target nodes in `lg` matching the query under `policy`
+// ordered by name.
+func (q *Query) Select(lg *LicenseGraph, policy Policy) TargetNodeList {
+	ev := &queryEval{lg: lg, policy: policy, reach: make(map[*queryTerm]map[*TargetNode]bool)}
+	result := make(TargetNodeList, 0)
+	for _, tn := range lg.Targets() {
+		if q.expr.eval(ev, tn) {
+			result = append(result, tn)
+		}
+	}
+	sort.Sort(result)
+	return result
+}
+
+// Matches returns true when `target` in `lg` matches the query under `policy`.
+func (q *Query) Matches(lg *LicenseGraph, policy Policy, target *TargetNode) bool {
+	ev := &queryEval{lg: lg, policy: policy, reach: make(map[*queryTerm]map[*TargetNode]bool)}
+	return q.expr.eval(ev, target)
+}
+
+// queryToken describes a token of query text.
+type queryToken struct {
+	text   string
+	offset int
+}
+
+// tokenizeQuery splits query `text` into parentheses and words. Words may
+// contain double-quoted strings.
+func tokenizeQuery(text string) ([]queryToken, error) {
+	tokens := make([]queryToken, 0)
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{text[i : i+1], i})
+			i++
+		default:
+			start := i
+			for i < len(text) && !strings.ContainsRune(" \t\n\r()", rune(text[i])) {
+				if text[i] != '"' {
+					i++
+					continue
+				}
+				end := i + 1
+				for end < len(text) && text[end] != '"' {
+					if text[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				if end >= len(text) {
+					return nil, fmt.Errorf("unterminated string at offset %d", i)
+				}
+				i = end + 1
+			}
+			tokens = append(tokens, queryToken{text[start:i], start})
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser implements a recursive descent parser for query tokens.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// peek returns the text of the next token or "" at the end.
+func (p *queryParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+// parseOr parses a sequence of and-expressions separated by `or`.
+func (p *queryParser) parseOr() (queryExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryOr{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd parses a sequence of not-expressions optionally separated by `and`.
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		next := p.peek()
+		if next == "and" {
+			p.pos++
+		} else if next == "" || next == "or" || next == ")" {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryAnd{left, right}
+	}
+}
+
+// parseNot parses an optionally negated term or parenthesized expression.
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch tok.text {
+	case "not":
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNot{expr}, nil
+	case "(":
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ) for ( at offset %d", tok.offset)
+		}
+		p.pos++
+		return expr, nil
+	case ")", "and", "or":
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.offset)
+	}
+	return parseQueryTerm(tok)
+}
+
+// queryFields lists the fields of field:pattern terms.
+var queryFields = map[string]bool{
+	"name":         true,
+	"package":      true,
+	"project":      true,
+	"module_type":  true,
+	"module_class": true,
+	"kind":         true,
+	"condition":    true,
+	"resolved":     true,
+	"annotation":   true,
+	"reaches":      true,
+	"from":         true,
+}
+
+// queryKeywords lists the bare keyword terms.
+var queryKeywords = map[string]bool{
+	"shipped":   true,
+	"root":      true,
+	"container": true,
+}
+
+// parseQueryTerm parses a field:pattern or keyword term from `tok`.
+func parseQueryTerm(tok queryToken) (queryExpr, error) {
+	if queryKeywords[tok.text] {
+		return &queryTerm{field: tok.text}, nil
+	}
+	colon := strings.IndexByte(tok.text, ':')
+	if colon < 0 {
+		return nil, fmt.Errorf("unknown keyword %q at offset %d", tok.text, tok.offset)
+	}
+	field, pattern := tok.text[:colon], tok.text[colon+1:]
+	if !queryFields[field] {
+		return nil, fmt.Errorf("unknown field %q at offset %d", field, tok.offset)
+	}
+	if strings.HasPrefix(pattern, "\"") {
+		unquoted, err := strconv.Unquote(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s at offset %d", pattern, tok.offset+colon+1)
+		}
+		pattern = unquoted
+	}
+	if len(pattern) == 0 {
+		return nil, fmt.Errorf("missing pattern for %q at offset %d", field, tok.offset)
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return &queryTerm{field, regexp.MustCompile(sb.String())}, nil
+}
+
+// queryExpr describes a node of a parsed query.
+type queryExpr interface {
+	// eval returns true when `tn` satisfies the expression.
+	eval(ev *queryEval, tn *TargetNode) bool
+}
+
+// queryAnd matches targets matching both `left` and `right`.
+type queryAnd struct {
+	left, right queryExpr
+}
+
+func (q *queryAnd) eval(ev *queryEval, tn *TargetNode) bool {
+	return q.left.eval(ev, tn) && q.right.eval(ev, tn)
+}
+
+// queryOr matches targets matching either `left` or `right`.
+type queryOr struct {
+	left, right queryExpr
+}
+
+func (q *queryOr) eval(ev *queryEval, tn *TargetNode) bool {
+	return q.left.eval(ev, tn) || q.right.eval(ev, tn)
+}
+
+// queryNot matches targets not matching `expr`.
+type queryNot struct {
+	expr queryExpr
+}
+
+func (q *queryNot) eval(ev *queryEval, tn *TargetNode) bool {
+	return !q.expr.eval(ev, tn)
+}
+
+// queryTerm matches targets with a `field` value matching `pattern`, or
+// targets with the property `field` for keywords.
+type queryTerm struct {
+	field   string
+	pattern *regexp.Regexp
+}
+
+func (q *queryTerm) eval(ev *queryEval, tn *TargetNode) bool {
+	switch q.field {
+	case "shipped":
+		return ev.shippedNodes().Contains(tn)
+	case "root":
+		return ev.rootNodes()[tn]
+	case "container":
+		return tn.IsContainer()
+	case "name":
+		return q.pattern.MatchString(tn.name)
+	case "package":
+		return q.pattern.MatchString(tn.PackageName())
+	case "project":
+		return q.matchAny(tn.proto.Projects)
+	case "module_type":
+		return q.matchAny(tn.proto.ModuleTypes)
+	case "module_class":
+		return q.matchAny(tn.proto.ModuleClasses)
+	case "kind":
+		return q.matchAny(tn.proto.LicenseKinds)
+	case "condition":
+		return q.matchAny(tn.proto.LicenseConditions)
+	case "resolved":
+		return q.matchAny(ev.resolvedConditions(tn))
+	case "annotation":
+		for _, e := range ev.lg.Dependents(tn) {
+			if q.matchAny(e.Annotations().AsList()) {
+				return true
+			}
+		}
+		return false
+	case "reaches", "from":
+		return ev.reachable(q)[tn]
+	}
+	panic(fmt.Errorf("unknown query field %q", q.field))
+}
+
+// matchAny returns true when any of `values` matches the pattern.
+func (q *queryTerm) matchAny(values []string) bool {
+	for _, v := range values {
+		if q.pattern.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryEval caches the graph properties needed to evaluate a query.
+type queryEval struct {
+	lg     *LicenseGraph
+	policy Policy
+
+	shipped  *TargetNodeSet
+	roots    map[*TargetNode]bool
+	resolved *ResolutionSet
+	reach    map[*queryTerm]map[*TargetNode]bool
+}
+
+// shippedNodes returns the targets distributed per policy.
+func (ev *queryEval) shippedNodes() *TargetNodeSet {
+	if ev.shipped == nil {
+		ev.shipped = ShippedNodes(ev.lg, ev.policy)
+	}
+	return ev.shipped
+}
+
+// rootNodes returns the roots of the graph.
+func (ev *queryEval) rootNodes() map[*TargetNode]bool {
+	if ev.roots == nil {
+		ev.roots = make(map[*TargetNode]bool)
+		for _, r := range ev.lg.rootFiles {
+			if tn, ok := ev.lg.targets[r]; ok {
+				ev.roots[tn] = true
+			}
+		}
+	}
+	return ev.roots
+}
+
+// resolvedConditions returns the names of the conditions `tn` acts on.
+func (ev *queryEval) resolvedConditions(tn *TargetNode) []string {
+	if ev.resolved == nil {
+		ev.resolved = ResolveTopDownConditions(ev.lg, ev.policy)
+	}
+	return ev.resolved.ResolutionsByActsOn(tn).AllConditions().Names()
+}
+
+// reachable returns the targets depending on (`reaches`) or depended on by
+// (`from`) the targets with names matching the pattern of `q`.
+func (ev *queryEval) reachable(q *queryTerm) map[*TargetNode]bool {
+	if result, ok := ev.reach[q]; ok {
+		return result
+	}
+	next := make(map[*TargetNode][]*TargetNode)
+	for _, e := range ev.lg.Edges() {
+		if q.field == "reaches" {
+			next[e.Dependency()] = append(next[e.Dependency()], e.Target())
+		} else {
+			next[e.Target()] = append(next[e.Target()], e.Dependency())
+		}
+	}
+	queue := make([]*TargetNode, 0)
+	for _, tn := range ev.lg.targets {
+		if q.pattern.MatchString(tn.name) {
+			queue = append(queue, tn)
+		}
+	}
+	result := make(map[*TargetNode]bool)
+	for len(queue) > 0 {
+		tn := queue[0]
+		queue = queue[1:]
+		for _, n := range next[tn] {
+			if !result[n] {
+				result[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	ev.reach[q] = result
+	return result
+}
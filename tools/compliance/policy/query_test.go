@@ -0,0 +1,113 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQuery(t *testing.T) {
+	fs := testFS{
+		"apacheBin.meta_lic": []byte(AOSP + "projects: \"device/bin\"\nmodule_classes: \"EXECUTABLES\"\nmodule_types: \"cc_binary\"\n" +
+			"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n" +
+			"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+			"deps: {\n  file: \"gplBin.meta_lic\"\n  annotations: \"toolchain\"\n}\n"),
+		"gplLib.meta_lic": []byte(GPL + "projects: \"vendor/gpl/lib\"\nmodule_classes: \"SHARED_LIBRARIES\"\n"),
+		"mitLib.meta_lic": []byte(MIT + "projects: \"vendor/mit\"\nmodule_classes: \"STATIC_LIBRARIES\"\n" +
+			"deps: {\n  file: \"mplLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+		"mplLib.meta_lic": []byte(MPL + "projects: \"external/mpl\"\nmodule_classes: \"STATIC_LIBRARIES\"\n"),
+		"gplBin.meta_lic": []byte(GPL + "projects: \"vendor/gpl/tool\"\nmodule_classes: \"EXECUTABLES\"\n"),
+	}
+	stderr := &bytes.Buffer{}
+	lg, err := ReadLicenseGraph(&fs, stderr, []string{"apacheBin.meta_lic", "gplLib.meta_lic"})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"name:gplLib.meta_lic", []string{"gplLib.meta_lic"}},
+		{"name:*Lib*", []string{"gplLib.meta_lic", "mitLib.meta_lic", "mplLib.meta_lic"}},
+		{"name:?plLib.meta_lic", []string{"gplLib.meta_lic", "mplLib.meta_lic"}},
+		{"package:\"Free Software\"", []string{"gplBin.meta_lic", "gplLib.meta_lic"}},
+		{"project:vendor/*", []string{"gplBin.meta_lic", "gplLib.meta_lic", "mitLib.meta_lic"}},
+		{"project:vendor", []string{}},
+		{"module_type:cc_*", []string{"apacheBin.meta_lic"}},
+		{"module_class:EXECUTABLES", []string{"apacheBin.meta_lic", "gplBin.meta_lic"}},
+		{"kind:*MPL*", []string{"mplLib.meta_lic"}},
+		{"condition:restricted", []string{"gplBin.meta_lic", "gplLib.meta_lic"}},
+		{"resolved:restricted", []string{"apacheBin.meta_lic", "gplBin.meta_lic", "gplLib.meta_lic", "mitLib.meta_lic", "mplLib.meta_lic"}},
+		{"resolved:restricted not condition:restricted", []string{"apacheBin.meta_lic", "mitLib.meta_lic", "mplLib.meta_lic"}},
+		{"annotation:dynamic", []string{"gplLib.meta_lic"}},
+		{"annotation:static", []string{"mitLib.meta_lic", "mplLib.meta_lic"}},
+		{"reaches:mplLib.meta_lic", []string{"apacheBin.meta_lic", "mitLib.meta_lic"}},
+		{"from:mitLib.meta_lic", []string{"mplLib.meta_lic"}},
+		{"shipped", []string{"apacheBin.meta_lic", "gplLib.meta_lic", "mitLib.meta_lic", "mplLib.meta_lic"}},
+		{"root", []string{"apacheBin.meta_lic", "gplLib.meta_lic"}},
+		{"container", []string{}},
+		{"not shipped", []string{"gplBin.meta_lic"}},
+		{"shipped project:vendor/* condition:restricted", []string{"gplLib.meta_lic"}},
+		{"shipped and project:vendor/* and condition:restricted and annotation:dynamic", []string{"gplLib.meta_lic"}},
+		{"shipped and project:vendor/* and condition:restricted and annotation:static", []string{}},
+		{"condition:reciprocal or module_type:cc_binary", []string{"apacheBin.meta_lic", "mplLib.meta_lic"}},
+		{"shipped and (condition:reciprocal or condition:restricted)", []string{"gplLib.meta_lic", "mplLib.meta_lic"}},
+		{"shipped and condition:reciprocal or condition:restricted", []string{"gplBin.meta_lic", "gplLib.meta_lic", "mplLib.meta_lic"}},
+		{"not (root or from:apacheBin.meta_lic)", []string{}},
+		{"not root from:apacheBin.meta_lic annotation:toolchain", []string{"gplBin.meta_lic"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: got %s, want no error", err)
+			}
+			actual := q.Select(lg, DefaultPolicy).Names()
+			checkSameStrings("target", actual, tt.expected, t)
+		})
+	}
+}
+
+func TestQuery_errors(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"", "empty query"},
+		{"  ", "empty query"},
+		{"name", "unknown keyword \"name\" at offset 0"},
+		{"owner:me", "unknown field \"owner\" at offset 0"},
+		{"name:", "missing pattern for \"name\" at offset 0"},
+		{"name:\"a b", "unterminated string at offset 5"},
+		{"shipped and", "unexpected end of query"},
+		{"(shipped", "missing ) for ( at offset 0"},
+		{"shipped)", "unexpected \")\" at offset 7"},
+		{"or shipped", "unexpected \"or\" at offset 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := ParseQuery(tt.query)
+			if err == nil {
+				t.Fatalf("missing error: got no error, want %q", tt.expected)
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("unexpected error: got %q, want %q", err.Error(), tt.expected)
+			}
+		})
+	}
+}
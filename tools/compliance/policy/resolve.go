@@ -27,11 +27,12 @@ package compliance
 // dependencies originate any "restricted" conditions. The bottom-up walk will
 // not resolve the library and its transitive closure, but the later top-down
 // walk will.
-func ResolveBottomUpConditions(lg *LicenseGraph) *ResolutionSet {
-
+//
+// `policy` establishes which conditions propagate up each edge.
+func ResolveBottomUpConditions(lg *LicenseGraph, policy Policy) *ResolutionSet {
 	// short-cut if already walked and cached
 	lg.mu.Lock()
-	rs := lg.rsBU
+	rs := lg.rsBU[policy]
 	lg.mu.Unlock()
 
 	if rs != nil {
@@ -41,15 +42,14 @@ func ResolveBottomUpConditions(lg *LicenseGraph) *ResolutionSet {
 	// must be indexed for fast lookup
 	lg.indexForward()
 
-	rs = resolveBottomUp(lg, make(map[*TargetNode]actionSet) /* empty map; no prior resolves */)
-
+	rs = resolveBottomUp(lg, policy, make(map[*TargetNode]actionSet) /* empty map; no prior resolves */)
 	// if not yet cached, save the result
 	lg.mu.Lock()
-	if lg.rsBU == nil {
-		lg.rsBU = rs
+	if cached, ok := lg.rsBU[policy]; !ok {
+		lg.rsBU[policy] = rs
 	} else {
 		// if we end up with 2, release the later for garbage collection
-		rs = lg.rsBU
+		rs = cached
 	}
 	lg.mu.Unlock()
 
@@ -57,17 +57,16 @@ func ResolveBottomUpConditions(lg *LicenseGraph) *ResolutionSet {
 }
 
 // ResolveTopDownCondtions performs a top-down walk of the LicenseGraph
-// resolving all reachable nodes for `condition`. Policy establishes the rules
-// for transforming and propagating resolutions down the graph.
+// resolving all reachable nodes for `condition`. `policy` establishes the
+// rules for transforming and propagating resolutions down the graph.
 //
-// e.g. For current policy, none of the conditions propagate from target to
+// e.g. For the default policy, none of the conditions propagate from target to
 // dependency except restricted. For restricted, the policy is to share the
 // source of any libraries linked to restricted code and to provide notice.
-func ResolveTopDownConditions(lg *LicenseGraph) *ResolutionSet {
-
+func ResolveTopDownConditions(lg *LicenseGraph, policy Policy) *ResolutionSet {
 	// short-cut if already walked and cached
 	lg.mu.Lock()
-	rs := lg.rsTD
+	rs := lg.rsTD[policy]
 	lg.mu.Unlock()
 
 	if rs != nil {
@@ -75,7 +74,7 @@ func ResolveTopDownConditions(lg *LicenseGraph) *ResolutionSet {
 	}
 
 	// start with the conditions propagated up the graph
-	rs = ResolveBottomUpConditions(lg)
+	rs = ResolveBottomUpConditions(lg, policy)
 
 	// rmap maps 'appliesTo' targets to their applicable conditions
 	//
@@ -104,7 +103,7 @@ func ResolveTopDownConditions(lg *LicenseGraph) *ResolutionSet {
 		for _, edge := range lg.index[fnode.name] {
 			e := TargetEdge{lg, edge}
 			// dcs holds the dpendency conditions inherited from the target
-			dcs := targetConditionsApplicableToDep(e, cs, treatAsAggregate)
+			dcs := targetConditionsApplicableToDep(policy, e, cs, treatAsAggregate)
 			if dcs.IsEmpty() && !treatAsAggregate {
 				continue
 			}
@@ -158,15 +157,14 @@ func ResolveTopDownConditions(lg *LicenseGraph) *ResolutionSet {
 	}
 
 	// propagate any new conditions back up the graph
-	rs = resolveBottomUp(lg, rmap)
-
+	rs = resolveBottomUp(lg, policy, rmap)
 	// if not yet cached, save the result
 	lg.mu.Lock()
-	if lg.rsTD == nil {
-		lg.rsTD = rs
+	if cached, ok := lg.rsTD[policy]; !ok {
+		lg.rsTD[policy] = rs
 	} else {
 		// if we end up with 2, release the later for garbage collection
-		rs = lg.rsTD
+		rs = cached
 	}
 	lg.mu.Unlock()
 
@@ -174,8 +172,8 @@ func ResolveTopDownConditions(lg *LicenseGraph) *ResolutionSet {
 }
 
 // resolveBottomUp implements a bottom-up resolve propagating conditions both
-// from the graph, and from a `priors` map of resolutions.
-func resolveBottomUp(lg *LicenseGraph, priors map[*TargetNode]actionSet) *ResolutionSet {
+// from the graph, and from a `priors` map of resolutions according to `policy`.
+func resolveBottomUp(lg *LicenseGraph, policy Policy, priors map[*TargetNode]actionSet) *ResolutionSet {
 	rs := newResolutionSet()
 
 	// cmap contains an entry for every target that was previously walked as a pure aggregate only.
@@ -214,7 +212,7 @@ func resolveBottomUp(lg *LicenseGraph, priors map[*TargetNode]actionSet) *Resolu
 			as := walk(edge.dependency, treatAsAggregate && lg.targets[edge.dependency].IsContainer())
 
 			// turn those into the conditions that apply to the target
-			as = depActionsApplicableToTarget(TargetEdge{lg, edge}, as, treatAsAggregate)
+			as = depActionsApplicableToTarget(policy, TargetEdge{lg, edge}, as, treatAsAggregate)
 
 			// add them to the result
 			result.addSet(as)
@@ -225,7 +223,7 @@ func resolveBottomUp(lg *LicenseGraph, priors map[*TargetNode]actionSet) *Resolu
 		if len(priors) == 0 {
 			// on the first bottom-up resolve, parents have their own sharing and notice needs
 			// on the later resolve, if priors is empty, there will be nothing new to add
-			rs.addSelf(target, result.byName(ImpliesRestricted))
+			rs.addSelf(target, result.byName(policy.Implies(RestrictedClass)))
 		}
 
 		// return this up the tree
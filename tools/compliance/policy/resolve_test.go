@@ -384,7 +384,7 @@ func TestResolveBottomUpConditions(t *testing.T) {
 				return
 			}
 			expectedRs := toResolutionSet(lg, tt.expectedResolutions)
-			actualRs := ResolveBottomUpConditions(lg)
+			actualRs := ResolveBottomUpConditions(lg, DefaultPolicy)
 			checkSame(actualRs, expectedRs, t)
 		})
 	}
@@ -757,7 +757,7 @@ func TestResolveTopDownConditions(t *testing.T) {
 				return
 			}
 			expectedRs := toResolutionSet(lg, tt.expectedResolutions)
-			actualRs := ResolveTopDownConditions(lg)
+			actualRs := ResolveTopDownConditions(lg, DefaultPolicy)
 			checkSame(actualRs, expectedRs, t)
 		})
 	}
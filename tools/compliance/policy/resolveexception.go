@@ -0,0 +1,28 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+// ResolveByExceptionOnly implements the policy for license review and approval
+// before use.
+func ResolveByExceptionOnly(lg *LicenseGraph) *ResolutionSet {
+	return ResolveByExceptionOnlyWithPolicy(lg, DefaultPolicy)
+}
+
+// ResolveByExceptionOnlyWithPolicy implements license review and approval
+// before use under `policy`.
+func ResolveByExceptionOnlyWithPolicy(lg *LicenseGraph, policy Policy) *ResolutionSet {
+	rs := ResolveTopDownConditions(lg, policy)
+	return WalkResolutionsForCondition(lg, policy, rs, policy.Implies(ByExceptionOnlyClass))
+}
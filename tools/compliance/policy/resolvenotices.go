@@ -16,6 +16,11 @@ package compliance
 
 // ResolveNotices implements the policy for notices.
 func ResolveNotices(lg *LicenseGraph) *ResolutionSet {
-	rs := ResolveTopDownConditions(lg)
-	return WalkResolutionsForCondition(lg, rs, ImpliesNotice)
+	return ResolveNoticesWithPolicy(lg, DefaultPolicy)
+}
+
+// ResolveNoticesWithPolicy implements notices under `policy`.
+func ResolveNoticesWithPolicy(lg *LicenseGraph, policy Policy) *ResolutionSet {
+	rs := ResolveTopDownConditions(lg, policy)
+	return WalkResolutionsForCondition(lg, policy, rs, policy.Implies(NoticeClass))
 }
@@ -16,6 +16,11 @@ package compliance
 
 // ResolveSourcePrivacy implements the policy for source privacy.
 func ResolveSourcePrivacy(lg *LicenseGraph) *ResolutionSet {
-	rs := ResolveTopDownConditions(lg)
-	return WalkResolutionsForCondition(lg, rs, ImpliesPrivate)
+	return ResolveSourcePrivacyWithPolicy(lg, DefaultPolicy)
+}
+
+// ResolveSourcePrivacyWithPolicy implements source privacy under `policy`.
+func ResolveSourcePrivacyWithPolicy(lg *LicenseGraph, policy Policy) *ResolutionSet {
+	rs := ResolveTopDownConditions(lg, policy)
+	return WalkResolutionsForCondition(lg, policy, rs, policy.Implies(PrivateClass))
 }
@@ -16,6 +16,66 @@ package compliance
 
 // ResolveSourceSharing implements the policy for source-sharing.
 func ResolveSourceSharing(lg *LicenseGraph) *ResolutionSet {
-	rs := ResolveTopDownConditions(lg)
-	return WalkResolutionsForCondition(lg, rs, ImpliesShared)
+	return ResolveSourceSharingWithPolicy(lg, DefaultPolicy)
+}
+
+// ResolveSourceSharingWithPolicy implements source-sharing under `policy`.
+func ResolveSourceSharingWithPolicy(lg *LicenseGraph, policy Policy) *ResolutionSet {
+	rs := ResolveTopDownConditions(lg, policy)
+	return WalkResolutionsForCondition(lg, policy, rs, policy.Implies(SharedClass))
+}
+
+// ResolveReciprocalSourceSharing implements the policy for reciprocal
+// source-sharing, which only requires sharing the files of the originating
+// target.
+func ResolveReciprocalSourceSharing(lg *LicenseGraph) *ResolutionSet {
+	return ResolveReciprocalSourceSharingWithPolicy(lg, DefaultPolicy)
+}
+
+// ResolveReciprocalSourceSharingWithPolicy implements reciprocal
+// source-sharing under `policy`.
+func ResolveReciprocalSourceSharingWithPolicy(lg *LicenseGraph, policy Policy) *ResolutionSet {
+	rs := ResolveTopDownConditions(lg, policy)
+	return WalkResolutionsForCondition(lg, policy, rs, policy.Implies(ReciprocalClass))
+}
+
+// ResolveRestrictedSourceSharing implements the policy for restricted
+// source-sharing, which requires sharing whole projects.
+func ResolveRestrictedSourceSharing(lg *LicenseGraph) *ResolutionSet {
+	return ResolveRestrictedSourceSharingWithPolicy(lg, DefaultPolicy)
+}
+
+// ResolveRestrictedSourceSharingWithPolicy implements restricted
+// source-sharing under `policy`.
+func ResolveRestrictedSourceSharingWithPolicy(lg *LicenseGraph, policy Policy) *ResolutionSet {
+	rs := ResolveTopDownConditions(lg, policy)
+	return WalkResolutionsForCondition(lg, policy, rs, policy.Implies(RestrictedClass))
+}
+
+// SharedSourceFiles returns the source files to share to resolve the
+// conditions in `rs` mapped to the conditions each file resolves, and the
+// targets acted on that do not list their sources and must be shared whole.
+//
+// Only meaningful for reciprocal resolutions, e.g. from
+// ResolveReciprocalSourceSharing: restricted conditions require sharing the
+// whole project regardless of which files the target uses.
+func SharedSourceFiles(rs *ResolutionSet) (map[string]*LicenseConditionSet, TargetNodeList) {
+	files := make(map[string]*LicenseConditionSet)
+	unlisted := make(TargetNodeList, 0)
+	for _, actsOn := range rs.ActsOn() {
+		conditions := rs.ResolutionsByActsOn(actsOn).AllConditions()
+		sources := actsOn.Sources()
+		if len(sources) == 0 {
+			unlisted = append(unlisted, actsOn)
+			continue
+		}
+		for _, f := range sources {
+			if _, ok := files[f]; !ok {
+				files[f] = conditions.Copy()
+				continue
+			}
+			files[f].AddSet(conditions)
+		}
+	}
+	return files, unlisted
 }
@@ -16,6 +16,8 @@ package compliance
 
 import (
 	"bytes"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -295,3 +297,52 @@ func TestResolveSourceSharing(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveReciprocalSourceSharing(t *testing.T) {
+	fs := testFS{
+		"apacheBin.meta_lic": []byte(AOSP +
+			"deps: {\n  file: \"mplLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+			"deps: {\n  file: \"mplBin.meta_lic\"\n  annotations: \"static\"\n}\n" +
+			"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+		"mplLib.meta_lic": []byte(MPL + "sources: \"external/mpl/a.c\"\nsources: \"external/mpl/b.c\"\n"),
+		"mplBin.meta_lic": []byte(MPL),
+		"gplLib.meta_lic": []byte(GPL),
+	}
+	stderr := &bytes.Buffer{}
+	lg, err := ReadLicenseGraph(&fs, stderr, []string{"apacheBin.meta_lic"})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+
+	t.Run("reciprocal", func(t *testing.T) {
+		expectedRs := toResolutionSet(lg, []res{
+			{"apacheBin.meta_lic", "mplBin.meta_lic", "mplBin.meta_lic", "reciprocal"},
+			{"apacheBin.meta_lic", "mplLib.meta_lic", "mplLib.meta_lic", "reciprocal"},
+		})
+		checkSame(ResolveReciprocalSourceSharing(lg), expectedRs, t)
+	})
+
+	t.Run("restricted", func(t *testing.T) {
+		expectedRs := toResolutionSet(lg, []res{
+			{"apacheBin.meta_lic", "apacheBin.meta_lic", "gplLib.meta_lic", "restricted"},
+			{"apacheBin.meta_lic", "gplLib.meta_lic", "gplLib.meta_lic", "restricted"},
+			{"apacheBin.meta_lic", "mplBin.meta_lic", "gplLib.meta_lic", "restricted"},
+			{"apacheBin.meta_lic", "mplLib.meta_lic", "gplLib.meta_lic", "restricted"},
+		})
+		checkSame(ResolveRestrictedSourceSharing(lg), expectedRs, t)
+	})
+
+	t.Run("files", func(t *testing.T) {
+		files, unlisted := SharedSourceFiles(ResolveReciprocalSourceSharing(lg))
+		actual := make([]string, 0, len(files))
+		for f, cs := range files {
+			actual = append(actual, f+" "+strings.Join(cs.asStringList(":"), " "))
+		}
+		sort.Strings(actual)
+		checkSameStrings("file", actual, []string{
+			"external/mpl/a.c mplLib.meta_lic:reciprocal",
+			"external/mpl/b.c mplLib.meta_lic:reciprocal",
+		}, t)
+		checkSameStrings("unlisted target", unlisted.Names(), []string{"mplBin.meta_lic"}, t)
+	})
+}
@@ -46,14 +46,23 @@ func (conflict SourceSharePrivacyConflict) IsEqualTo(other SourceSharePrivacyCon
 // ConflictingSharedPrivateSource lists all of the targets where conflicting conditions to
 // share the source and to keep the source private apply to the target.
 func ConflictingSharedPrivateSource(lg *LicenseGraph) []SourceSharePrivacyConflict {
+	return ConflictingSharedPrivateSourceWithPolicy(lg, DefaultPolicy)
+}
+
+// ConflictingSharedPrivateSourceWithPolicy lists the conflicts between
+// source-sharing and source privacy under `policy`.
+func ConflictingSharedPrivateSourceWithPolicy(lg *LicenseGraph, policy Policy) []SourceSharePrivacyConflict {
+	shared := policy.Implies(SharedClass)
+	private := policy.Implies(PrivateClass)
+
 	// shareSource is the set of all source-sharing resolutions.
-	shareSource := ResolveSourceSharing(lg)
+	shareSource := ResolveSourceSharingWithPolicy(lg, policy)
 	if shareSource.IsEmpty() {
 		return []SourceSharePrivacyConflict{}
 	}
 
 	// privateSource is the set of all source privacy resolutions.
-	privateSource := ResolveSourcePrivacy(lg)
+	privateSource := ResolveSourcePrivacyWithPolicy(lg, policy)
 	if privateSource.IsEmpty() {
 		return []SourceSharePrivacyConflict{}
 	}
@@ -65,7 +74,7 @@ func ConflictingSharedPrivateSource(lg *LicenseGraph) []SourceSharePrivacyConfli
 	size := 0
 	for _, actsOn := range combined.ActsOn() {
 		rl := combined.ResolutionsByActsOn(actsOn)
-		size += rl.CountConditionsByName(ImpliesShared) * rl.CountConditionsByName(ImpliesPrivate)
+		size += rl.CountConditionsByName(shared) * rl.CountConditionsByName(private)
 	}
 	if size == 0 {
 		return []SourceSharePrivacyConflict{}
@@ -77,8 +86,8 @@ func ConflictingSharedPrivateSource(lg *LicenseGraph) []SourceSharePrivacyConfli
 			continue
 		}
 
-		pconditions := rl.ByName(ImpliesPrivate).AllConditions().AsList()
-		ssconditions := rl.ByName(ImpliesShared).AllConditions().AsList()
+		pconditions := rl.ByName(private).AllConditions().AsList()
+		ssconditions := rl.ByName(shared).AllConditions().AsList()
 
 		// report all conflicting condition combinations
 		for _, p := range pconditions {
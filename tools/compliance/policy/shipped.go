@@ -15,10 +15,10 @@
 package compliance
 
 // ShippedNodes returns the set of nodes in a license graph where the target or
-// a derivative work gets distributed. (caches result)
-func ShippedNodes(lg *LicenseGraph) *TargetNodeSet {
+// a derivative work gets distributed according to `policy`. (caches result)
+func ShippedNodes(lg *LicenseGraph, policy Policy) *TargetNodeSet {
 	lg.mu.Lock()
-	shipped := lg.shippedNodes
+	shipped := lg.shippedNodes[policy]
 	lg.mu.Unlock()
 	if shipped != nil {
 		return shipped
@@ -31,7 +31,7 @@ func ShippedNodes(lg *LicenseGraph) *TargetNodeSet {
 			return false
 		}
 		if len(path) > 0 {
-			if !edgeIsDerivation(path[len(path)-1]) {
+			if !policy.IsDerivation(path[len(path)-1]) {
 				return false
 			}
 		}
@@ -42,11 +42,11 @@ func ShippedNodes(lg *LicenseGraph) *TargetNodeSet {
 	shipped = &TargetNodeSet{tset}
 
 	lg.mu.Lock()
-	if lg.shippedNodes == nil {
-		lg.shippedNodes = shipped
+	if cached, ok := lg.shippedNodes[policy]; !ok {
+		lg.shippedNodes[policy] = shipped
 	} else {
 		// if we end up with 2, release the later for garbage collection.
-		shipped = lg.shippedNodes
+		shipped = cached
 	}
 	lg.mu.Unlock()
 
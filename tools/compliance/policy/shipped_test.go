@@ -111,7 +111,7 @@ func TestShippedNodes(t *testing.T) {
 				return
 			}
 			expectedNodes := append([]string{}, tt.expectedNodes...)
-			actualNodes := ShippedNodes(lg).Names()
+			actualNodes := ShippedNodes(lg, DefaultPolicy).Names()
 			sort.Strings(expectedNodes)
 			sort.Strings(actualNodes)
                         if len(expectedNodes) != len(actualNodes) {
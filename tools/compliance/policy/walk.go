@@ -14,9 +14,17 @@
 
 package compliance
 
+import (
+	"sort"
+)
+
 // VisitNode is called for each root and for each walked dependency node by
 // WalkTopDown. When VisitNode returns true, WalkTopDown will proceed to walk
 // down the dependences of the node
+//
+// Likewise, WalkBottomUp calls VisitNode for each starting node and for each
+// walked dependent node, and proceeds to walk up the dependents of the node
+// when VisitNode returns true.
 type VisitNode func(*LicenseGraph, *TargetNode, TargetEdgePath) bool
 
 // WalkTopDown does a top-down walk of `lg` calling `visit` and descending
@@ -46,10 +54,72 @@ func WalkTopDown(lg *LicenseGraph, visit VisitNode) {
 	}
 }
 
+// WalkBottomUp does a bottom-up walk of `lg` from every leaf, i.e. every
+// target without dependencies, calling `visit` and ascending into dependents
+// when `visit` returns true.
+//
+// The path passed to `visit` lists the edges from the visited node down to the
+// leaf where the walk started.
+func WalkBottomUp(lg *LicenseGraph, visit VisitNode) {
+	// must be indexed for fast lookup
+	lg.indexForward()
+
+	leaves := make(TargetNodeList, 0)
+	for _, tn := range lg.targets {
+		if len(lg.index[tn.name]) == 0 {
+			leaves = append(leaves, tn)
+		}
+	}
+	sort.Sort(leaves)
+
+	WalkBottomUpFrom(lg, visit, leaves...)
+}
+
+// WalkBottomUpFrom does a bottom-up walk of `lg` from each of `targets`
+// calling `visit` and ascending into dependents when `visit` returns true.
+//
+// e.g. walking up from a library finds every target that depends on it
+// directly or transitively, and the paths from those targets to the library.
+//
+// The path passed to `visit` lists the edges from the visited node down to the
+// target where the walk started. Like with WalkTopDown, the path gets reused
+// after `visit` returns.
+func WalkBottomUpFrom(lg *LicenseGraph, visit VisitNode, targets ...*TargetNode) {
+	// up lists the edges climbed from the starting target in walk order
+	up := make(TargetEdgePath, 0, 32)
+	path := NewTargetEdgePath(32)
+
+	// must be indexed for fast lookup
+	lg.indexReverse()
+
+	var walk func(f string)
+	walk = func(f string) {
+		// reverse `up` into `path` to list the edges from `f` down
+		path.Clear()
+		for i := len(up) - 1; i >= 0; i-- {
+			*path = append(*path, up[i])
+		}
+		visitParents := visit(lg, lg.targets[f], *path)
+		if !visitParents {
+			return
+		}
+		for _, edge := range lg.reverseIndex[f] {
+			up = append(up, TargetEdge{lg, edge})
+			walk(edge.target)
+			up = up[:len(up)-1]
+		}
+	}
+
+	for _, tn := range targets {
+		up = up[:0]
+		walk(tn.name)
+	}
+}
+
 // WalkResolutionsForCondition performs a top-down walk of the LicenseGraph
-// resolving all distributed works for condition `names`.
-func WalkResolutionsForCondition(lg *LicenseGraph, rs *ResolutionSet, names ConditionNames) *ResolutionSet {
-	shipped := ShippedNodes(lg)
+// resolving all works distributed according to `policy` for condition `names`.
+func WalkResolutionsForCondition(lg *LicenseGraph, policy Policy, rs *ResolutionSet, names ConditionNames) *ResolutionSet {
+	shipped := ShippedNodes(lg, policy)
 
 	// rmap maps 'attachesTo' targets to the `actsOn` targets and applicable conditions
 	//
@@ -16,6 +16,8 @@ package compliance
 
 import (
 	"bytes"
+	"fmt"
+	"sort"
 	"testing"
 )
 
@@ -624,8 +626,93 @@ func TestWalkResolutionsForCondition(t *testing.T) {
 				return
 			}
 			expectedRs := toResolutionSet(lg, tt.expectedResolutions)
-			actualRs := WalkResolutionsForCondition(lg, ResolveTopDownConditions(lg), tt.condition)
+			actualRs := WalkResolutionsForCondition(lg, DefaultPolicy, ResolveTopDownConditions(lg, DefaultPolicy), tt.condition)
 			checkSame(actualRs, expectedRs, t)
 		})
 	}
 }
+
+func TestDependents(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	lg, err := toGraph(stderr, []string{"apacheBin.meta_lic"}, []annotated{
+		{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"static"}},
+		{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"dynamic"}},
+		{"mitLib.meta_lic", "gplLib.meta_lic", []string{"static"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	tests := []struct {
+		target   string
+		expected []string
+	}{
+		{"gplLib.meta_lic", []string{"apacheBin.meta_lic -> gplLib.meta_lic [static]", "mitLib.meta_lic -> gplLib.meta_lic [static]"}},
+		{"mitLib.meta_lic", []string{"apacheBin.meta_lic -> mitLib.meta_lic [dynamic]"}},
+		{"apacheBin.meta_lic", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			edges := lg.Dependents(lg.TargetNode(tt.target))
+			sort.Sort(edges)
+			actual := make([]string, 0, len(edges))
+			for _, e := range edges {
+				actual = append(actual, fmt.Sprintf("%s -> %s %v", e.Target().Name(), e.Dependency().Name(), e.Annotations().AsList()))
+			}
+			checkSameStrings("dependent", actual, tt.expected, t)
+		})
+	}
+}
+
+func TestWalkBottomUp(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	lg, err := toGraph(stderr, []string{"apacheBin.meta_lic"}, []annotated{
+		{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"static"}},
+		{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"dynamic"}},
+		{"mitLib.meta_lic", "gplLib.meta_lic", []string{"static"}},
+		{"mitLib.meta_lic", "mplLib.meta_lic", []string{"static"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+
+	// visits records each visited node and path.
+	visits := func(walk func(VisitNode)) []string {
+		result := make([]string, 0)
+		walk(func(lg *LicenseGraph, tn *TargetNode, path TargetEdgePath) bool {
+			result = append(result, tn.Name()+" "+path.String())
+			return true
+		})
+		sort.Strings(result)
+		return result
+	}
+
+	t.Run("leaves", func(t *testing.T) {
+		actual := visits(func(visit VisitNode) { WalkBottomUp(lg, visit) })
+		checkSameStrings("visit", actual, []string{
+			"apacheBin.meta_lic [apacheBin.meta_lic -> gplLib.meta_lic]",
+			"apacheBin.meta_lic [apacheBin.meta_lic -> mitLib.meta_lic -> gplLib.meta_lic]",
+			"apacheBin.meta_lic [apacheBin.meta_lic -> mitLib.meta_lic -> mplLib.meta_lic]",
+			"gplLib.meta_lic []",
+			"mitLib.meta_lic [mitLib.meta_lic -> gplLib.meta_lic]",
+			"mitLib.meta_lic [mitLib.meta_lic -> mplLib.meta_lic]",
+			"mplLib.meta_lic []",
+		}, t)
+	})
+
+	t.Run("from", func(t *testing.T) {
+		actual := visits(func(visit VisitNode) { WalkBottomUpFrom(lg, visit, lg.TargetNode("mitLib.meta_lic")) })
+		checkSameStrings("visit", actual, []string{
+			"apacheBin.meta_lic [apacheBin.meta_lic -> mitLib.meta_lic]",
+			"mitLib.meta_lic []",
+		}, t)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		actual := make([]string, 0)
+		WalkBottomUpFrom(lg, func(lg *LicenseGraph, tn *TargetNode, path TargetEdgePath) bool {
+			actual = append(actual, tn.Name())
+			return tn.Name() != "mitLib.meta_lic"
+		}, lg.TargetNode("mplLib.meta_lic"))
+		checkSameStrings("visit", actual, []string{"mplLib.meta_lic", "mitLib.meta_lic"}, t)
+	})
+}
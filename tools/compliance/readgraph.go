@@ -18,12 +18,11 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"sort"
 	"strings"
 	"sync"
 
 	"android/soong/compliance/license_metadata_proto"
-
-	"google.golang.org/protobuf/encoding/prototext"
 )
 
 var (
@@ -31,6 +30,77 @@ var (
 	ConcurrentReaders = 5
 )
 
+// ReadOptions adjust how ReadLicenseGraphWithOptions reads a license graph.
+// The zero value reads like ReadLicenseGraph.
+type ReadOptions struct {
+	// Kinds optionally maps license kinds to license conditions like
+	// ReadLicenseGraphWithKindMap.
+	Kinds *LicenseKindMap
+
+	// CacheFile optionally names a cache of parsed license metadata to reuse
+	// between reads like ReadLicenseGraphCached.
+	CacheFile string
+
+	// BreakCycles removes the edge closing each dependency cycle, with a
+	// warning, instead of failing with a *CycleError. The removed edges are
+	// the same for every read of the same metadata.
+	BreakCycles bool
+
+	// TolerateReadErrors keeps reading after license metadata files fail to
+	// read or parse. The resulting graph has an empty placeholder node for
+	// each file that failed, and the error lists every failure as ReadErrors.
+	TolerateReadErrors bool
+}
+
+// ReadError describes a failure to read or parse a single license metadata
+// file.
+type ReadError struct {
+	// File identifies the license metadata file that failed.
+	File string
+
+	// Parent identifies the edge from the target depending on `File`, or nil
+	// when `File` is one of the root files.
+	Parent *TargetEdge
+
+	// Cause is the underlying error.
+	Cause error
+}
+
+// Error returns a string describing the failure.
+func (re *ReadError) Error() string {
+	if re.Parent == nil {
+		return re.Cause.Error()
+	}
+	return fmt.Sprintf("%s (dependency of %q)", re.Cause.Error(), re.Parent.e.target)
+}
+
+// Unwrap returns the underlying error.
+func (re *ReadError) Unwrap() error {
+	return re.Cause
+}
+
+// ReadErrors joins every failure from reading a license graph with
+// ReadOptions.TolerateReadErrors, ordered by file.
+type ReadErrors []*ReadError
+
+// Error returns the newline-separated failures.
+func (re ReadErrors) Error() string {
+	messages := make([]string, 0, len(re))
+	for _, e := range re {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the individual failures.
+func (re ReadErrors) Unwrap() []error {
+	result := make([]error, 0, len(re))
+	for _, e := range re {
+		result = append(result, e)
+	}
+	return result
+}
+
 // result describes the outcome of reading and parsing a single license metadata file.
 type result struct {
 	// file identifies the path to the license metadata file
@@ -43,7 +113,7 @@ type result struct {
 	edges []*dependencyEdge
 
 	// err is nil unless an error occurs
-	err error
+	err *ReadError
 }
 
 // receiver coordinates the tasks for reading and parsing license metadata files.
@@ -57,6 +127,15 @@ type receiver struct {
 	// stderr identifies the error output writer.
 	stderr io.Writer
 
+	// kinds optionally maps license kinds to license conditions.
+	kinds *LicenseKindMap
+
+	// cache optionally holds previously parsed license metadata.
+	cache *graphCache
+
+	// tolerant keeps reading after errors.
+	tolerant bool
+
 	// task provides a fixed-size task pool to limit concurrent open files etc.
 	task chan bool
 
@@ -71,6 +150,32 @@ type receiver struct {
 //
 // `files` become the root files of the graph for top-down walks of the graph.
 func ReadLicenseGraph(rootFS fs.FS, stderr io.Writer, files []string) (*LicenseGraph, error) {
+	return ReadLicenseGraphWithKindMap(rootFS, stderr, files, nil)
+}
+
+// ReadLicenseGraphWithKindMap reads and parses `files` and their dependencies
+// into a LicenseGraph deriving the license conditions of each target from its
+// license kinds using `kinds`.
+//
+// Targets with license kinds missing from `kinds` keep the license conditions
+// declared in their metadata. A nil `kinds` keeps all declared conditions.
+func ReadLicenseGraphWithKindMap(rootFS fs.FS, stderr io.Writer, files []string, kinds *LicenseKindMap) (*LicenseGraph, error) {
+	return ReadLicenseGraphWithOptions(rootFS, stderr, files, ReadOptions{Kinds: kinds})
+}
+
+// ReadLicenseGraphWithOptions reads and parses `files` and their dependencies
+// into a LicenseGraph as adjusted by `opts`.
+func ReadLicenseGraphWithOptions(rootFS fs.FS, stderr io.Writer, files []string, opts ReadOptions) (*LicenseGraph, error) {
+	if len(opts.CacheFile) > 0 {
+		return readLicenseGraphCached(rootFS, stderr, files, opts)
+	}
+	return readLicenseGraph(rootFS, stderr, files, opts, nil)
+}
+
+// readLicenseGraph reads and parses `files` and their dependencies into a
+// LicenseGraph as adjusted by `opts` using `cache` when not nil to avoid
+// re-parsing unchanged files.
+func readLicenseGraph(rootFS fs.FS, stderr io.Writer, files []string, opts ReadOptions, cache *graphCache) (*LicenseGraph, error) {
 	if len(files) == 0 {
 		return nil, fmt.Errorf("no license metadata to analyze")
 	}
@@ -80,20 +185,19 @@ func ReadLicenseGraph(rootFS fs.FS, stderr io.Writer, files []string) (*LicenseG
 
 	lg := newLicenseGraph()
 	for _, f := range files {
-		if strings.HasSuffix(f, ".meta_lic") {
-			lg.rootFiles = append(lg.rootFiles, f)
-		} else {
-			lg.rootFiles = append(lg.rootFiles, f+".meta_lic")
-		}
+		lg.rootFiles = append(lg.rootFiles, MetadataFileName(f))
 	}
 
 	recv := &receiver{
-		lg:      lg,
-		rootFS:  rootFS,
-		stderr:  stderr,
-		task:    make(chan bool, ConcurrentReaders),
-		results: make(chan *result, ConcurrentReaders),
-		wg:      sync.WaitGroup{},
+		lg:       lg,
+		rootFS:   rootFS,
+		stderr:   stderr,
+		kinds:    opts.Kinds,
+		cache:    cache,
+		tolerant: opts.TolerateReadErrors,
+		task:     make(chan bool, ConcurrentReaders),
+		results:  make(chan *result, ConcurrentReaders),
+		wg:       sync.WaitGroup{},
 	}
 	for i := 0; i < ConcurrentReaders; i++ {
 		recv.task <- true
@@ -109,7 +213,7 @@ func ReadLicenseGraph(rootFS fs.FS, stderr io.Writer, files []string) (*LicenseG
 
 		// schedule tasks to read the files
 		for _, f := range lg.rootFiles {
-			readFile(recv, f)
+			readFile(recv, f, nil)
 		}
 
 		// schedule a task to wait until finished and close the channel.
@@ -123,12 +227,19 @@ func ReadLicenseGraph(rootFS fs.FS, stderr io.Writer, files []string) (*LicenseG
 
 	// tasks to read license metadata files are scheduled; read and process results from channel
 	var err error
+	readErrors := make(ReadErrors, 0)
 	for recv.results != nil {
 		select {
 		case r, ok := <-recv.results:
 			if ok {
-				// handle errors by nil'ing ls, setting err, and clobbering results channel
-				if r.err != nil {
+				// when tolerant, collect errors and substitute placeholders for missing targets
+				if r.err != nil && recv.tolerant {
+					readErrors = append(readErrors, r.err)
+					if r.target == nil {
+						r.target = &TargetNode{name: r.file, placeholder: true}
+					}
+				} else if r.err != nil {
+					// handle errors by nil'ing ls, setting err, and clobbering results channel
 					err = r.err
 					fmt.Fprintf(recv.stderr, "%s\n", err.Error())
 					lg = nil
@@ -150,8 +261,26 @@ func ReadLicenseGraph(rootFS fs.FS, stderr io.Writer, files []string) (*LicenseG
 		}
 	}
 
-	return lg, err
+	if err != nil {
+		return lg, err
+	}
 
+	// resolving conditions recurses through the graph and requires no cycles
+	err = lg.checkCycles(stderr, opts.BreakCycles)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err.Error())
+		return nil, err
+	}
+	if len(readErrors) > 0 {
+		sort.Slice(readErrors, func(i, j int) bool {
+			if readErrors[i].File == readErrors[j].File {
+				return readErrors[i].Error() < readErrors[j].Error()
+			}
+			return readErrors[i].File < readErrors[j].File
+		})
+		return lg, readErrors
+	}
+	return lg, nil
 }
 
 // targetNode contains the license metadata for a node in the license graph.
@@ -160,6 +289,9 @@ type targetNode struct {
 
 	// name is the path to the metadata file
 	name string
+
+	// placeholder is true when the metadata file could not be read
+	placeholder bool
 }
 
 // dependencyEdge describes a single edge in the license graph.
@@ -180,11 +312,16 @@ type dependencyEdge struct {
 }
 
 // addDependencies converts the proto AnnotatedDependencies into `edges`
+//
+// Dependencies without names get skipped and reported as an error after
+// converting the others.
 func addDependencies(edges *[]*dependencyEdge, target string, dependencies []*license_metadata_proto.AnnotatedDependency) error {
+	var err error
 	for _, ad := range dependencies {
 		dependency := ad.GetFile()
 		if len(dependency) == 0 {
-			return fmt.Errorf("missing dependency name")
+			err = fmt.Errorf("missing dependency name")
+			continue
 		}
 		annotations := newEdgeAnnotations()
 		for _, a := range ad.Annotations {
@@ -195,47 +332,52 @@ func addDependencies(edges *[]*dependencyEdge, target string, dependencies []*li
 		}
 		*edges = append(*edges, &dependencyEdge{target, dependency, annotations})
 	}
-	return nil
+	return err
 }
 
 // readFile is a task to read and parse a single license metadata file, and to schedule
 // additional tasks for reading and parsing dependencies as necessary.
-func readFile(recv *receiver, file string) {
+//
+// `parent` is the edge from the target depending on `file` or nil for root files.
+func readFile(recv *receiver, file string, parent *dependencyEdge) {
 	recv.wg.Add(1)
 	<-recv.task
 	go func() {
-		f, err := recv.rootFS.Open(file)
-		if err != nil {
-			recv.results <- &result{file, nil, nil, fmt.Errorf("error opening license metadata %q: %w", file, err)}
-			return
+		var tn *TargetNode
+		var err error
+		if recv.cache != nil {
+			tn, err = recv.cache.readTarget(recv.rootFS, file)
+		} else {
+			tn, err = readTarget(recv.rootFS, file)
 		}
-
-		// read the file
-		data, err := io.ReadAll(f)
 		if err != nil {
-			recv.results <- &result{file, nil, nil, fmt.Errorf("error reading license metadata %q: %w", file, err)}
+			recv.results <- &result{file, nil, nil, recv.readError(file, parent, err)}
+			if recv.tolerant {
+				recv.task <- true
+				recv.wg.Done()
+			}
 			return
 		}
 
-		tn := &TargetNode{name: file}
-
-		err = prototext.Unmarshal(data, &tn.proto)
-		if err != nil {
-			recv.results <- &result{file, nil, nil, fmt.Errorf("error license metadata %q: %w", file, err)}
-			return
+		if recv.kinds != nil {
+			tn.proto.LicenseConditions = recv.kinds.licenseConditions(tn.proto.LicenseKinds, tn.proto.LicenseConditions)
 		}
 
 		edges := []*dependencyEdge{}
+		var depErr *ReadError
 		err = addDependencies(&edges, file, tn.proto.Deps)
 		if err != nil {
-			recv.results <- &result{file, nil, nil, fmt.Errorf("error license metadata dependency %q: %w", file, err)}
-			return
+			depErr = recv.readError(file, parent, fmt.Errorf("error license metadata dependency %q: %w", file, err))
+			if !recv.tolerant {
+				recv.results <- &result{file, nil, nil, depErr}
+				return
+			}
 		}
 		tn.proto.Deps = []*license_metadata_proto.AnnotatedDependency{}
 
 		// send result for this file and release task before scheduling dependencies,
 		// but do not signal done to WaitGroup until dependencies are scheduled.
-		recv.results <- &result{file, tn, edges, nil}
+		recv.results <- &result{file, tn, edges, depErr}
 		recv.task <- true
 
 		// schedule tasks as necessary to read dependencies
@@ -249,7 +391,7 @@ func readFile(recv *receiver, file string) {
 			recv.lg.mu.Unlock()
 			// schedule task to read dependency file outside critical section
 			if !alreadyScheduled {
-				readFile(recv, e.dependency)
+				readFile(recv, e.dependency, e)
 			}
 		}
 
@@ -257,3 +399,44 @@ func readFile(recv *receiver, file string) {
 		recv.wg.Done()
 	}()
 }
+
+// readError returns a *ReadError for `file` depended on by `parent` failing with `err`.
+func (recv *receiver) readError(file string, parent *dependencyEdge, err error) *ReadError {
+	re := &ReadError{File: file, Cause: err}
+	if parent != nil {
+		re.Parent = &TargetEdge{recv.lg, parent}
+	}
+	return re
+}
+
+// readData returns the content of license metadata `file` in `rootFS`.
+func readData(rootFS fs.FS, file string) ([]byte, error) {
+	f, err := rootFS.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening license metadata %q: %w", file, err)
+	}
+	defer f.Close()
+
+	// read the file
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading license metadata %q: %w", file, err)
+	}
+	return data, nil
+}
+
+// readTarget reads and parses license metadata `file` in `rootFS` into a target node.
+func readTarget(rootFS fs.FS, file string) (*TargetNode, error) {
+	data, err := readData(rootFS, file)
+	if err != nil {
+		return nil, err
+	}
+
+	tn := &TargetNode{name: file}
+
+	err = UnmarshalLicenseMetadata(data, &tn.proto)
+	if err != nil {
+		return nil, fmt.Errorf("error license metadata %q: %w", file, err)
+	}
+	return tn, nil
+}
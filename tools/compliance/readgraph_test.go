@@ -16,6 +16,7 @@ package compliance
 
 import (
 	"bytes"
+	"errors"
 	"sort"
 	"strings"
 	"testing"
@@ -137,3 +138,86 @@ func TestReadLicenseGraph(t *testing.T) {
 		})
 	}
 }
+
+func TestReadLicenseGraph_readErrors(t *testing.T) {
+	fs := &testFS{
+		"apex.meta_lic": []byte(AOSP + "deps: {\n  file: \"app.meta_lic\"\n}\ndeps: {\n  file: \"bin.meta_lic\"\n  annotations: \"static\"\n}\n"),
+		"app.meta_lic":  []byte(AOSP + "deps: {\n  file: \"lib.meta_lic\"\n}\ndeps: {\n  annotations: \"static\"\n}\n"),
+		"bin.meta_lic":  []byte("package_name: \"Android\n"),
+		"lib.meta_lic":  []byte(AOSP),
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		lg, err := ReadLicenseGraph(fs, &bytes.Buffer{}, []string{"bin.meta_lic"})
+		if lg != nil {
+			t.Errorf("unexpected license graph: got %v, want nil", lg)
+		}
+		var re *ReadError
+		if !errors.As(err, &re) {
+			t.Fatalf("unexpected error: got %v, want *ReadError", err)
+		}
+		if re.File != "bin.meta_lic" || re.Parent != nil || re.Cause == nil {
+			t.Errorf("unexpected read error: got file %q parent %v cause %v, want bin.meta_lic with no parent", re.File, re.Parent, re.Cause)
+		}
+
+		binFS := &testFS{
+			"top.meta_lic": []byte(AOSP + "deps: {\n  file: \"bin.meta_lic\"\n  annotations: \"static\"\n}\n"),
+			"bin.meta_lic": (*fs)["bin.meta_lic"],
+		}
+		_, err = ReadLicenseGraph(binFS, &bytes.Buffer{}, []string{"top.meta_lic"})
+		if !errors.As(err, &re) {
+			t.Fatalf("unexpected error: got %v, want *ReadError", err)
+		}
+		if re.File != "bin.meta_lic" || re.Parent == nil {
+			t.Fatalf("unexpected read error: got file %q parent %v, want bin.meta_lic with parent", re.File, re.Parent)
+		}
+		if re.Parent.Target().Name() != "top.meta_lic" || !re.Parent.Annotations().HasAnnotation("static") {
+			t.Errorf("unexpected parent edge: got %s -> %s, want static top.meta_lic -> bin.meta_lic", re.Parent.Target().Name(), re.Parent.Dependency().Name())
+		}
+		if !strings.Contains(err.Error(), `(dependency of "top.meta_lic")`) {
+			t.Errorf("unexpected error string: got %q, want parent named", err.Error())
+		}
+	})
+
+	t.Run("tolerant", func(t *testing.T) {
+		stderr := &bytes.Buffer{}
+		lg, err := ReadLicenseGraphWithOptions(fs, stderr, []string{"apex.meta_lic", "missing.meta_lic"}, ReadOptions{TolerateReadErrors: true})
+		if lg == nil {
+			t.Fatalf("missing license graph: got nil, want partial license graph")
+		}
+		var res ReadErrors
+		if !errors.As(err, &res) {
+			t.Fatalf("unexpected error: got %v, want ReadErrors", err)
+		}
+		actualErrors := make([]string, 0, len(res))
+		for _, re := range res {
+			parent := ""
+			if re.Parent != nil {
+				parent = re.Parent.Target().Name()
+			}
+			actualErrors = append(actualErrors, re.File+" from "+parent)
+		}
+		checkSameStrings("error", actualErrors, []string{"app.meta_lic from apex.meta_lic", "bin.meta_lic from apex.meta_lic", "missing.meta_lic from "}, t)
+		if len(strings.Split(err.Error(), "\n")) != 3 {
+			t.Errorf("unexpected joined error: got %q, want 3 lines", err.Error())
+		}
+
+		actualTargets := make([]string, 0)
+		for _, tn := range lg.Targets() {
+			name := tn.Name()
+			if tn.IsPlaceholder() {
+				name += " (placeholder)"
+			}
+			actualTargets = append(actualTargets, name)
+		}
+		sort.Strings(actualTargets)
+		checkSameStrings("target", actualTargets, []string{"apex.meta_lic", "app.meta_lic", "bin.meta_lic (placeholder)", "lib.meta_lic", "missing.meta_lic (placeholder)"}, t)
+
+		actualEdges := make([]string, 0)
+		for _, e := range lg.Edges() {
+			actualEdges = append(actualEdges, e.Target().Name()+" -> "+e.Dependency().Name())
+		}
+		sort.Strings(actualEdges)
+		checkSameStrings("edge", actualEdges, []string{"apex.meta_lic -> app.meta_lic", "apex.meta_lic -> bin.meta_lic", "app.meta_lic -> lib.meta_lic"}, t)
+	})
+}
@@ -0,0 +1,445 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// SPDXVersion identifies the version of the SPDX specification implemented by SPDXDocument.
+	SPDXVersion = "SPDX-2.3"
+
+	// spdxNoAssertion is the SPDX value for information not provided.
+	spdxNoAssertion = "NOASSERTION"
+
+	// spdxKindPrefix identifies license kinds named by an SPDX license identifier.
+	spdxKindPrefix = "SPDX-license-identifier-"
+)
+
+// SPDXDocument describes a software bill of materials for a license graph
+// per the SPDX 2.3 specification.
+//
+// Each target node becomes a package, and each edge becomes a relationship
+// between packages.
+type SPDXDocument struct {
+	SPDXVersion       string                 `json:"spdxVersion"`
+	DataLicense       string                 `json:"dataLicense"`
+	SPDXID            string                 `json:"SPDXID"`
+	Name              string                 `json:"name"`
+	DocumentNamespace string                 `json:"documentNamespace"`
+	CreationInfo      SPDXCreationInfo       `json:"creationInfo"`
+	Packages          []SPDXPackage          `json:"packages"`
+	Files             []SPDXFile             `json:"files,omitempty"`
+	Relationships     []SPDXRelationship     `json:"relationships"`
+	ExtractedLicenses []SPDXExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+// SPDXCreationInfo describes who created the document and when.
+type SPDXCreationInfo struct {
+	Creators []string `json:"creators"`
+	Created  string   `json:"created"`
+}
+
+// SPDXPackage describes a single target node as an SPDX package.
+type SPDXPackage struct {
+	SPDXID           string `json:"SPDXID"`
+	Name             string `json:"name"`
+	DownloadLocation string `json:"downloadLocation"`
+	FilesAnalyzed    bool   `json:"filesAnalyzed"`
+	SourceInfo       string `json:"sourceInfo,omitempty"`
+	LicenseConcluded string `json:"licenseConcluded"`
+	LicenseDeclared  string `json:"licenseDeclared"`
+	LicenseComments  string `json:"licenseComments,omitempty"`
+	CopyrightText    string `json:"copyrightText"`
+	Comment          string `json:"comment,omitempty"`
+}
+
+// SPDXFile describes a file installed by a target node.
+type SPDXFile struct {
+	SPDXID    string         `json:"SPDXID"`
+	FileName  string         `json:"fileName"`
+	Checksums []SPDXChecksum `json:"checksums"`
+}
+
+// SPDXChecksum describes the digest of a file's content.
+type SPDXChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+// SPDXRelationship describes how 2 elements of the document relate.
+//
+// i.e. `Element` has relationship `Type` to `RelatedElement`.
+type SPDXRelationship struct {
+	Element        string `json:"spdxElementId"`
+	Type           string `json:"relationshipType"`
+	RelatedElement string `json:"relatedSpdxElement"`
+}
+
+// SPDXExtractedLicense describes a license kind without an SPDX license identifier.
+type SPDXExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	ExtractedText string `json:"extractedText"`
+	Name          string `json:"name"`
+}
+
+// NewSPDXDocument converts license graph `lg` into an SPDX document named
+// `name` with unique URI `namespace` created by `creator` at time `created`.
+//
+// The checksums of installed files get computed from `rootFS`. SPDX requires
+// a checksum for every file so installed files missing from `rootFS` get
+// listed as packages instead.
+func NewSPDXDocument(rootFS fs.FS, lg *LicenseGraph, name, namespace, creator string, created time.Time) *SPDXDocument {
+	doc := &SPDXDocument{
+		SPDXVersion:       SPDXVersion,
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              name,
+		DocumentNamespace: namespace,
+		CreationInfo: SPDXCreationInfo{
+			Creators: []string{creator},
+			Created:  created.UTC().Format(time.RFC3339),
+		},
+		Packages:      []SPDXPackage{},
+		Relationships: []SPDXRelationship{},
+	}
+
+	ids := make(spdxIDs)
+
+	// packages maps target names to their SPDX identifiers.
+	packages := make(map[string]string)
+
+	targets := lg.Targets()
+	sort.Sort(targets)
+
+	// extracted maps non-SPDX license kinds to their LicenseRef identifiers.
+	extracted := make(map[string]string)
+
+	// contains lists the package to file relationships in the order discovered.
+	contains := make([]SPDXRelationship, 0)
+
+	// files maps installed paths to their SPDX identifiers.
+	files := make(map[string]string)
+
+	// missing lists the packages describing installed files not found.
+	missing := make([]SPDXPackage, 0)
+
+	for _, tn := range targets {
+		pkg := SPDXPackage{
+			SPDXID:           ids.unique("SPDXRef-", tn.name),
+			Name:             tn.name,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+		}
+		projects := tn.Projects()
+		if len(projects) > 0 {
+			sort.Strings(projects)
+			pkg.SourceInfo = "built from project(s): " + strings.Join(projects, " ")
+		}
+		texts := tn.LicenseTexts()
+		if len(texts) > 0 {
+			sort.Strings(texts)
+			pkg.LicenseComments = "license text(s): " + strings.Join(texts, " ")
+		}
+		kinds := tn.LicenseKinds()
+		sort.Strings(kinds)
+		expression := make([]string, 0, len(kinds))
+		seen := make(map[string]bool)
+		for _, kind := range kinds {
+			var id string
+			if expression, ok := spdxLicenseExpression(kind); ok {
+				id = expression
+			} else if ref, ok := extracted[kind]; ok {
+				id = ref
+			} else {
+				id = ids.unique("LicenseRef-", kind)
+				extracted[kind] = id
+				doc.ExtractedLicenses = append(doc.ExtractedLicenses, SPDXExtractedLicense{
+					LicenseID:     id,
+					ExtractedText: spdxNoAssertion,
+					Name:          kind,
+				})
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			expression = append(expression, id)
+		}
+		if len(expression) > 0 {
+			pkg.LicenseDeclared = strings.Join(expression, " AND ")
+		} else {
+			pkg.LicenseDeclared = spdxNoAssertion
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		packages[tn.name] = pkg.SPDXID
+
+		installed := tn.Installed()
+		sort.Strings(installed)
+		for _, path := range installed {
+			fileID, ok := files[path]
+			if !ok {
+				fileID = ids.unique("SPDXRef-File-", path)
+				files[path] = fileID
+				if f, err := newSPDXFile(rootFS, fileID, path); err == nil {
+					doc.Files = append(doc.Files, f)
+				} else {
+					missing = append(missing, SPDXPackage{
+						SPDXID:           fileID,
+						Name:             path,
+						DownloadLocation: spdxNoAssertion,
+						LicenseConcluded: spdxNoAssertion,
+						LicenseDeclared:  spdxNoAssertion,
+						CopyrightText:    spdxNoAssertion,
+						Comment:          "installed file not found at time of analysis; checksum not computed",
+					})
+				}
+			}
+			contains = append(contains, SPDXRelationship{pkg.SPDXID, "CONTAINS", fileID})
+		}
+	}
+
+	doc.Packages = append(doc.Packages, missing...)
+
+	// the document describes the root targets
+	roots := append([]string{}, lg.rootFiles...)
+	sort.Strings(roots)
+	for _, r := range roots {
+		doc.Relationships = append(doc.Relationships, SPDXRelationship{doc.SPDXID, "DESCRIBES", packages[r]})
+	}
+
+	// each edge relates the target and dependency packages
+	edges := lg.Edges()
+	sort.Sort(edges)
+	related := make(map[SPDXRelationship]bool)
+	for _, e := range edges {
+		r := spdxEdgeRelationship(packages, e)
+		if related[r] {
+			continue
+		}
+		related[r] = true
+		doc.Relationships = append(doc.Relationships, r)
+	}
+	doc.Relationships = append(doc.Relationships, contains...)
+
+	return doc
+}
+
+// WriteJSON outputs the document in the SPDX JSON format.
+func (doc *SPDXDocument) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// WriteTagValue outputs the document in the SPDX tag-value format.
+func (doc *SPDXDocument) WriteTagValue(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "SPDXVersion: %s\n", doc.SPDXVersion)
+	fmt.Fprintf(&sb, "DataLicense: %s\n", doc.DataLicense)
+	fmt.Fprintf(&sb, "SPDXID: %s\n", doc.SPDXID)
+	fmt.Fprintf(&sb, "DocumentName: %s\n", doc.Name)
+	fmt.Fprintf(&sb, "DocumentNamespace: %s\n", doc.DocumentNamespace)
+	for _, creator := range doc.CreationInfo.Creators {
+		fmt.Fprintf(&sb, "Creator: %s\n", creator)
+	}
+	fmt.Fprintf(&sb, "Created: %s\n", doc.CreationInfo.Created)
+	for _, pkg := range doc.Packages {
+		fmt.Fprintf(&sb, "\nPackageName: %s\n", pkg.Name)
+		fmt.Fprintf(&sb, "SPDXID: %s\n", pkg.SPDXID)
+		fmt.Fprintf(&sb, "PackageDownloadLocation: %s\n", pkg.DownloadLocation)
+		fmt.Fprintf(&sb, "FilesAnalyzed: %t\n", pkg.FilesAnalyzed)
+		if len(pkg.SourceInfo) > 0 {
+			fmt.Fprintf(&sb, "PackageSourceInfo: <text>%s</text>\n", pkg.SourceInfo)
+		}
+		fmt.Fprintf(&sb, "PackageLicenseConcluded: %s\n", pkg.LicenseConcluded)
+		fmt.Fprintf(&sb, "PackageLicenseDeclared: %s\n", pkg.LicenseDeclared)
+		if len(pkg.LicenseComments) > 0 {
+			fmt.Fprintf(&sb, "PackageLicenseComments: <text>%s</text>\n", pkg.LicenseComments)
+		}
+		fmt.Fprintf(&sb, "PackageCopyrightText: %s\n", pkg.CopyrightText)
+		if len(pkg.Comment) > 0 {
+			fmt.Fprintf(&sb, "PackageComment: <text>%s</text>\n", pkg.Comment)
+		}
+	}
+	for _, f := range doc.Files {
+		fmt.Fprintf(&sb, "\nFileName: %s\n", f.FileName)
+		fmt.Fprintf(&sb, "SPDXID: %s\n", f.SPDXID)
+		for _, c := range f.Checksums {
+			fmt.Fprintf(&sb, "FileChecksum: %s: %s\n", c.Algorithm, c.ChecksumValue)
+		}
+	}
+	if len(doc.Relationships) > 0 {
+		fmt.Fprintf(&sb, "\n")
+	}
+	for _, r := range doc.Relationships {
+		fmt.Fprintf(&sb, "Relationship: %s %s %s\n", r.Element, r.Type, r.RelatedElement)
+	}
+	for _, l := range doc.ExtractedLicenses {
+		fmt.Fprintf(&sb, "\nLicenseID: %s\n", l.LicenseID)
+		fmt.Fprintf(&sb, "ExtractedText: <text>%s</text>\n", l.ExtractedText)
+		fmt.Fprintf(&sb, "LicenseName: %s\n", l.Name)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// spdxEdgeRelationship returns the SPDX relationship implied by the
+// annotations on edge `e` given the identifiers of the `packages`.
+func spdxEdgeRelationship(packages map[string]string, e TargetEdge) SPDXRelationship {
+	target := packages[e.e.target]
+	dependency := packages[e.e.dependency]
+	if e.e.annotations.HasAnnotation("toolchain") {
+		return SPDXRelationship{dependency, "BUILD_TOOL_OF", target}
+	}
+	if edgeIsDynamicLink(e) {
+		return SPDXRelationship{target, "DYNAMIC_LINK", dependency}
+	}
+	if e.Target().IsContainer() {
+		return SPDXRelationship{target, "CONTAINS", dependency}
+	}
+	return SPDXRelationship{target, "STATIC_LINK", dependency}
+}
+
+// newSPDXFile describes installed file `path` computing the checksum from
+// `rootFS`.
+func newSPDXFile(rootFS fs.FS, id, path string) (SPDXFile, error) {
+	f, err := rootFS.Open(path)
+	if err != nil {
+		return SPDXFile{}, err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return SPDXFile{}, err
+	}
+	return SPDXFile{
+		SPDXID:    id,
+		FileName:  path,
+		Checksums: []SPDXChecksum{{"SHA1", fmt.Sprintf("%x", h.Sum(nil))}},
+	}, nil
+}
+
+// spdxLicenseExpression returns the SPDX license expression for license
+// `kind` when the kind names a license on the SPDX license list.
+//
+// Kinds naming deprecated identifiers map to their replacements. e.g.
+// SPDX-license-identifier-GPL-2.0-with-classpath-exception becomes
+// GPL-2.0-only WITH Classpath-exception-2.0. Kinds naming a family of
+// licenses without a version, e.g. SPDX-license-identifier-GPL, have none.
+func spdxLicenseExpression(kind string) (string, bool) {
+	if !strings.HasPrefix(kind, spdxKindPrefix) {
+		return "", false
+	}
+	id := strings.TrimPrefix(kind, spdxKindPrefix)
+	if expression, ok := spdxDeprecatedLicenseIDs[id]; ok {
+		return expression, true
+	}
+	if spdxLicenseIDs[id] {
+		return id, true
+	}
+	return "", false
+}
+
+// spdxDeprecatedLicenseIDs maps deprecated SPDX license identifiers used
+// by license kinds to equivalent license expressions.
+var spdxDeprecatedLicenseIDs = map[string]string{
+	"AGPL-1.0":                         "AGPL-1.0-only",
+	"AGPL-3.0":                         "AGPL-3.0-only",
+	"GPL-1.0":                          "GPL-1.0-only",
+	"GPL-1.0+":                         "GPL-1.0-or-later",
+	"GPL-2.0":                          "GPL-2.0-only",
+	"GPL-2.0+":                         "GPL-2.0-or-later",
+	"GPL-2.0-with-autoconf-exception":  "GPL-2.0-only WITH Autoconf-exception-2.0",
+	"GPL-2.0-with-bison-exception":     "GPL-2.0-only WITH Bison-exception-2.2",
+	"GPL-2.0-with-classpath-exception": "GPL-2.0-only WITH Classpath-exception-2.0",
+	"GPL-2.0-with-font-exception":      "GPL-2.0-only WITH Font-exception-2.0",
+	"GPL-2.0-with-GCC-exception":       "GPL-2.0-only WITH GCC-exception-2.0",
+	"GPL-3.0":                          "GPL-3.0-only",
+	"GPL-3.0+":                         "GPL-3.0-or-later",
+	"GPL-3.0-with-autoconf-exception":  "GPL-3.0-only WITH Autoconf-exception-3.0",
+	"GPL-3.0-with-GCC-exception":       "GPL-3.0-only WITH GCC-exception-3.1",
+	"LGPL-2.0":                         "LGPL-2.0-only",
+	"LGPL-2.0+":                        "LGPL-2.0-or-later",
+	"LGPL-2.1":                         "LGPL-2.1-only",
+	"LGPL-2.1+":                        "LGPL-2.1-or-later",
+	"LGPL-3.0":                         "LGPL-3.0-only",
+	"LGPL-3.0+":                        "LGPL-3.0-or-later",
+}
+
+// spdxLicenseIDs lists the current SPDX license identifiers used by license
+// kinds.
+var spdxLicenseIDs = map[string]bool{
+	"0BSD": true, "AFL-1.1": true, "AFL-1.2": true, "AFL-2.0": true, "AFL-2.1": true, "AFL-3.0": true,
+	"AGPL-1.0-only": true, "AGPL-1.0-or-later": true, "AGPL-3.0-only": true, "AGPL-3.0-or-later": true,
+	"APSL-1.1": true, "APSL-2.0": true, "Apache-1.0": true, "Apache-1.1": true, "Apache-2.0": true,
+	"Artistic-1.0": true, "Artistic-1.0-Perl": true, "Artistic-1.0-cl8": true, "Artistic-2.0": true,
+	"BSD-1-Clause": true, "BSD-2-Clause": true, "BSD-2-Clause-Patent": true, "BSD-3-Clause": true,
+	"BSD-3-Clause-Attribution": true, "BSD-3-Clause-Clear": true, "BSD-3-Clause-LBNL": true,
+	"BSD-3-Clause-No-Nuclear-License": true, "BSD-3-Clause-No-Nuclear-License-2014": true,
+	"BSD-3-Clause-No-Nuclear-Warranty": true, "BSD-3-Clause-Open-MPI": true, "BSD-4-Clause": true,
+	"BSD-4-Clause-UC": true, "BSD-Protection": true, "BSD-Source-Code": true, "BSL-1.0": true, "Beerware": true,
+	"CC-BY-1.0": true, "CC-BY-2.0": true, "CC-BY-2.5": true, "CC-BY-3.0": true, "CC-BY-4.0": true,
+	"CC-BY-NC-1.0": true, "CC-BY-NC-2.0": true, "CC-BY-NC-2.5": true, "CC-BY-NC-3.0": true, "CC-BY-NC-4.0": true,
+	"CC-BY-NC-ND-1.0": true, "CC-BY-NC-ND-2.0": true, "CC-BY-NC-ND-2.5": true, "CC-BY-NC-ND-3.0": true, "CC-BY-NC-ND-4.0": true,
+	"CC-BY-NC-SA-1.0": true, "CC-BY-NC-SA-2.0": true, "CC-BY-NC-SA-2.5": true, "CC-BY-NC-SA-3.0": true, "CC-BY-NC-SA-4.0": true,
+	"CC-BY-ND-1.0": true, "CC-BY-ND-2.0": true, "CC-BY-ND-2.5": true, "CC-BY-ND-3.0": true, "CC-BY-ND-4.0": true,
+	"CC-BY-SA-1.0": true, "CC-BY-SA-2.0": true, "CC-BY-SA-2.5": true, "CC-BY-SA-3.0": true, "CC-BY-SA-4.0": true,
+	"CC0-1.0": true, "CDDL-1.0": true, "CDDL-1.1": true, "CPAL-1.0": true, "CPL-1.0": true,
+	"EPL-1.0": true, "EPL-2.0": true, "EUPL-1.0": true, "EUPL-1.1": true, "EUPL-1.2": true, "FSFAP": true, "FTL": true,
+	"GPL-1.0-only": true, "GPL-1.0-or-later": true, "GPL-2.0-only": true, "GPL-2.0-or-later": true,
+	"GPL-3.0-only": true, "GPL-3.0-or-later": true, "HPND": true, "ICU": true, "IJG": true, "ISC": true, "JSON": true,
+	"LGPL-2.0-only": true, "LGPL-2.0-or-later": true, "LGPL-2.1-only": true, "LGPL-2.1-or-later": true,
+	"LGPL-3.0-only": true, "LGPL-3.0-or-later": true, "LGPLLR": true, "LPL-1.02": true,
+	"MIT": true, "MIT-0": true, "MIT-CMU": true, "MIT-advertising": true, "MIT-enna": true, "MIT-feh": true, "MITNFA": true,
+	"MPL-1.0": true, "MPL-1.1": true, "MPL-2.0": true, "MPL-2.0-no-copyleft-exception": true, "MS-PL": true, "MS-RL": true,
+	"NCSA": true, "NIST-PD": true, "OFL-1.0": true, "OFL-1.1": true, "OpenSSL": true, "PDDL-1.0": true,
+	"PSF-2.0": true, "Python-2.0": true, "Ruby": true, "SGI-B-2.0": true, "SISSL": true, "SSPL-1.0": true,
+	"Sleepycat": true, "UPL-1.0": true, "Unicode-3.0": true, "Unicode-DFS-2015": true, "Unicode-DFS-2016": true,
+	"Unlicense": true, "W3C": true, "W3C-19980720": true, "W3C-20150513": true, "WTFPL": true, "X11": true,
+	"Xnet": true, "ZPL-1.1": true, "ZPL-2.0": true, "ZPL-2.1": true, "Zend-2.0": true, "Zlib": true, "libtiff": true,
+}
+
+// spdxIDs records the SPDX identifiers already assigned within a document.
+type spdxIDs map[string]bool
+
+// unique returns a new identifier starting with `prefix` for the element
+// `name`.
+//
+// SPDX identifiers allow only letters, numbers, `.` and `-` so other
+// characters become `-` with a numeric suffix to break any ties.
+func (ids spdxIDs) unique(prefix, name string) string {
+	id := prefix + strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+	result := id
+	for i := 1; ids[result]; i++ {
+		result = fmt.Sprintf("%s-%d", id, i)
+	}
+	ids[result] = true
+	return result
+}
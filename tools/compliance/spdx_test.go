@@ -0,0 +1,204 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSPDXDocument(t *testing.T) {
+	tests := []struct {
+		name                  string
+		roots                 []string
+		edges                 []annotated
+		expectedPackages      []string
+		expectedDeclared      []string
+		expectedRelationships []string
+		expectedExtracted     []string
+	}{
+		{
+			name:                  "singleton",
+			roots:                 []string{"apacheBin.meta_lic"},
+			expectedPackages:      []string{"SPDXRef-apacheBin.meta-lic"},
+			expectedDeclared:      []string{"Apache-2.0"},
+			expectedRelationships: []string{"SPDXRef-DOCUMENT DESCRIBES SPDXRef-apacheBin.meta-lic"},
+		},
+		{
+			name:  "static",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{
+				{"apacheBin.meta_lic", "mitLib.meta_lic", []string{"static"}},
+			},
+			expectedPackages: []string{"SPDXRef-apacheBin.meta-lic", "SPDXRef-mitLib.meta-lic"},
+			expectedDeclared: []string{"Apache-2.0", "MIT"},
+			expectedRelationships: []string{
+				"SPDXRef-DOCUMENT DESCRIBES SPDXRef-apacheBin.meta-lic",
+				"SPDXRef-apacheBin.meta-lic STATIC_LINK SPDXRef-mitLib.meta-lic",
+			},
+		},
+		{
+			name:  "dynamic",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{
+				{"apacheBin.meta_lic", "gplLib.meta_lic", []string{"dynamic"}},
+			},
+			expectedPackages: []string{"SPDXRef-apacheBin.meta-lic", "SPDXRef-gplLib.meta-lic"},
+			expectedDeclared: []string{"Apache-2.0", "GPL-2.0-only"},
+			expectedRelationships: []string{
+				"SPDXRef-DOCUMENT DESCRIBES SPDXRef-apacheBin.meta-lic",
+				"SPDXRef-apacheBin.meta-lic DYNAMIC_LINK SPDXRef-gplLib.meta-lic",
+			},
+		},
+		{
+			name:  "toolchain",
+			roots: []string{"apacheBin.meta_lic"},
+			edges: []annotated{
+				{"apacheBin.meta_lic", "gplBin.meta_lic", []string{"toolchain"}},
+			},
+			expectedPackages: []string{"SPDXRef-apacheBin.meta-lic", "SPDXRef-gplBin.meta-lic"},
+			expectedDeclared: []string{"Apache-2.0", "GPL-2.0-only"},
+			expectedRelationships: []string{
+				"SPDXRef-DOCUMENT DESCRIBES SPDXRef-apacheBin.meta-lic",
+				"SPDXRef-gplBin.meta-lic BUILD_TOOL_OF SPDXRef-apacheBin.meta-lic",
+			},
+		},
+		{
+			name:  "container",
+			roots: []string{"apacheContainer.meta_lic"},
+			edges: []annotated{
+				{"apacheContainer.meta_lic", "proprietary.meta_lic", []string{"static"}},
+			},
+			expectedPackages: []string{"SPDXRef-apacheContainer.meta-lic", "SPDXRef-proprietary.meta-lic"},
+			expectedDeclared: []string{"Apache-2.0", "LicenseRef-legacy-proprietary"},
+			expectedRelationships: []string{
+				"SPDXRef-DOCUMENT DESCRIBES SPDXRef-apacheContainer.meta-lic",
+				"SPDXRef-apacheContainer.meta-lic CONTAINS SPDXRef-proprietary.meta-lic",
+			},
+			expectedExtracted: []string{"LicenseRef-legacy-proprietary"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stderr := &bytes.Buffer{}
+			lg, err := toGraph(stderr, tt.roots, tt.edges)
+			if err != nil {
+				t.Errorf("unexpected test data error: got %s, want no error", err)
+				return
+			}
+			doc := NewSPDXDocument(&testFS{}, lg, "test", "https://example.com/test", "Tool: test", time.Unix(0, 0))
+			if doc.SPDXVersion != SPDXVersion {
+				t.Errorf("unexpected version: got %q, want %q", doc.SPDXVersion, SPDXVersion)
+			}
+			if doc.CreationInfo.Created != "1970-01-01T00:00:00Z" {
+				t.Errorf("unexpected creation time: got %q, want %q", doc.CreationInfo.Created, "1970-01-01T00:00:00Z")
+			}
+			actualPackages := make([]string, 0, len(doc.Packages))
+			actualDeclared := make([]string, 0, len(doc.Packages))
+			for _, pkg := range doc.Packages {
+				actualPackages = append(actualPackages, pkg.SPDXID)
+				actualDeclared = append(actualDeclared, pkg.LicenseDeclared)
+			}
+			checkSameStrings("package", actualPackages, tt.expectedPackages, t)
+			checkSameStrings("declared license", actualDeclared, tt.expectedDeclared, t)
+			actualRelationships := make([]string, 0, len(doc.Relationships))
+			for _, r := range doc.Relationships {
+				actualRelationships = append(actualRelationships, r.Element+" "+r.Type+" "+r.RelatedElement)
+			}
+			checkSameStrings("relationship", actualRelationships, tt.expectedRelationships, t)
+			actualExtracted := make([]string, 0, len(doc.ExtractedLicenses))
+			for _, l := range doc.ExtractedLicenses {
+				actualExtracted = append(actualExtracted, l.LicenseID)
+			}
+			checkSameStrings("extracted license", actualExtracted, tt.expectedExtracted, t)
+		})
+	}
+}
+
+func TestSPDXDocument_Write(t *testing.T) {
+	fs := &testFS{
+		"app.meta_lic": []byte(AOSP + "projects: \"packages/apps/app\"\n" +
+			"installed: \"out/app.apk\"\n" +
+			"installed: \"out/missing.apk\"\n"),
+		"out/app.apk": []byte("apk"),
+	}
+	lg, err := ReadLicenseGraph(fs, &bytes.Buffer{}, []string{"app.meta_lic"})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	doc := NewSPDXDocument(fs, lg, "test", "https://example.com/test", "Tool: test", time.Unix(0, 0))
+
+	tv := &bytes.Buffer{}
+	if err := doc.WriteTagValue(tv); err != nil {
+		t.Fatalf("unexpected tag-value error: got %s, want no error", err)
+	}
+	for _, expected := range []string{
+		"SPDXVersion: SPDX-2.3\n",
+		"DocumentNamespace: https://example.com/test\n",
+		"PackageName: app.meta_lic\n",
+		"PackageSourceInfo: <text>built from project(s): packages/apps/app</text>\n",
+		"PackageLicenseDeclared: Apache-2.0\n",
+		"FileName: out/app.apk\nSPDXID: SPDXRef-File-out-app.apk\nFileChecksum: SHA1: f5fce1d3a0929197f8bbf9423ee507657420c4c0\n",
+		"PackageName: out/missing.apk\nSPDXID: SPDXRef-File-out-missing.apk\n",
+		"PackageComment: <text>installed file not found at time of analysis; checksum not computed</text>\n",
+		"Relationship: SPDXRef-app.meta-lic CONTAINS SPDXRef-File-out-app.apk\n",
+		"Relationship: SPDXRef-app.meta-lic CONTAINS SPDXRef-File-out-missing.apk\n",
+	} {
+		if !strings.Contains(tv.String(), expected) {
+			t.Errorf("missing tag-value output: got %s, want %q", tv.String(), expected)
+		}
+	}
+
+	js := &bytes.Buffer{}
+	if err := doc.WriteJSON(js); err != nil {
+		t.Fatalf("unexpected json error: got %s, want no error", err)
+	}
+	var actual SPDXDocument
+	if err := json.Unmarshal(js.Bytes(), &actual); err != nil {
+		t.Fatalf("unexpected json decode error: got %s, want no error", err)
+	}
+	if len(actual.Packages) != 2 || actual.Packages[0].Name != "app.meta_lic" || actual.Packages[1].Name != "out/missing.apk" {
+		t.Errorf("unexpected json packages: got %v, want app.meta_lic and out/missing.apk", actual.Packages)
+	}
+	if len(actual.Files) != 1 || len(actual.Files[0].Checksums) != 1 {
+		t.Errorf("unexpected json files: got %v, want out/app.apk with a checksum", actual.Files)
+	}
+}
+
+func TestSPDXLicenseExpression(t *testing.T) {
+	tests := []struct {
+		kind       string
+		expression string
+		ok         bool
+	}{
+		{"SPDX-license-identifier-Apache-2.0", "Apache-2.0", true},
+		{"SPDX-license-identifier-GPL-2.0", "GPL-2.0-only", true},
+		{"SPDX-license-identifier-LGPL-2.1+", "LGPL-2.1-or-later", true},
+		{"SPDX-license-identifier-GPL-2.0-with-classpath-exception", "GPL-2.0-only WITH Classpath-exception-2.0", true},
+		{"SPDX-license-identifier-GPL", "", false},
+		{"SPDX-license-identifier-BSD", "", false},
+		{"SPDX-license-identifier-CC-BY", "", false},
+		{"legacy_proprietary", "", false},
+	}
+	for _, tt := range tests {
+		expression, ok := spdxLicenseExpression(tt.kind)
+		if expression != tt.expression || ok != tt.ok {
+			t.Errorf("spdxLicenseExpression(%q): got %q, %t, want %q, %t", tt.kind, expression, ok, tt.expression, tt.ok)
+		}
+	}
+}
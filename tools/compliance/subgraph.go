@@ -0,0 +1,96 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SubGraph derives a new LicenseGraph from `lg` rooted at the targets named
+// `roots` containing only the targets and edges reachable from them.
+//
+// The new graph shares the immutable target nodes and edges of `lg` without
+// re-reading any license metadata, but like any other graph, resolutions and
+// shipped nodes for the new graph get computed and cached separately. i.e.
+// ResolveTopDownConditions and ShippedNodes on the new graph consider only
+// the new roots.
+func SubGraph(lg *LicenseGraph, roots ...string) (*LicenseGraph, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no roots for sub-graph")
+	}
+
+	// must be indexed for fast lookup
+	lg.indexForward()
+
+	sub := newLicenseGraph()
+	seen := make(map[string]bool)
+	for _, r := range roots {
+		r = MetadataFileName(r)
+		if _, ok := lg.targets[r]; !ok {
+			return nil, fmt.Errorf("target %q missing from graph", r)
+		}
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		sub.rootFiles = append(sub.rootFiles, r)
+	}
+
+	queue := append([]string{}, sub.rootFiles...)
+	for len(queue) > 0 {
+		f := queue[0]
+		queue = queue[1:]
+		if _, ok := sub.targets[f]; ok {
+			continue
+		}
+		sub.targets[f] = lg.targets[f]
+		for _, e := range lg.index[f] {
+			sub.edges = append(sub.edges, e)
+			queue = append(queue, e.dependency)
+		}
+	}
+	return sub, nil
+}
+
+// InstalledSubGraph derives a new LicenseGraph from `lg` rooted at every
+// target installing a file with a path starting with `prefix`, e.g. the
+// targets installed on one partition, containing only the targets and edges
+// reachable from them.
+//
+// Only the roots get chosen by `prefix`. The graph is not restricted to the
+// targets installed under `prefix`: the dependencies reachable from the roots
+// stay wherever they get installed, or when they install nothing, because
+// their conditions apply to the roots built from or linked to them. e.g. a
+// library installed on another partition but statically linked into a root.
+//
+// See SubGraph.
+func InstalledSubGraph(lg *LicenseGraph, prefix string) (*LicenseGraph, error) {
+	roots := make([]string, 0)
+	for name, tn := range lg.targets {
+		for _, installed := range tn.proto.Installed {
+			if strings.HasPrefix(installed, prefix) {
+				roots = append(roots, name)
+				break
+			}
+		}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no targets installed under %q", prefix)
+	}
+	sort.Strings(roots)
+	return SubGraph(lg, roots...)
+}
@@ -0,0 +1,177 @@
+// Copyright 2021 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compliance
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// subGraphFS is a test file system for a container of 2 binaries installed on
+// different partitions.
+var subGraphFS = testFS{
+	"apacheContainer.meta_lic": []byte(AOSP + "is_container: true\ninstalled: \"out/system.img\"\n" +
+		"deps: {\n  file: \"apacheBin.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mplBin.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"apacheBin.meta_lic": []byte(AOSP + "installed: \"out/system/bin/apacheBin\"\n" +
+		"deps: {\n  file: \"gplLib.meta_lic\"\n  annotations: \"static\"\n}\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"dynamic\"\n}\n"),
+	"mplBin.meta_lic": []byte(MPL + "installed: \"out/vendor/bin/mplBin\"\n" +
+		"deps: {\n  file: \"mitLib.meta_lic\"\n  annotations: \"static\"\n}\n"),
+	"gplLib.meta_lic": []byte(GPL),
+	"mitLib.meta_lic": []byte(MIT + "installed: \"out/system/lib/mitLib.so\"\n"),
+}
+
+// describeGraph returns the sorted edges, shipped nodes and top-down
+// resolutions of `lg` by name for comparing graphs.
+func describeGraph(lg *LicenseGraph) []string {
+	result := make([]string, 0)
+	for _, e := range lg.Edges() {
+		result = append(result, "edge "+e.Target().Name()+" -> "+e.Dependency().Name())
+	}
+	for _, name := range ShippedNodes(lg, DefaultPolicy).Names() {
+		result = append(result, "shipped "+name)
+	}
+	rs := ResolveTopDownConditions(lg, DefaultPolicy)
+	for _, tn := range rs.AttachesTo() {
+		for _, r := range rs.Resolutions(tn) {
+			conditions := r.Resolves().asStringList(":")
+			sort.Strings(conditions)
+			result = append(result, "resolution "+r.AttachesTo().Name()+" "+r.ActsOn().Name()+" "+strings.Join(conditions, " "))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestSubGraph(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	lg, err := ReadLicenseGraph(&subGraphFS, stderr, []string{"apacheContainer.meta_lic"})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+
+	tests := []struct {
+		name            string
+		roots           []string
+		prefix          string
+		expectedRoots   []string
+		expectedTargets []string
+	}{
+		{
+			name:            "bin",
+			roots:           []string{"apacheBin.meta_lic"},
+			expectedRoots:   []string{"apacheBin.meta_lic"},
+			expectedTargets: []string{"apacheBin.meta_lic", "gplLib.meta_lic", "mitLib.meta_lic"},
+		},
+		{
+			name:            "bins",
+			roots:           []string{"mplBin", "apacheBin.meta_lic", "mplBin.meta_lic"},
+			expectedRoots:   []string{"mplBin.meta_lic", "apacheBin.meta_lic"},
+			expectedTargets: []string{"apacheBin.meta_lic", "gplLib.meta_lic", "mitLib.meta_lic", "mplBin.meta_lic"},
+		},
+		{
+			name:            "lib",
+			roots:           []string{"mitLib.meta_lic"},
+			expectedRoots:   []string{"mitLib.meta_lic"},
+			expectedTargets: []string{"mitLib.meta_lic"},
+		},
+		{
+			name:            "system",
+			prefix:          "out/system/",
+			expectedRoots:   []string{"apacheBin.meta_lic", "mitLib.meta_lic"},
+			expectedTargets: []string{"apacheBin.meta_lic", "gplLib.meta_lic", "mitLib.meta_lic"},
+		},
+		{
+			// dependencies installed elsewhere stay in the sub-graph
+			name:            "vendor",
+			prefix:          "out/vendor/",
+			expectedRoots:   []string{"mplBin.meta_lic"},
+			expectedTargets: []string{"mitLib.meta_lic", "mplBin.meta_lic"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sub *LicenseGraph
+			var err error
+			if len(tt.prefix) > 0 {
+				sub, err = InstalledSubGraph(lg, tt.prefix)
+			} else {
+				sub, err = SubGraph(lg, tt.roots...)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: got %s, want no error", err)
+			}
+			checkSameStrings("root", sub.Roots().Names(), tt.expectedRoots, t)
+			actualTargets := sub.Targets().Names()
+			sort.Strings(actualTargets)
+			checkSameStrings("target", actualTargets, tt.expectedTargets, t)
+
+			// the derived graph matches a graph read with the same roots
+			fresh, err := ReadLicenseGraph(&subGraphFS, stderr, sub.rootFiles)
+			if err != nil {
+				t.Fatalf("unexpected test data error: got %s, want no error", err)
+			}
+			checkSameStrings("description", describeGraph(sub), describeGraph(fresh), t)
+		})
+	}
+
+	// the original graph keeps its own roots and resolutions
+	shipped := ShippedNodes(lg, DefaultPolicy).Names()
+	sort.Strings(shipped)
+	checkSameStrings("shipped node", shipped, []string{
+		"apacheBin.meta_lic", "apacheContainer.meta_lic", "gplLib.meta_lic", "mitLib.meta_lic", "mplBin.meta_lic",
+	}, t)
+}
+
+func TestSubGraph_errors(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	lg, err := ReadLicenseGraph(&subGraphFS, stderr, []string{"apacheContainer.meta_lic"})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	if _, err := SubGraph(lg); err == nil {
+		t.Errorf("missing error for no roots")
+	}
+	if _, err := SubGraph(lg, "nosuch.meta_lic"); err == nil || !strings.Contains(err.Error(), "missing from graph") {
+		t.Errorf("unexpected error for unknown root: got %v, want missing from graph", err)
+	}
+	if _, err := SubGraph(lg, "apacheContainer.meta_lic.json"); err == nil || !strings.Contains(err.Error(), "missing from graph") {
+		t.Errorf("unexpected error for unknown json root: got %v, want missing from graph", err)
+	}
+	if _, err := InstalledSubGraph(lg, "out/product/"); err == nil || !strings.Contains(err.Error(), "no targets installed") {
+		t.Errorf("unexpected error for unmatched prefix: got %v, want no targets installed", err)
+	}
+}
+
+func TestSubGraph_jsonRoot(t *testing.T) {
+	fs := testFS{}
+	for name, data := range subGraphFS {
+		fs[name] = data
+	}
+	fs["apacheContainer.json.meta_lic"] = encodeMetadata(t, string(subGraphFS["apacheContainer.meta_lic"]), JSONFormat)
+	stderr := &bytes.Buffer{}
+	lg, err := ReadLicenseGraph(&fs, stderr, []string{"apacheContainer.json"})
+	if err != nil {
+		t.Fatalf("unexpected test data error: got %s, want no error", err)
+	}
+	sub, err := SubGraph(lg, "apacheContainer.json")
+	if err != nil {
+		t.Fatalf("unexpected error: got %s, want no error", err)
+	}
+	checkSameStrings("description", describeGraph(sub), describeGraph(lg), t)
+}
@@ -406,3 +406,17 @@ func checkSame(rsActual, rsExpected *ResolutionSet, t *testing.T) {
 		}
 	}
 }
+
+// checkSameStrings compares an actual list of strings to an expected list for a test.
+func checkSameStrings(kind string, actual, expected []string, t *testing.T) {
+	if len(actual) != len(expected) {
+		t.Errorf("unexpected number of %ss: got %v with %d elements, want %v with %d elements",
+			kind, actual, len(actual), expected, len(expected))
+		return
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Errorf("unexpected %s at element %d: got %q, want %q", kind, i, actual[i], expected[i])
+		}
+	}
+}